@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"log"
+	"time"
 
 	"cmd/main.go/cmd/migrations"
 	"cmd/main.go/pkg/api"
@@ -31,9 +32,23 @@ func main() {
 	ragService, err := rag.NewServiceFromEnv(ctx)
 	if err != nil {
 		log.Printf("RAG service disabled: %v", err)
+	} else {
+		go warmupRAG(ragService)
 	}
 
 	api.SetupRoutes(app, ragService)
 
 	log.Fatal(app.Listen(":8000"))
 }
+
+// warmupRAG loads the embedding and chat models in the background so the first query is not slowed by a cold start.
+func warmupRAG(ragService *rag.Service) {
+	ctx, cancel := context.WithTimeout(context.Background(), ragService.QueryTimeout())
+	defer cancel()
+	started := time.Now()
+	if err := ragService.Warmup(ctx); err != nil {
+		log.Printf("RAG warmup failed after %s: %v", time.Since(started).Round(time.Millisecond), err)
+		return
+	}
+	log.Printf("RAG warmup completed in %s", time.Since(started).Round(time.Millisecond))
+}
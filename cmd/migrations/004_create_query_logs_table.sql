@@ -0,0 +1,15 @@
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS query_logs (
+    id SERIAL PRIMARY KEY,
+    question TEXT NOT NULL,
+    top_k INTEGER NOT NULL,
+    answer_length INTEGER NOT NULL,
+    source_document_ids TEXT[] NOT NULL DEFAULT '{}',
+    latency_ms BIGINT NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT current_timestamp,
+    updated_at TIMESTAMPTZ DEFAULT current_timestamp,
+    deleted_at TIMESTAMPTZ
+);
+
+-- +migrate Down
+DROP TABLE IF EXISTS query_logs;
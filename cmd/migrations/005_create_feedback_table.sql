@@ -0,0 +1,15 @@
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS feedbacks (
+    id SERIAL PRIMARY KEY,
+    query_log_id INTEGER NOT NULL REFERENCES query_logs (id) ON DELETE CASCADE,
+    rating VARCHAR(8) NOT NULL,
+    comment TEXT,
+    created_at TIMESTAMPTZ DEFAULT current_timestamp,
+    updated_at TIMESTAMPTZ DEFAULT current_timestamp,
+    deleted_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS feedbacks_query_log_id_idx ON feedbacks (query_log_id);
+
+-- +migrate Down
+DROP TABLE IF EXISTS feedbacks;
@@ -2,35 +2,195 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
+	"os"
+	"os/signal"
+	"regexp"
+	"sort"
 	"strings"
+	"time"
+	"unicode/utf8"
 
 	"cmd/main.go/pkg/rag"
 )
 
 func main() {
-	mode := flag.String("mode", "ingest", "ingest or query")
+	mode := flag.String("mode", "ingest", "ingest, plan, suggest, chunk, eval, query, export, import, merge, or stale")
 	indexPath := flag.String("index", rag.DefaultIndexPath, "path to the rag index (JSON file)")
 	docsDir := flag.String("docs", rag.DefaultLocalDocsFolder, "local docs directory to include during ingestion")
-	chunkSize := flag.Int("chunk-size", 1400, "characters per chunk")
-	chunkOverlap := flag.Int("chunk-overlap", 200, "character overlap between chunks")
+	chunkSize := flag.Int("chunk-size", rag.DefaultChunkSize, "characters per chunk")
+	chunkOverlap := flag.Int("chunk-overlap", rag.DefaultChunkOverlap, "character overlap between chunks")
+	embedBatch := flag.Int("embed-batch", 0, "chunks per embedding request; 0 uses RAG_EMBED_BATCH_SIZE (default 16)")
+	chunkOverlapRatio := flag.Float64("chunk-overlap-ratio", 0, "overlap as a fraction of --chunk-size, e.g. 0.15; overrides --chunk-overlap when set")
+	keepCodeBlocks := flag.Bool("keep-code-blocks", false, "never split fenced code blocks across chunks; chunks may grow up to --max-code-block")
+	maxCodeBlock := flag.Int("max-code-block", 0, "hard cap in characters for a chunk holding a code block; 0 uses 4x --chunk-size")
+	maxChunks := flag.Int("max-chunks", 0, "stop chunking once this many chunks exist, keeping them; 0 is unlimited")
+	chunkMin := flag.Int("chunk-min", 0, "merge a trailing chunk shorter than this many characters into the previous one")
 	topK := flag.Int("top-k", rag.DefaultTopK, "number of chunks to send to the LLM in query mode")
+	contextWindow := flag.Int("context-window", 0, fmt.Sprintf("neighbouring chunks added on each side of every match in query mode, up to %d", rag.MaxContextWindow))
+	contextOrder := flag.String("context-order", "", "order of context sections in query mode: most_first, least_first, or lost_in_middle")
 	questionFlag := flag.String("question", "", "question to ask when mode=query")
+	chunkFile := flag.String("file", "", "file to split when mode=chunk")
+	casesFile := flag.String("cases", "", "JSON file of evaluation cases when mode=eval")
+	mergeInputs := flag.String("inputs", "", "comma-separated JSON indexes to combine when mode=merge")
+	jsonlPath := flag.String("jsonl", "", "JSONL file for mode=export or mode=import; empty uses stdout or stdin")
+	format := flag.String("format", "text", "query and stale output format: text or json")
+	maxAge := flag.Duration("max-age", 7*24*time.Hour, "report sources fetched longer ago than this when mode=stale")
+	fetchTimeout := flag.Duration("fetch-timeout", rag.DefaultFetchTimeout, "timeout for each remote request during ingestion")
+	storeDocuments := flag.Bool("store-documents", false, "keep the original documents in the JSON index for offline re-chunking (roughly doubles its size)")
+	maxDocuments := flag.Int("max-documents", 0, "stop collecting once this many documents are found, keeping them; 0 is unlimited")
+	maxBytes := flag.Int64("max-bytes", rag.DefaultMaxFetchBytes, "maximum response size in bytes for each remote request")
+	imageText := flag.Bool("image-text", false, "keep image alt text, figure captions, and title attributes from HTML pages")
+	stripBoilerplate := flag.Bool("strip-boilerplate", false, "remove navigation, cookie banners, and lines repeated across most HTML pages of a site")
+	summarize := flag.Bool("summarize", false, "generate a per-document summary with the chat model and embed it with each chunk")
+	preserveParagraphs := flag.Bool("preserve-paragraphs", false, "keep blank lines between paragraphs in ingested documents")
+	userAgent := flag.String("user-agent", rag.DefaultUserAgent, "User-Agent header for remote fetches")
+	crawlDelay := flag.Duration("crawl-delay", time.Second, "minimum delay between requests to the same host")
+	ignoreRobots := flag.Bool("ignore-robots", false, "fetch remote sources even when robots.txt disallows them")
+	exclude := flag.String("exclude", "", "comma-separated gitignore-style globs of local docs to skip, e.g. \"drafts/,**/*.tmpl.md\"")
+	languages := flag.String("languages", "", "comma-separated ISO 639-1 codes to keep during ingestion, e.g. en")
+	githubRepo := flag.String("github", "", "GitHub repository to ingest as owner/repo[@ref]")
+	githubGlobs := flag.String("github-globs", "", "comma-separated path globs for --github, e.g. \"**/*.md,src/**\"")
+	githubExts := flag.String("github-ext", "", "comma-separated file extensions for --github, e.g. .md,.java,.py")
+	crawlSeed := flag.String("crawl", "", "seed URL to crawl during ingestion (same host only)")
+	crawlDepth := flag.Int("crawl-depth", rag.DefaultCrawlDepth, "link hops to follow from the crawl seed")
+	crawlMaxPages := flag.Int("crawl-max-pages", rag.DefaultCrawlMaxPages, "maximum pages to ingest from the crawl")
+	crawlPrefix := flag.String("crawl-prefix", "", "only follow links whose path starts with this prefix")
+	urlList := flag.String("url-list", "", "file of URLs, one per line as url or format|url, to fetch and append to the existing index instead of the default sources")
+	sitemapURL := flag.String("sitemap", "", "sitemap.xml URL whose pages are ingested")
+	sitemapPrefix := flag.String("sitemap-prefix", "", "only ingest sitemap URLs starting with this prefix")
+	sitemapMax := flag.Int("sitemap-max", rag.DefaultSitemapMaxURLs, "maximum pages to ingest from the sitemap")
+	headers := headerFlag{}
+	flag.Var(headers, "header", "extra \"Name: value\" header for every remote request; repeatable")
+	var boilerplatePatterns patternFlag
+	flag.Var(&boilerplatePatterns, "boilerplate-pattern", "extra regular expression for whole HTML lines to strip as boilerplate; repeatable")
 	flag.Parse()
 
-	ctx := context.Background()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
 	cfg := rag.LoadServiceConfigFromEnv()
-	if cfg.Provider == rag.ProviderOpenAI && cfg.OpenAIAPIKey == "" {
-		log.Fatal("OPENAI_API_KEY must be set when RAG_PROVIDER=openai")
+	if *embedBatch != 0 {
+		cfg.EmbedBatchSize = *embedBatch
+	}
+	selectedMode := strings.ToLower(*mode)
+	// These modes never talk to a provider, so they run without provider credentials.
+	switch selectedMode {
+	case "plan", "suggest", "chunk", "export", "import", "merge", "stale":
+	default:
+		if err := cfg.Validate(); err != nil {
+			log.Fatal(err)
+		}
 	}
 
 	resolvedIndex := rag.ResolveWorkspacePath(*indexPath)
+	chunkOpts := rag.ChunkOptions{
+		Size:           *chunkSize,
+		Overlap:        *chunkOverlap,
+		OverlapRatio:   *chunkOverlapRatio,
+		MinSize:        *chunkMin,
+		KeepCodeBlocks: *keepCodeBlocks,
+		MaxCodeBlock:   *maxCodeBlock,
+		MaxChunks:      *maxChunks,
+	}
 
-	switch strings.ToLower(*mode) {
-	case "ingest":
-		runIngest(ctx, cfg, rag.ResolveWorkspacePath(*docsDir), resolvedIndex, *chunkSize, *chunkOverlap)
+	switch selectedMode {
+	case "ingest", "plan", "suggest":
+		opts := rag.DefaultSourceOptions(rag.ResolveWorkspacePath(*docsDir))
+		opts.UserAgent = *userAgent
+		opts.HTTPHeaders = headers
+		opts.CrawlDelay = *crawlDelay
+		opts.IgnoreRobots = *ignoreRobots
+		opts.AllowLanguages = splitFlagList(*languages)
+		opts.ExcludeGlobs = splitFlagList(*exclude)
+		opts.PreserveParagraphs = *preserveParagraphs
+		opts.Summarize = *summarize
+		opts.HTML.ImageText = *imageText
+		opts.HTML.RemoveBoilerplate = *stripBoilerplate
+		opts.HTML.BoilerplatePatterns = boilerplatePatterns
+		opts.FetchTimeout = *fetchTimeout
+		opts.MaxBytes = *maxBytes
+		opts.MaxDocuments = *maxDocuments
+		opts.StoreFullDocuments = *storeDocuments
+		opts.Retry = cfg.Retry
+		if *urlList != "" {
+			sources, err := rag.LoadURLList(*urlList)
+			if err != nil {
+				log.Fatalf("load URL list: %v", err)
+			}
+			if len(sources) == 0 {
+				log.Fatalf("%s lists no URLs", *urlList)
+			}
+			// The list replaces the built-in sources; explicit crawl, sitemap, and GitHub flags still apply.
+			opts.LocalDocsDir = ""
+			opts.RemoteSources = sources
+		}
+		if *githubRepo != "" {
+			src, err := parseGitHubFlag(*githubRepo)
+			if err != nil {
+				log.Fatal(err)
+			}
+			src.PathGlobs = splitFlagList(*githubGlobs)
+			src.IncludeExtensions = splitFlagList(*githubExts)
+			opts.GitHubSources = append(opts.GitHubSources, src)
+		}
+		if *crawlSeed != "" {
+			opts.CrawlSources = append(opts.CrawlSources, rag.CrawlSource{
+				SeedURL:         *crawlSeed,
+				MaxDepth:        *crawlDepth,
+				SameHostOnly:    true,
+				MaxPages:        *crawlMaxPages,
+				AllowPathPrefix: *crawlPrefix,
+			})
+		}
+		if *sitemapURL != "" {
+			opts.SitemapSources = append(opts.SitemapSources, rag.SitemapSource{
+				URL:          *sitemapURL,
+				FilterPrefix: *sitemapPrefix,
+				MaxURLs:      *sitemapMax,
+			})
+		}
+		switch selectedMode {
+		case "plan":
+			runPlan(ctx, opts, chunkOpts)
+			return
+		case "suggest":
+			runSuggest(ctx, opts)
+			return
+		}
+		if *urlList != "" {
+			runAppend(ctx, cfg, opts, resolvedIndex, chunkOpts)
+			return
+		}
+		runIngest(ctx, cfg, opts, resolvedIndex, chunkOpts)
+	case "chunk":
+		if *chunkFile == "" {
+			log.Fatal("provide a file via --file when mode=chunk")
+		}
+		runChunk(*chunkFile, chunkOpts, *preserveParagraphs)
+	case "eval":
+		if *casesFile == "" {
+			log.Fatal("provide evaluation cases via --cases when mode=eval")
+		}
+		runEval(ctx, cfg, *casesFile, resolvedIndex)
+	case "export":
+		runExport(resolvedIndex, *jsonlPath)
+	case "import":
+		runImport(*jsonlPath, resolvedIndex)
+	case "merge":
+		inputs := splitFlagList(*mergeInputs)
+		if len(inputs) < 2 {
+			log.Fatal("provide at least two indexes via --inputs when mode=merge, e.g. --inputs a.json,b.json")
+		}
+		runMerge(inputs, resolvedIndex)
+	case "stale":
+		outputFormat := strings.ToLower(*format)
+		if outputFormat != "text" && outputFormat != "json" {
+			log.Fatalf("unsupported format %s, expected text or json", *format)
+		}
+		runStale(cfg, resolvedIndex, *maxAge, outputFormat)
 	case "query":
 		question := strings.TrimSpace(*questionFlag)
 		if question == "" {
@@ -39,44 +199,235 @@ func main() {
 		if question == "" {
 			log.Fatal("provide a question via --question or as a positional argument, e.g. --mode query --question \"How do SP-API rate limits work?\"")
 		}
-		runQuery(ctx, cfg, question, resolvedIndex, *topK)
+		outputFormat := strings.ToLower(*format)
+		if outputFormat != "text" && outputFormat != "json" {
+			log.Fatalf("unsupported format %s, expected text or json", *format)
+		}
+		order, err := rag.ParseContextOrder(*contextOrder)
+		if err != nil {
+			log.Fatal(err)
+		}
+		runQuery(ctx, cfg, question, resolvedIndex, rag.QueryOptions{TopK: *topK, ContextOrder: order, ContextWindow: *contextWindow}, outputFormat)
 	default:
 		log.Fatalf("unsupported mode %s", *mode)
 	}
 }
 
-func runIngest(ctx context.Context, cfg rag.ServiceConfig, docsDir, indexPath string, chunkSize, chunkOverlap int) {
-	opts := rag.DefaultSourceOptions(docsDir)
-	documents, err := rag.CollectDocuments(ctx, opts)
+func runIngest(ctx context.Context, cfg rag.ServiceConfig, opts rag.SourceOptions, indexPath string, chunkOpts rag.ChunkOptions) {
+	documents, chunks, store := buildStore(ctx, cfg, opts, chunkOpts)
+	if cfg.Store == rag.StorePgVector {
+		if opts.StoreFullDocuments {
+			log.Printf("note: --store-documents is ignored for pgvector; only chunks are saved")
+		}
+		pgStore, err := rag.OpenPgVectorStore(cfg.DatabaseURL)
+		if err != nil {
+			log.Fatalf("open pgvector store: %v", err)
+		}
+		if err := pgStore.Replace(store.Chunks); err != nil {
+			log.Fatalf("save pgvector store: %v", err)
+		}
+		fmt.Printf("Ingestion complete: %d documents -> %d chunks (saved to pgvector)\n", len(documents), len(chunks))
+		return
+	}
+	if err := store.SaveWithBackups(indexPath, cfg.IndexBackups); err != nil {
+		log.Fatalf("save vector store: %v", err)
+	}
+
+	fmt.Printf("Ingestion complete: %d documents -> %d chunks (saved at %s)\n", len(documents), len(chunks), indexPath)
+}
+
+// runAppend ingests the configured sources into the existing index, replacing documents that are
+// already indexed and keeping everything else.
+func runAppend(ctx context.Context, cfg rag.ServiceConfig, opts rag.SourceOptions, indexPath string, chunkOpts rag.ChunkOptions) {
+	documents, chunks, built := buildStore(ctx, cfg, opts, chunkOpts)
+	cfg.IndexPath = indexPath
+	store, err := rag.OpenOrCreateStore(cfg)
+	if err != nil {
+		log.Fatalf("open %s store: %v", cfg.Store, err)
+	}
+	added, updated, skipped, err := rag.AppendDocuments(store, built, cfg.DuplicateThreshold)
+	if err != nil {
+		log.Fatalf("append to index: %v", err)
+	}
+	where := "pgvector"
+	if vs, ok := store.(*rag.VectorStore); ok {
+		if err := vs.SaveWithBackups(indexPath, cfg.IndexBackups); err != nil {
+			log.Fatalf("save vector store: %v", err)
+		}
+		where = indexPath
+	}
+	fmt.Printf("Append complete: %d documents (%d new, %d updated) -> %d chunks, %d skipped as near-duplicates; index now holds %d chunks (saved to %s)\n",
+		len(documents), added, updated, len(chunks), skipped, store.Len(), where)
+}
+
+// buildStore collects, chunks, and embeds the configured sources into a new in-memory store,
+// exiting on failure.
+func buildStore(ctx context.Context, cfg rag.ServiceConfig, opts rag.SourceOptions, chunkOpts rag.ChunkOptions) ([]rag.Document, []rag.Chunk, *rag.VectorStore) {
+	documents, notes, err := rag.CollectDocumentsWithNotes(ctx, opts)
 	if err != nil {
 		log.Fatalf("collect documents: %v", err)
 	}
+	for _, note := range notes {
+		log.Printf("note: %s", note)
+	}
 	if len(documents) == 0 {
 		log.Fatal("no documents discovered for ingestion")
 	}
+	if opts.Summarize {
+		chatClient, err := rag.NewChatClient(cfg)
+		if err != nil {
+			log.Fatalf("create chat client: %v", err)
+		}
+		summaryNotes := rag.SummarizeDocuments(ctx, chatClient, documents)
+		for _, note := range summaryNotes {
+			log.Printf("note: %s", note)
+		}
+		notes = append(notes, summaryNotes...)
+	}
 
-	chunks := rag.ChunkDocuments(documents, rag.ChunkOptions{Size: chunkSize, Overlap: chunkOverlap})
+	chunks, chunkNotes := rag.ChunkDocumentsWithNotes(documents, chunkOpts)
+	for _, note := range chunkNotes {
+		log.Printf("note: %s", note)
+	}
+	notes = append(notes, chunkNotes...)
 	embedder, err := rag.NewEmbedder(cfg)
 	if err != nil {
 		log.Fatalf("create embedder: %v", err)
 	}
 
 	meta := rag.MetadataForRun(len(documents), len(chunks))
-	store, err := rag.BuildVectorStore(ctx, chunks, embedder, 16, meta)
+	meta.Notes = notes
+	store, err := rag.BuildVectorStore(ctx, chunks, embedder, rag.BuildOptions{BatchSize: cfg.EmbedBatchSize, DocumentPrefix: cfg.EmbedDocumentPrefix, Progress: printProgress}, meta)
+	fmt.Fprintln(os.Stderr)
 	if err != nil {
 		log.Fatalf("build vector store: %v", err)
 	}
-	if err := store.Save(indexPath); err != nil {
-		log.Fatalf("save vector store: %v", err)
+	if opts.StoreFullDocuments {
+		store.Documents = documents
 	}
+	return documents, chunks, store
+}
 
-	fmt.Printf("Ingestion complete: %d documents -> %d chunks (saved at %s)\n", len(documents), len(chunks), indexPath)
+// runPlan collects documents and reports what an ingest would embed, without calling the embedder.
+func runPlan(ctx context.Context, opts rag.SourceOptions, chunkOpts rag.ChunkOptions) {
+	documents, notes, err := rag.CollectDocumentsWithNotes(ctx, opts)
+	if err != nil {
+		log.Fatalf("collect documents: %v", err)
+	}
+	for _, note := range notes {
+		log.Printf("note: %s", note)
+	}
+
+	totalChars, totalChunks := 0, 0
+	for _, doc := range documents {
+		chars := utf8.RuneCountInString(doc.Content)
+		chunks := len(rag.ChunkDocuments([]rag.Document{doc}, chunkOpts))
+		totalChars += chars
+		totalChunks += chunks
+		fmt.Printf("- %s\n  uri: %s\n  source: %s\n  chars: %d, chunks: %d\n", doc.Title, doc.URI, doc.Source, chars, chunks)
+	}
+	fmt.Printf("\nPlan: %d documents, %d characters -> %d chunks (nothing embedded)\n", len(documents), totalChars, totalChunks)
 }
 
-func runQuery(ctx context.Context, cfg rag.ServiceConfig, question, indexPath string, topK int) {
-	store, err := rag.LoadVectorStore(indexPath)
+// runSuggest collects documents and prints recommended chunk options with the reasoning behind them.
+func runSuggest(ctx context.Context, opts rag.SourceOptions) {
+	// Paragraph lengths are measured between blank lines, so keep them.
+	opts.PreserveParagraphs = true
+	documents, notes, err := rag.CollectDocumentsWithNotes(ctx, opts)
+	if err != nil {
+		log.Fatalf("collect documents: %v", err)
+	}
+	for _, note := range notes {
+		log.Printf("note: %s", note)
+	}
+
+	suggested, rationale := rag.SuggestChunkOptionsWithNotes(documents)
+	for _, line := range rationale {
+		fmt.Printf("- %s\n", line)
+	}
+	flags := fmt.Sprintf("--chunk-size %d --chunk-overlap %d", suggested.Size, suggested.Overlap)
+	if suggested.KeepCodeBlocks {
+		flags += " --keep-code-blocks"
+	}
+	fmt.Printf("\nSuggested: %s\n", flags)
+}
+
+// runChunk prints how a single file is split into chunks, normalizing it as ingestion does.
+func runChunk(path string, chunkOpts rag.ChunkOptions, preserveParagraphs bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Fatalf("read %s: %v", path, err)
+	}
+	content := rag.NormalizeWhitespace(string(data), preserveParagraphs)
+	windows := rag.SlidingWindows(content, chunkOpts)
+	effective := chunkOpts.Normalized()
+	for i, text := range windows {
+		fmt.Printf("[%d] %d chars\n  start: %q\n  end:   %q\n", i, utf8.RuneCountInString(text), headRunes(text, 40), tailRunes(text, 40))
+	}
+	fmt.Printf("\n%s -> %d chunks (size %d, overlap %d)\n", path, len(windows), effective.Size, effective.Overlap)
+}
+
+func headRunes(text string, n int) string {
+	runes := []rune(text)
+	if len(runes) <= n {
+		return text
+	}
+	return string(runes[:n])
+}
+
+func tailRunes(text string, n int) string {
+	runes := []rune(text)
+	if len(runes) <= n {
+		return text
+	}
+	return string(runes[len(runes)-n:])
+}
+
+// runEval measures retrieval quality against a JSON array of rag.EvalCase values.
+func runEval(ctx context.Context, cfg rag.ServiceConfig, casesPath, indexPath string) {
+	data, err := os.ReadFile(casesPath)
+	if err != nil {
+		log.Fatalf("read %s: %v", casesPath, err)
+	}
+	var cases []rag.EvalCase
+	if err := json.Unmarshal(data, &cases); err != nil {
+		log.Fatalf("parse %s: %v", casesPath, err)
+	}
+	cfg.IndexPath = indexPath
+	store, err := rag.OpenStore(cfg)
+	if err != nil {
+		log.Fatalf("open %s store: %v", cfg.Store, err)
+	}
+	embedder, err := rag.NewEmbedder(cfg)
+	if err != nil {
+		log.Fatalf("create embedder: %v", err)
+	}
+
+	report := rag.Evaluate(ctx, store, embedder, cfg.EmbedQueryPrefix, cases)
+	for _, result := range report.Results {
+		if result.Error != "" {
+			fmt.Printf("- %s\n  error: %s\n", result.Question, result.Error)
+			continue
+		}
+		fmt.Printf("- %s\n  first hit rank: %d, retrieved: %s\n", result.Question, result.FirstHitRank, strings.Join(result.RetrievedDocumentIDs, ", "))
+	}
+	fmt.Printf("\nCases: %d (failed %d)\n", report.Cases, report.Failed)
+	ks := make([]int, 0, len(report.RecallAtK))
+	for k := range report.RecallAtK {
+		ks = append(ks, k)
+	}
+	sort.Ints(ks)
+	for _, k := range ks {
+		fmt.Printf("Recall@%d: %.3f\n", k, report.RecallAtK[k])
+	}
+	fmt.Printf("MRR: %.3f\n", report.MRR)
+}
+
+func runQuery(ctx context.Context, cfg rag.ServiceConfig, question, indexPath string, opts rag.QueryOptions, format string) {
+	cfg.IndexPath = indexPath
+	store, err := rag.OpenStore(cfg)
 	if err != nil {
-		log.Fatalf("load vector store: %v", err)
+		log.Fatalf("open %s store: %v", cfg.Store, err)
 	}
 	embedder, err := rag.NewEmbedder(cfg)
 	if err != nil {
@@ -87,15 +438,199 @@ func runQuery(ctx context.Context, cfg rag.ServiceConfig, question, indexPath st
 		log.Fatalf("create chat client: %v", err)
 	}
 
-	service := rag.NewService(store, embedder, chatClient, cfg)
-	answer, err := service.Answer(ctx, question, rag.QueryOptions{TopK: topK})
+	service, err := rag.NewService(store, embedder, chatClient, cfg)
+	if err != nil {
+		log.Fatalf("create rag service: %v", err)
+	}
+	answer, err := service.Answer(ctx, question, opts)
 	if err != nil {
 		log.Fatalf("query rag: %v", err)
 	}
 
+	if format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(answer); err != nil {
+			log.Fatalf("encode answer: %v", err)
+		}
+		return
+	}
+
 	fmt.Println("Answer:\n", answer.Answer)
 	fmt.Println("\nSources:")
 	for _, src := range answer.Sources {
-		fmt.Printf("- (%.3f) %s => %s\n", src.Score, src.Title, src.URI)
+		target := src.URI
+		if src.Location != "" {
+			target = src.Location
+		}
+		if src.KeywordMatch {
+			fmt.Printf("- (keyword match) %s => %s\n", src.Title, target)
+			continue
+		}
+		fmt.Printf("- (%.3f) %s => %s\n", src.Score, src.Title, target)
+	}
+}
+
+// runStale lists the indexed sources fetched more than maxAge ago, oldest first.
+func runStale(cfg rag.ServiceConfig, indexPath string, maxAge time.Duration, format string) {
+	cfg.IndexPath = indexPath
+	store, err := rag.OpenStore(cfg)
+	if err != nil {
+		log.Fatalf("open %s store: %v", cfg.Store, err)
+	}
+	stale, err := rag.StaleSources(store, maxAge)
+	if err != nil {
+		log.Fatalf("list stale sources: %v", err)
+	}
+
+	if format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if stale == nil {
+			stale = []rag.SourceSummary{}
+		}
+		if err := encoder.Encode(stale); err != nil {
+			log.Fatalf("encode stale sources: %v", err)
+		}
+		return
+	}
+
+	if len(stale) == 0 {
+		fmt.Printf("No sources older than %s\n", maxAge)
+		return
+	}
+	fmt.Printf("%d sources older than %s:\n", len(stale), maxAge)
+	for _, src := range stale {
+		fetched := "fetch time unknown"
+		if !src.FetchedAt.IsZero() {
+			fetched = fmt.Sprintf("%s, %dd ago", src.FetchedAt.Format(time.DateOnly), int(time.Since(src.FetchedAt).Hours()/24))
+		}
+		fmt.Printf("- (%s) %s => %s\n", fetched, src.Title, src.URI)
+	}
+}
+
+// runExport writes the JSON index as JSONL to path, or stdout when path is empty.
+func runExport(indexPath, path string) {
+	store, err := rag.LoadVectorStore(indexPath)
+	if err != nil {
+		log.Fatalf("load %s: %v", indexPath, err)
+	}
+	out := os.Stdout
+	if path != "" {
+		out, err = os.Create(path)
+		if err != nil {
+			log.Fatalf("create %s: %v", path, err)
+		}
+	}
+	if err := store.ExportJSONL(out); err != nil {
+		log.Fatalf("export: %v", err)
+	}
+	if path != "" {
+		if err := out.Close(); err != nil {
+			log.Fatalf("close %s: %v", path, err)
+		}
+		log.Printf("exported %d chunks to %s", store.Len(), path)
+	}
+}
+
+// runImport builds a JSON index from JSONL read from path, or stdin when path is empty.
+func runImport(path, indexPath string) {
+	in := os.Stdin
+	if path != "" {
+		file, err := os.Open(path)
+		if err != nil {
+			log.Fatalf("open %s: %v", path, err)
+		}
+		defer file.Close()
+		in = file
+	}
+	store, err := rag.ImportJSONL(in)
+	if err != nil {
+		log.Fatalf("import: %v", err)
+	}
+	if err := store.Save(indexPath); err != nil {
+		log.Fatalf("save vector store: %v", err)
+	}
+	fmt.Printf("Imported %d chunks from %d documents (saved at %s)\n", store.Metadata.ChunkCount, store.Metadata.SourceCount, indexPath)
+}
+
+// runMerge combines several JSON indexes into one saved at indexPath.
+func runMerge(inputs []string, indexPath string) {
+	stores := make([]*rag.VectorStore, len(inputs))
+	for i, input := range inputs {
+		path := rag.ResolveWorkspacePath(input)
+		store, err := rag.LoadVectorStore(path)
+		if err != nil {
+			log.Fatalf("load %s: %v", path, err)
+		}
+		stores[i] = store
+	}
+	merged, err := rag.MergeVectorStores(stores...)
+	if err != nil {
+		log.Fatalf("merge: %v", err)
+	}
+	if err := merged.Save(indexPath); err != nil {
+		log.Fatalf("save vector store: %v", err)
+	}
+	fmt.Printf("Merged %d indexes: %d documents -> %d chunks (saved at %s)\n", len(inputs), merged.Metadata.SourceCount, merged.Metadata.ChunkCount, indexPath)
+}
+
+// printProgress redraws a single embedding progress line on stderr.
+func printProgress(done, total int) {
+	fmt.Fprintf(os.Stderr, "\rEmbedding chunks: %d/%d (%d%%)", done, total, done*100/total)
+}
+
+func splitFlagList(raw string) []string {
+	var out []string
+	for _, part := range strings.Split(raw, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+// headerFlag collects repeated --header "Name: value" flags.
+type headerFlag map[string]string
+
+func (h headerFlag) String() string {
+	parts := make([]string, 0, len(h))
+	for name, value := range h {
+		parts = append(parts, name+": "+value)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (h headerFlag) Set(raw string) error {
+	name, value, ok := strings.Cut(raw, ":")
+	name = strings.TrimSpace(name)
+	if !ok || name == "" {
+		return fmt.Errorf("invalid header %q, expected \"Name: value\"", raw)
+	}
+	h[name] = strings.TrimSpace(value)
+	return nil
+}
+
+// patternFlag collects repeated --boilerplate-pattern flags.
+type patternFlag []string
+
+func (p *patternFlag) String() string {
+	return strings.Join(*p, ", ")
+}
+
+func (p *patternFlag) Set(raw string) error {
+	if _, err := regexp.Compile(raw); err != nil {
+		return fmt.Errorf("invalid pattern %q: %w", raw, err)
+	}
+	*p = append(*p, raw)
+	return nil
+}
+
+func parseGitHubFlag(raw string) (rag.GitHubSource, error) {
+	repo, ref, _ := strings.Cut(raw, "@")
+	owner, name, ok := strings.Cut(repo, "/")
+	if !ok || owner == "" || name == "" {
+		return rag.GitHubSource{}, fmt.Errorf("invalid --github value %q, expected owner/repo[@ref]", raw)
 	}
+	return rag.GitHubSource{Owner: owner, Repo: name, Ref: ref}, nil
 }
@@ -1 +1,25 @@
 package api
+
+import (
+	"crypto/subtle"
+	"os"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// AdminAuth guards operator endpoints with the bearer token from RAG_ADMIN_TOKEN.
+// When the variable is unset, the guarded endpoints are disabled.
+func AdminAuth() fiber.Handler {
+	token := os.Getenv("RAG_ADMIN_TOKEN")
+	return func(c *fiber.Ctx) error {
+		if token == "" {
+			return fiber.NewError(fiber.StatusForbidden, "admin endpoints are disabled; set RAG_ADMIN_TOKEN to enable them")
+		}
+		supplied := strings.TrimPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(supplied), []byte(token)) != 1 {
+			return fiber.NewError(fiber.StatusUnauthorized, "missing or invalid admin token")
+		}
+		return c.Next()
+	}
+}
@@ -0,0 +1,269 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"cmd/main.go/pkg/rag"
+
+	fastws "github.com/fasthttp/websocket"
+	"github.com/gofiber/contrib/websocket"
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	// maxChatHistory is how many earlier turns of a chat socket session are quoted in each prompt.
+	maxChatHistory = 6
+	// chatSocketIdleTimeout closes a chat socket that sends nothing, pings included, for this long.
+	chatSocketIdleTimeout = 10 * time.Minute
+	// chatSocketQueue is how many questions may wait while one is being answered.
+	chatSocketQueue = 4
+	// maxChatMessageBytes caps a client message.
+	maxChatMessageBytes = 64 << 10
+	// chatSocketWriteTimeout bounds a single write to a slow client.
+	chatSocketWriteTimeout = 10 * time.Second
+)
+
+// chatSocketRequest is a message from a chat socket client. Type "question" (the default) asks
+// Question; "reset" forgets the session's history.
+type chatSocketRequest struct {
+	Type     string `json:"type"`
+	Question string `json:"question"`
+	TopK     int    `json:"topK"`
+}
+
+// chatSocketHandler upgrades GET /ws/rag to a WebSocket chat session. Each question is answered
+// with "token" messages carrying the answer as it is generated, then a "sources" message with
+// the final answer and its sources; failures send an "error" message and keep the session open.
+// Earlier turns of the session are passed to the model as conversation history. Each question
+// holds one of slots while it is answered. Browser handshakes must come from the same origin or
+// one listed in RAG_WS_ALLOWED_ORIGINS.
+func chatSocketHandler(ragService *rag.Service, metrics *metrics, slots querySlots) fiber.Handler {
+	allowedOrigins := splitOrigins(os.Getenv("RAG_WS_ALLOWED_ORIGINS"))
+	return func(c *fiber.Ctx) error {
+		if ragService == nil {
+			return fiber.NewError(fiber.StatusServiceUnavailable, "RAG service is not configured; run the ingestion workflow first.")
+		}
+		if !websocket.IsWebSocketUpgrade(c) {
+			c.Set(fiber.HeaderUpgrade, "websocket")
+			return fiber.NewError(fiber.StatusUpgradeRequired, "expected a WebSocket upgrade request")
+		}
+		if !originAllowed(c, allowedOrigins) {
+			return fiber.NewError(fiber.StatusForbidden, "WebSocket origin not allowed; add it to RAG_WS_ALLOWED_ORIGINS")
+		}
+
+		requestID := requestIDFrom(c)
+		return websocket.New(func(conn *websocket.Conn) {
+			session := &chatSession{service: ragService, metrics: metrics, slots: slots, ws: newChatConn(conn), requestID: requestID}
+			session.run(rag.WithRequestID(context.Background(), requestID))
+		})(c)
+	}
+}
+
+// splitOrigins parses a comma-separated origin list, dropping blanks and trailing slashes.
+func splitOrigins(list string) []string {
+	var origins []string
+	for _, origin := range strings.Split(list, ",") {
+		if origin = strings.TrimSuffix(strings.TrimSpace(origin), "/"); origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+	return origins
+}
+
+// originAllowed reports whether a WebSocket handshake may proceed. Requests without an Origin
+// header come from non-browser clients and are allowed. Browsers must be on the server's own host
+// or send an origin listed in allowed, where "*" admits any.
+func originAllowed(c *fiber.Ctx, allowed []string) bool {
+	origin := c.Get(fiber.HeaderOrigin)
+	if origin == "" {
+		return true
+	}
+	for _, candidate := range allowed {
+		if candidate == "*" || strings.EqualFold(candidate, origin) {
+			return true
+		}
+	}
+	u, err := url.Parse(origin)
+	return err == nil && u.Host != "" && strings.EqualFold(u.Host, c.Hostname())
+}
+
+// chatSession serves one chat socket connection.
+type chatSession struct {
+	service   *rag.Service
+	metrics   *metrics
+	slots     querySlots
+	ws        *chatConn
+	requestID string
+	history   []rag.ChatTurn
+}
+
+// chatConn wraps a WebSocket connection for a chat session. Reads must come from one goroutine;
+// writes may come from any.
+type chatConn struct {
+	conn *websocket.Conn
+	mu   sync.Mutex
+}
+
+// newChatConn caps message size and makes pings, not only messages, keep the connection alive.
+func newChatConn(conn *websocket.Conn) *chatConn {
+	conn.SetReadLimit(maxChatMessageBytes)
+	conn.SetPingHandler(func(data string) error {
+		_ = conn.SetReadDeadline(time.Now().Add(chatSocketIdleTimeout))
+		return conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(chatSocketWriteTimeout))
+	})
+	return &chatConn{conn: conn}
+}
+
+// readMessage returns the next text message. A binary message fails with a close error.
+func (ws *chatConn) readMessage() ([]byte, error) {
+	if err := ws.conn.SetReadDeadline(time.Now().Add(chatSocketIdleTimeout)); err != nil {
+		return nil, err
+	}
+	kind, message, err := ws.conn.ReadMessage()
+	if err != nil {
+		return nil, err
+	}
+	if kind != websocket.TextMessage {
+		return nil, &fastws.CloseError{Code: websocket.CloseUnsupportedData, Text: "binary messages are not supported"}
+	}
+	return message, nil
+}
+
+func (ws *chatConn) writeJSON(v any) error {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	if err := ws.conn.SetWriteDeadline(time.Now().Add(chatSocketWriteTimeout)); err != nil {
+		return err
+	}
+	return ws.conn.WriteJSON(v)
+}
+
+// close sends the close frame for the read error that ended the session and closes the
+// connection: the client's own code echoed back, 1009 for an oversized message, or going away
+// for a dropped connection.
+func (ws *chatConn) close(readErr error) {
+	code, reason := websocket.CloseGoingAway, ""
+	var closeErr *fastws.CloseError
+	switch {
+	case errors.As(readErr, &closeErr):
+		code, reason = closeErr.Code, closeErr.Text
+		if code == websocket.CloseNormalClosure || code == websocket.CloseGoingAway || code == websocket.CloseNoStatusReceived {
+			code, reason = websocket.CloseNormalClosure, ""
+		}
+	case errors.Is(readErr, fastws.ErrReadLimit):
+		code, reason = websocket.CloseMessageTooBig, "message too large"
+	}
+	_ = ws.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(chatSocketWriteTimeout))
+	_ = ws.conn.Close()
+}
+
+// run answers questions in order until the client disconnects. A disconnect cancels the answer
+// in progress.
+func (s *chatSession) run(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	requests := make(chan chatSocketRequest, chatSocketQueue)
+	var readErr error
+	go func() {
+		defer cancel()
+		for {
+			message, err := s.ws.readMessage()
+			if err != nil {
+				readErr = err
+				return
+			}
+			var request chatSocketRequest
+			if err := json.Unmarshal(message, &request); err != nil {
+				s.sendError("invalid message: expected JSON with a question")
+				continue
+			}
+			select {
+			case requests <- request:
+			default:
+				s.sendError("too many pending questions; wait for the current answer")
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			s.ws.close(readErr)
+			return
+		case request := <-requests:
+			s.handle(ctx, request)
+		}
+	}
+}
+
+// handle serves one client request.
+func (s *chatSession) handle(ctx context.Context, request chatSocketRequest) {
+	switch request.Type {
+	case "reset":
+		s.history = nil
+		_ = s.ws.writeJSON(fiber.Map{"type": "reset"})
+		return
+	case "", "question":
+	default:
+		s.sendError("unknown message type " + request.Type)
+		return
+	}
+	v := &validator{}
+	v.question("question", request.Question)
+	v.topK(request.TopK)
+	if v.failed() {
+		_ = s.ws.writeJSON(fiber.Map{"type": "error", "error": v.summary(), "fields": v.errors, "requestId": s.requestID})
+		return
+	}
+
+	if !s.slots.tryAcquire() {
+		_ = s.ws.writeJSON(fiber.Map{"type": "error", "error": errTooManyQueries.Message, "retryAfter": queryRetryAfter, "requestId": s.requestID})
+		return
+	}
+	defer s.slots.release()
+
+	ctx, cancel := context.WithTimeout(ctx, s.service.QueryTimeout())
+	defer cancel()
+	started := time.Now()
+	opts := rag.QueryOptions{TopK: request.TopK, History: s.history}
+	answer, err := s.service.AnswerStream(ctx, request.Question, opts, func(delta string) error {
+		return s.ws.writeJSON(fiber.Map{"type": "token", "text": delta})
+	})
+	s.metrics.countQuery("websocket", err)
+	if err != nil {
+		s.sendError(err.Error())
+		return
+	}
+
+	topK := request.TopK
+	if topK <= 0 {
+		topK = s.service.DefaultTopK()
+	}
+	questionID := logQuery(s.requestID, request.Question, topK, answer, time.Since(started))
+	_ = s.ws.writeJSON(fiber.Map{
+		"type":       "sources",
+		"answer":     answer.Answer,
+		"answered":   answer.Answered,
+		"sources":    answer.Sources,
+		"questionId": questionID,
+		"usage":      answer.Usage,
+	})
+
+	s.history = append(s.history, rag.ChatTurn{Question: strings.TrimSpace(request.Question), Answer: answer.Answer})
+	if len(s.history) > maxChatHistory {
+		s.history = s.history[len(s.history)-maxChatHistory:]
+	}
+}
+
+// sendError reports a failed request to the client; the session stays open.
+func (s *chatSession) sendError(message string) {
+	_ = s.ws.writeJSON(fiber.Map{"type": "error", "error": message, "requestId": s.requestID})
+}
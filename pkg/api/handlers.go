@@ -1,3 +1,385 @@
 package api
 
 // API handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cmd/main.go/pkg/rag"
+	"cmd/main.go/pkg/repositories"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// reingestTimeout bounds a background rebuild, which outlives the triggering request.
+const reingestTimeout = 30 * time.Minute
+
+// reingestStatus is the JSON view of the most recent reingest job.
+type reingestStatus struct {
+	State      string           `json:"state"`
+	StartedAt  *time.Time       `json:"startedAt,omitempty"`
+	FinishedAt *time.Time       `json:"finishedAt,omitempty"`
+	Stats      *rag.IngestStats `json:"stats,omitempty"`
+	Error      string           `json:"error,omitempty"`
+}
+
+// reingestJob runs at most one index rebuild at a time.
+type reingestJob struct {
+	mu      sync.Mutex
+	status  reingestStatus
+	metrics *metrics
+}
+
+func newReingestJob(m *metrics) *reingestJob {
+	return &reingestJob{status: reingestStatus{State: "idle"}, metrics: m}
+}
+
+// start launches a rebuild unless one is already running.
+func (j *reingestJob) start(service *rag.Service) (reingestStatus, bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.status.State == "running" {
+		return j.status, false
+	}
+	now := time.Now().UTC()
+	j.status = reingestStatus{State: "running", StartedAt: &now}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), reingestTimeout)
+		defer cancel()
+		stats, err := service.Reingest(ctx, rag.DefaultSourceOptions(""), rag.ChunkOptions{Size: rag.DefaultChunkSize, Overlap: rag.DefaultChunkOverlap})
+		j.metrics.countReingest(err)
+
+		j.mu.Lock()
+		defer j.mu.Unlock()
+		finished := time.Now().UTC()
+		j.status.FinishedAt = &finished
+		if err != nil {
+			log.Printf("rag reingest failed: %v", err)
+			j.status.State = "failed"
+			j.status.Error = err.Error()
+			return
+		}
+		j.status.State = "completed"
+		j.status.Stats = &stats
+	}()
+	return j.status, true
+}
+
+func (j *reingestJob) snapshot() reingestStatus {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.status
+}
+
+func reingestHandler(ragService *rag.Service, job *reingestJob) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if ragService == nil {
+			return fiber.NewError(fiber.StatusServiceUnavailable, "RAG service is not configured; run the ingestion workflow first.")
+		}
+		status, started := job.start(ragService)
+		if !started {
+			return c.Status(fiber.StatusConflict).JSON(status)
+		}
+		return c.Status(fiber.StatusAccepted).JSON(status)
+	}
+}
+
+func reingestStatusHandler(job *reingestJob) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		return c.JSON(job.snapshot())
+	}
+}
+
+// addSourceTimeout bounds chunking and embedding one added source.
+const addSourceTimeout = 2 * time.Minute
+
+// Length caps, in characters, for the title and uri of an added source.
+const (
+	maxSourceTitleLength = 500
+	maxSourceURILength   = 2048
+)
+
+// addSourceHandler indexes one document from the request body. It answers 201 for a new
+// document and 200 when upsert replaced an existing one.
+func addSourceHandler(ragService *rag.Service, m *metrics) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if ragService == nil {
+			return fiber.NewError(fiber.StatusServiceUnavailable, "RAG service is not configured; run the ingestion workflow first.")
+		}
+
+		var request struct {
+			Title   string `json:"title"`
+			URI     string `json:"uri"`
+			Content string `json:"content"`
+			Upsert  bool   `json:"upsert"`
+		}
+		if err := c.BodyParser(&request); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid request payload")
+		}
+		v := &validator{}
+		if strings.TrimSpace(request.Title) == "" && strings.TrimSpace(request.URI) == "" {
+			v.fail("title", "is required when uri is empty")
+		}
+		v.maxLength("title", request.Title, maxSourceTitleLength)
+		v.maxLength("uri", request.URI, maxSourceURILength)
+		v.required("content", request.Content)
+		if v.failed() {
+			return v.respond(c)
+		}
+
+		ctx := c.UserContext()
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		ctx, cancel := context.WithTimeout(ctx, addSourceTimeout)
+		defer cancel()
+
+		result, err := ragService.AddSource(ctx, rag.NewSource{Title: request.Title, URI: request.URI, Content: request.Content},
+			rag.ChunkOptions{Size: rag.DefaultChunkSize, Overlap: rag.DefaultChunkOverlap}, request.Upsert)
+		m.countAddSource(result, err)
+		switch {
+		case errors.Is(err, rag.ErrSourceExists), errors.Is(err, rag.ErrDuplicateSource), errors.Is(err, rag.ErrDimensionMismatch):
+			return fiber.NewError(fiber.StatusConflict, err.Error())
+		case errors.Is(err, rag.ErrAddSourceUnsupported):
+			return fiber.NewError(fiber.StatusNotImplemented, err.Error())
+		case errors.Is(err, rag.ErrUpstream):
+			return fiber.NewError(fiber.StatusBadGateway, err.Error())
+		case err != nil:
+			log.Printf("rag add source: %v", err)
+			return fiber.NewError(fiber.StatusInternalServerError, "adding the source failed")
+		}
+		status := fiber.StatusCreated
+		if result.Updated {
+			status = fiber.StatusOK
+		}
+		return c.Status(status).JSON(result)
+	}
+}
+
+// clearHandler empties the index. The body must set confirm to true so a stray request cannot
+// wipe it.
+func clearHandler(ragService *rag.Service) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if ragService == nil {
+			return fiber.NewError(fiber.StatusServiceUnavailable, "RAG service is not configured; run the ingestion workflow first.")
+		}
+
+		var request struct {
+			Confirm bool `json:"confirm"`
+		}
+		if err := c.BodyParser(&request); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid request payload")
+		}
+		v := &validator{}
+		if !request.Confirm {
+			v.fail("confirm", "must be true to clear the index")
+		}
+		if v.failed() {
+			return v.respond(c)
+		}
+
+		removed, err := ragService.Clear()
+		switch {
+		case errors.Is(err, rag.ErrClearUnsupported):
+			return fiber.NewError(fiber.StatusNotImplemented, err.Error())
+		case err != nil:
+			log.Printf("rag clear: %v", err)
+			return fiber.NewError(fiber.StatusInternalServerError, "clearing the index failed")
+		}
+		log.Printf("rag clear: removed %d chunks", removed)
+		return c.JSON(fiber.Map{"removedChunks": removed})
+	}
+}
+
+// configHandler reports the running service's non-secret configuration. When the service failed
+// to load it reports the environment configuration instead, with loaded set to false.
+func configHandler(ragService *rag.Service) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if ragService == nil {
+			return c.JSON(fiber.Map{"loaded": false, "config": rag.LoadServiceConfigFromEnv().Public()})
+		}
+		return c.JSON(fiber.Map{"loaded": true, "config": ragService.Config()})
+	}
+}
+
+// feedbackHandler stores a thumbs up/down rating for a logged query.
+func feedbackHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if repositories.DB == nil {
+			return fiber.NewError(fiber.StatusServiceUnavailable, "feedback storage is unavailable; the database is not connected.")
+		}
+
+		var request struct {
+			QuestionID uint   `json:"questionId"`
+			Rating     string `json:"rating"`
+			Comment    string `json:"comment"`
+		}
+		if err := c.BodyParser(&request); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid request payload")
+		}
+		if request.QuestionID == 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "questionId is required")
+		}
+		rating := strings.ToLower(strings.TrimSpace(request.Rating))
+		if rating != repositories.RatingUp && rating != repositories.RatingDown {
+			return fiber.NewError(fiber.StatusBadRequest, `rating must be "up" or "down"`)
+		}
+
+		entry := repositories.Feedback{
+			QueryLogID: request.QuestionID,
+			Rating:     rating,
+			Comment:    strings.TrimSpace(request.Comment),
+		}
+		if err := repositories.CreateFeedback(repositories.DB, &entry); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fiber.NewError(fiber.StatusNotFound, "unknown questionId")
+			}
+			log.Printf("store rag feedback: %v", err)
+			return fiber.NewError(fiber.StatusServiceUnavailable, "feedback storage is unavailable; try again later.")
+		}
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+}
+
+// maxBatchQuestions caps how many questions one batch request may ask.
+const maxBatchQuestions = 50
+
+// batchQueryHandler answers several questions at once, reporting failures per question.
+func batchQueryHandler(ragService *rag.Service, m *metrics) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if ragService == nil {
+			return fiber.NewError(fiber.StatusServiceUnavailable, "RAG service is not configured; run the ingestion workflow first.")
+		}
+
+		var request struct {
+			Questions []string `json:"questions"`
+			TopK      int      `json:"topK"`
+		}
+		if err := c.BodyParser(&request); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid request payload")
+		}
+		v := &validator{}
+		if len(request.Questions) == 0 {
+			v.fail("questions", "is required")
+		}
+		if len(request.Questions) > maxBatchQuestions {
+			v.fail("questions", "allows at most %d questions per batch", maxBatchQuestions)
+		}
+		// Blank questions fail individually in the answers rather than failing the batch.
+		for i, question := range request.Questions {
+			v.maxLength(fmt.Sprintf("questions[%d]", i), question, maxQuestionLength)
+		}
+		v.topK(request.TopK)
+		if v.failed() {
+			return v.respond(c)
+		}
+
+		ctx := c.UserContext()
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		rounds := (len(request.Questions) + rag.DefaultBatchConcurrency - 1) / rag.DefaultBatchConcurrency
+		ctx, cancel := context.WithTimeout(ctx, ragService.QueryTimeout()*time.Duration(rounds))
+		defer cancel()
+
+		answers, err := ragService.AnswerBatch(ctx, request.Questions, rag.QueryOptions{TopK: request.TopK})
+		m.countQuery("batch", err)
+		if err != nil {
+			return queryError(err)
+		}
+		return c.JSON(fiber.Map{"answers": answers})
+	}
+}
+
+// maxSearchTopK bounds the topK query parameter of the search endpoint.
+const maxSearchTopK = 50
+
+// searchHandler serves GET /api/rag/search?q=...&source=...&topK=..., returning ranked chunks
+// without generation. source may be repeated or comma-separated.
+func searchHandler(ragService *rag.Service, m *metrics) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if ragService == nil {
+			return fiber.NewError(fiber.StatusServiceUnavailable, "RAG service is not configured; run the ingestion workflow first.")
+		}
+
+		query := strings.TrimSpace(c.Query("q"))
+		if query == "" {
+			return fiber.NewError(fiber.StatusBadRequest, "q is required")
+		}
+		topK := ragService.DefaultTopK()
+		if raw := c.Query("topK"); raw != "" {
+			parsed, err := strconv.Atoi(raw)
+			if err != nil || parsed < 1 || parsed > maxSearchTopK {
+				return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("topK must be between 1 and %d", maxSearchTopK))
+			}
+			topK = parsed
+		}
+		var sources []string
+		for _, raw := range c.Context().QueryArgs().PeekMulti("source") {
+			for _, source := range strings.Split(string(raw), ",") {
+				if source = strings.TrimSpace(source); source != "" {
+					sources = append(sources, source)
+				}
+			}
+		}
+
+		ctx := c.UserContext()
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		ctx, cancel := context.WithTimeout(ctx, 20*time.Second)
+		defer cancel()
+
+		matches, err := ragService.Retrieve(ctx, query, rag.QueryOptions{TopK: topK, Sources: sources})
+		m.countQuery("search", err)
+		if err != nil {
+			return queryError(err)
+		}
+		c.Set(fiber.HeaderCacheControl, "public, max-age=60")
+		return c.JSON(fiber.Map{"chunks": rag.RetrievedChunks(matches)})
+	}
+}
+
+// similarHandler serves GET /api/rag/similar/:id?topK=..., returning the chunks most similar to a
+// stored chunk, itself excluded. Unknown IDs get 404.
+func similarHandler(ragService *rag.Service, m *metrics) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if ragService == nil {
+			return fiber.NewError(fiber.StatusServiceUnavailable, "RAG service is not configured; run the ingestion workflow first.")
+		}
+
+		topK := ragService.DefaultTopK()
+		if raw := c.Query("topK"); raw != "" {
+			parsed, err := strconv.Atoi(raw)
+			if err != nil || parsed < 1 || parsed > maxSearchTopK {
+				return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("topK must be between 1 and %d", maxSearchTopK))
+			}
+			topK = parsed
+		}
+
+		id, err := url.PathUnescape(c.Params("id"))
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid chunk id")
+		}
+		matches, err := ragService.SimilarTo(id, topK)
+		m.countQuery("similar", err)
+		if errors.Is(err, rag.ErrChunkNotFound) {
+			return fiber.NewError(fiber.StatusNotFound, err.Error())
+		}
+		if err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+		}
+		c.Set(fiber.HeaderCacheControl, "public, max-age=60")
+		return c.JSON(fiber.Map{"chunks": rag.RetrievedChunks(matches)})
+	}
+}
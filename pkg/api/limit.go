@@ -0,0 +1,57 @@
+package api
+
+import (
+	"cmd/main.go/pkg/rag"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// queryRetryAfter is the Retry-After hint, in seconds, sent when every query slot is taken.
+const queryRetryAfter = "5"
+
+// errTooManyQueries is returned while every query slot is taken.
+var errTooManyQueries = fiber.NewError(fiber.StatusServiceUnavailable, "too many queries in progress; retry shortly")
+
+// querySlots bounds how many queries are answered at once. A nil querySlots is unlimited.
+type querySlots chan struct{}
+
+// newQuerySlots returns slots for the service's RAG_MAX_CONCURRENT_QUERIES limit.
+func newQuerySlots(ragService *rag.Service) querySlots {
+	if ragService == nil || ragService.MaxConcurrentQueries() <= 0 {
+		return nil
+	}
+	return make(querySlots, ragService.MaxConcurrentQueries())
+}
+
+// tryAcquire takes a slot without waiting and reports whether it got one.
+func (s querySlots) tryAcquire() bool {
+	if s == nil {
+		return true
+	}
+	select {
+	case s <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// release frees a slot taken by tryAcquire.
+func (s querySlots) release() {
+	if s != nil {
+		<-s
+	}
+}
+
+// limitQueries holds a query slot for the rest of the request. When none is free it answers 503
+// with Retry-After at once, so a burst is turned away instead of queueing until it times out.
+func limitQueries(slots querySlots) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !slots.tryAcquire() {
+			c.Set(fiber.HeaderRetryAfter, queryRetryAfter)
+			return errTooManyQueries
+		}
+		defer slots.release()
+		return c.Next()
+	}
+}
@@ -0,0 +1,183 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"cmd/main.go/pkg/rag"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// latencyBuckets are the histogram upper bounds in seconds; local models can take a minute.
+var latencyBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+// histogram is a cumulative Prometheus-style histogram.
+type histogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+func (h *histogram) observe(seconds float64) {
+	if h.counts == nil {
+		h.counts = make([]uint64, len(latencyBuckets))
+	}
+	for i, bound := range latencyBuckets {
+		if seconds <= bound {
+			h.counts[i]++
+		}
+	}
+	h.sum += seconds
+	h.count++
+}
+
+// metrics is a minimal registry rendered in the Prometheus text format by GET /metrics.
+type metrics struct {
+	mu       sync.Mutex
+	queries  map[string]uint64
+	errors   map[string]uint64
+	stages   map[string]*histogram
+	reingest map[string]uint64
+	sources  map[string]uint64
+	// chunks reports the current index size; nil when the RAG service is disabled.
+	chunks func() int
+}
+
+func newMetrics(ragService *rag.Service) *metrics {
+	m := &metrics{
+		queries:  map[string]uint64{},
+		errors:   map[string]uint64{},
+		stages:   map[string]*histogram{rag.StageRetrieval: {}, rag.StageGeneration: {}},
+		reingest: map[string]uint64{},
+		sources:  map[string]uint64{},
+	}
+	if ragService != nil {
+		m.chunks = ragService.ChunkCount
+		ragService.SetStageObserver(m.observeStage)
+	}
+	return m
+}
+
+// countQuery records a request to a query endpoint and, when err is set, its error type.
+func (m *metrics) countQuery(endpoint string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.queries[endpoint]++
+	if err != nil {
+		m.errors[queryErrorType(err)]++
+	}
+}
+
+func (m *metrics) observeStage(stage string, elapsed time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	h, ok := m.stages[stage]
+	if !ok {
+		h = &histogram{}
+		m.stages[stage] = h
+	}
+	h.observe(elapsed.Seconds())
+}
+
+func (m *metrics) countReingest(err error) {
+	result := "completed"
+	if err != nil {
+		result = "failed"
+	}
+	m.mu.Lock()
+	m.reingest[result]++
+	m.mu.Unlock()
+}
+
+// countAddSource records a finished POST /api/rag/sources as inserted, updated, or failed.
+func (m *metrics) countAddSource(result rag.AddSourceResult, err error) {
+	outcome := "inserted"
+	switch {
+	case err != nil:
+		outcome = "failed"
+	case result.Updated:
+		outcome = "updated"
+	}
+	m.mu.Lock()
+	m.sources[outcome]++
+	m.mu.Unlock()
+}
+
+// queryErrorType names the error label, mirroring the status mapping in queryError.
+func queryErrorType(err error) string {
+	switch {
+	case errors.Is(err, rag.ErrEmptyQuestion):
+		return "empty_question"
+	case errors.Is(err, rag.ErrNoContext):
+		return "no_context"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, rag.ErrUpstream):
+		return "upstream"
+	default:
+		return "internal"
+	}
+}
+
+// render writes every metric in the Prometheus text exposition format.
+func (m *metrics) render() string {
+	chunks := -1
+	if m.chunks != nil {
+		chunks = m.chunks()
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var b strings.Builder
+	writeCounter(&b, "rag_queries_total", "Requests to the RAG query endpoints.", "endpoint", m.queries)
+	writeCounter(&b, "rag_query_errors_total", "Failed RAG queries by error type.", "type", m.errors)
+	writeCounter(&b, "rag_reingest_runs_total", "Finished index rebuilds by result.", "result", m.reingest)
+	writeCounter(&b, "rag_add_source_total", "Sources added through the API by result.", "result", m.sources)
+	for _, stage := range sortedKeys(m.stages) {
+		h := m.stages[stage]
+		name := "rag_" + stage + "_duration_seconds"
+		fmt.Fprintf(&b, "# HELP %s Latency of the %s stage of a RAG query.\n# TYPE %s histogram\n", name, stage, name)
+		for i, bound := range latencyBuckets {
+			var count uint64
+			if h.counts != nil {
+				count = h.counts[i]
+			}
+			fmt.Fprintf(&b, "%s_bucket{le=\"%g\"} %d\n", name, bound, count)
+		}
+		fmt.Fprintf(&b, "%s_bucket{le=\"+Inf\"} %d\n%s_sum %g\n%s_count %d\n", name, h.count, name, h.sum, name, h.count)
+	}
+	if chunks >= 0 {
+		fmt.Fprintf(&b, "# HELP rag_index_chunks Chunks in the active index.\n# TYPE rag_index_chunks gauge\nrag_index_chunks %d\n", chunks)
+	}
+	return b.String()
+}
+
+func writeCounter(b *strings.Builder, name, help, label string, values map[string]uint64) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s counter\n", name, help, name)
+	for _, key := range sortedKeys(values) {
+		fmt.Fprintf(b, "%s{%s=%q} %d\n", name, label, key, values[key])
+	}
+}
+
+func sortedKeys[V any](values map[string]V) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// metricsHandler serves GET /metrics.
+func metricsHandler(m *metrics) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		c.Set(fiber.HeaderContentType, "text/plain; version=0.0.4; charset=utf-8")
+		return c.SendString(m.render())
+	}
+}
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"cmd/main.go/pkg/rag"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// maxRequestIDLength bounds client-supplied X-Request-ID values.
+const maxRequestIDLength = 128
+
+// RequestID assigns each request an ID, taken from a valid X-Request-ID header or generated. The ID
+// is stored on the user context for rag.RequestIDFrom, echoed in the X-Request-ID response header,
+// and appended to error messages so clients can quote it in support tickets.
+func RequestID() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id := c.Get(fiber.HeaderXRequestID)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		c.Set(fiber.HeaderXRequestID, id)
+		c.Locals("requestId", id)
+		c.SetUserContext(rag.WithRequestID(c.UserContext(), id))
+
+		err := c.Next()
+		var fiberErr *fiber.Error
+		if errors.As(err, &fiberErr) {
+			return fiber.NewError(fiberErr.Code, fmt.Sprintf("%s (request %s)", fiberErr.Message, id))
+		}
+		if err != nil {
+			return fmt.Errorf("%w (request %s)", err, id)
+		}
+		return nil
+	}
+}
+
+// requestIDFrom returns the ID assigned by RequestID, or "" when the middleware did not run.
+func requestIDFrom(c *fiber.Ctx) string {
+	id, _ := c.Locals("requestId").(string)
+	return id
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range id {
+		if r < '!' || r > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b[:])
+}
@@ -2,7 +2,10 @@ package api
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
+	"strings"
 	"time"
 
 	"cmd/main.go/pkg/rag"
@@ -11,6 +14,18 @@ import (
 	"github.com/gofiber/fiber/v2"
 )
 
+// maxSystemPromptLength caps per-request system prompt overrides.
+const maxSystemPromptLength = 4000
+
+// maxQuestionLength caps a question, and each query variant, in characters.
+const maxQuestionLength = 4000
+
+// maxQueryTopK bounds the topK and maxPerSource fields of a query.
+const maxQueryTopK = 100
+
+// maxHighlightMarkerLength caps the marker placed around highlighted snippet terms.
+const maxHighlightMarkerLength = 10
+
 // HeaderLinks represents the structure of header links
 type HeaderLinks struct {
 	Login   string
@@ -26,6 +41,10 @@ func SetupRoutes(app *fiber.App, ragService *rag.Service) {
 	app.Static("/assets", "../web/assets/")
 
 	headerLinks := headerLinks()
+	metrics := newMetrics(ragService)
+	slots := newQuerySlots(ragService)
+	app.Use("/api/rag", RequestID())
+	app.Use("/ws", RequestID())
 
 	// Home Page
 	app.Get("/", func(c *fiber.Ctx) error {
@@ -96,7 +115,16 @@ func SetupRoutes(app *fiber.App, ragService *rag.Service) {
 		})
 	})
 
-	app.Post("/api/rag/query", func(c *fiber.Ctx) error {
+	// Generating endpoints share the RAG_MAX_CONCURRENT_QUERIES slots.
+	app.Post("/api/rag/query", limitQueries(slots), queryHandler(ragService, metrics, false))
+	app.Post("/api/rag/query/debug", AdminAuth(), limitQueries(slots), queryHandler(ragService, metrics, true))
+
+	app.Post("/api/rag/query/batch", limitQueries(slots), batchQueryHandler(ragService, metrics))
+
+	// Interactive chat over a WebSocket: streamed answers with per-connection history.
+	app.Get("/ws/rag", chatSocketHandler(ragService, metrics, slots))
+
+	app.Post("/api/rag/retrieve", func(c *fiber.Ctx) error {
 		if ragService == nil {
 			return fiber.NewError(fiber.StatusServiceUnavailable, "RAG service is not configured; run the ingestion workflow first.")
 		}
@@ -108,21 +136,196 @@ func SetupRoutes(app *fiber.App, ragService *rag.Service) {
 		if err := c.BodyParser(&request); err != nil {
 			return fiber.NewError(fiber.StatusBadRequest, "invalid request payload")
 		}
+		v := &validator{}
+		v.question("question", request.Question)
+		v.topK(request.TopK)
+		if v.failed() {
+			return v.respond(c)
+		}
 
 		ctx := c.UserContext()
 		if ctx == nil {
 			ctx = context.Background()
 		}
-		ctx, cancel := context.WithTimeout(ctx, 45*time.Second)
+		ctx, cancel := context.WithTimeout(ctx, 20*time.Second)
 		defer cancel()
 
-		answer, err := ragService.Answer(ctx, request.Question, rag.QueryOptions{TopK: request.TopK})
+		matches, err := ragService.Retrieve(ctx, request.Question, rag.QueryOptions{TopK: request.TopK})
+		metrics.countQuery("retrieve", err)
 		if err != nil {
-			return fiber.NewError(fiber.StatusBadGateway, err.Error())
+			return queryError(err)
 		}
 
-		return c.JSON(answer)
+		return c.JSON(fiber.Map{"chunks": rag.RetrievedChunks(matches)})
 	})
+
+	app.Post("/api/rag/feedback", feedbackHandler())
+
+	app.Get("/api/rag/search", searchHandler(ragService, metrics))
+	app.Get("/api/rag/similar/:id", similarHandler(ragService, metrics))
+
+	reingest := newReingestJob(metrics)
+	app.Post("/api/rag/reingest", AdminAuth(), reingestHandler(ragService, reingest))
+	app.Get("/api/rag/reingest", AdminAuth(), reingestStatusHandler(reingest))
+	app.Post("/api/rag/sources", AdminAuth(), addSourceHandler(ragService, metrics))
+	app.Post("/api/rag/clear", AdminAuth(), clearHandler(ragService))
+	app.Get("/api/rag/config", AdminAuth(), configHandler(ragService))
+
+	app.Get("/metrics", metricsHandler(metrics))
+}
+
+// queryHandler answers a question. With returnPrompt, the rendered prompt is included in the
+// response as debugPrompt; that variant is only mounted behind AdminAuth.
+func queryHandler(ragService *rag.Service, metrics *metrics, returnPrompt bool) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if ragService == nil {
+			return fiber.NewError(fiber.StatusServiceUnavailable, "RAG service is not configured; run the ingestion workflow first.")
+		}
+
+		var request struct {
+			Question          string             `json:"question"`
+			TopK              int                `json:"topK"`
+			SourcePriority    map[string]float64 `json:"sourcePriority"`
+			DedupeSources     bool               `json:"dedupeSources"`
+			SystemPrompt      string             `json:"systemPrompt"`
+			IncludeFullText   bool               `json:"includeFullText"`
+			FallbackToSources bool               `json:"fallbackToSources"`
+			QueryVariants     []string           `json:"queryVariants"`
+			AutoVariants      int                `json:"autoVariants"`
+			ContextOrder      string             `json:"contextOrder"`
+			Highlight         bool               `json:"highlight"`
+			HighlightMarker   string             `json:"highlightMarker"`
+			Embedding         []float32          `json:"embedding"`
+			Verbosity         string             `json:"verbosity"`
+			MaxPerSource      int                `json:"maxPerSource"`
+			Temperature       *float32           `json:"temperature"`
+			ContextWindow     int                `json:"contextWindow"`
+		}
+		if err := c.BodyParser(&request); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid request payload")
+		}
+		v := &validator{}
+		v.question("question", request.Question)
+		v.topK(request.TopK)
+		v.maxLength("systemPrompt", request.SystemPrompt, maxSystemPromptLength)
+		v.intRange("autoVariants", request.AutoVariants, 0, rag.MaxAutoVariants)
+		if len(request.QueryVariants) > rag.MaxAutoVariants {
+			v.fail("queryVariants", "allows at most %d phrasings", rag.MaxAutoVariants)
+		}
+		for i, variant := range request.QueryVariants {
+			v.maxLength(fmt.Sprintf("queryVariants[%d]", i), variant, maxQuestionLength)
+		}
+		if len(request.Embedding) > 0 && (len(request.QueryVariants) > 0 || request.AutoVariants > 0) {
+			v.fail("embedding", "cannot be combined with queryVariants or autoVariants")
+		}
+		for _, source := range sortedKeys(request.SourcePriority) {
+			if request.SourcePriority[source] < 0 {
+				v.fail("sourcePriority."+source, "must not be negative")
+			}
+		}
+		v.intRange("maxPerSource", request.MaxPerSource, 0, maxQueryTopK)
+		if t := request.Temperature; t != nil && (*t < 0 || *t > rag.MaxTemperature) {
+			v.fail("temperature", "must be between 0 and %g", rag.MaxTemperature)
+		}
+		v.intRange("contextWindow", request.ContextWindow, 0, rag.MaxContextWindow)
+		v.maxLength("highlightMarker", request.HighlightMarker, maxHighlightMarkerLength)
+		contextOrder, err := rag.ParseContextOrder(request.ContextOrder)
+		v.check("contextOrder", err)
+		verbosity, err := rag.ParseVerbosity(request.Verbosity)
+		v.check("verbosity", err)
+		if v.failed() {
+			return v.respond(c)
+		}
+
+		ctx := c.UserContext()
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		ctx, cancel := context.WithTimeout(ctx, ragService.QueryTimeout())
+		defer cancel()
+
+		started := time.Now()
+		opts := rag.QueryOptions{
+			TopK:              request.TopK,
+			SourcePriority:    request.SourcePriority,
+			DedupeSources:     request.DedupeSources,
+			SystemPrompt:      request.SystemPrompt,
+			IncludeFullText:   request.IncludeFullText,
+			FallbackToSources: request.FallbackToSources,
+			ReturnPrompt:      returnPrompt,
+			QueryVariants:     request.QueryVariants,
+			AutoVariants:      request.AutoVariants,
+			ContextOrder:      contextOrder,
+			HighlightTerms:    request.Highlight,
+			HighlightMarker:   request.HighlightMarker,
+			Verbosity:         verbosity,
+			MaxPerSource:      request.MaxPerSource,
+			Temperature:       request.Temperature,
+			ContextWindow:     request.ContextWindow,
+		}
+		var answer *rag.Answer
+		if len(request.Embedding) > 0 {
+			answer, err = ragService.AnswerWithEmbedding(ctx, request.Question, request.Embedding, opts)
+		} else {
+			answer, err = ragService.Answer(ctx, request.Question, opts)
+		}
+		metrics.countQuery("query", err)
+		if err != nil {
+			return queryError(err)
+		}
+		topK := request.TopK
+		if topK <= 0 {
+			topK = ragService.DefaultTopK()
+		}
+		questionID := logQuery(requestIDFrom(c), request.Question, topK, answer, time.Since(started))
+
+		return c.JSON(queryResponse{Answer: answer, QuestionID: questionID, RequestID: requestIDFrom(c)})
+	}
+}
+
+// queryError maps rag errors to HTTP errors: 400 for a blank question or a supplied embedding of
+// the wrong dimension, 404 when nothing is indexed, and 502 for provider and other failures,
+// including a fallback embedder whose vectors do not fit the index.
+func queryError(err error) error {
+	switch {
+	case errors.Is(err, rag.ErrEmptyQuestion), errors.Is(err, rag.ErrInvalidEmbedding):
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	case errors.Is(err, rag.ErrNoContext):
+		return fiber.NewError(fiber.StatusNotFound, err.Error())
+	default:
+		return fiber.NewError(fiber.StatusBadGateway, err.Error())
+	}
+}
+
+// queryResponse adds the query log ID, used to submit feedback, and the request ID to an answer.
+type queryResponse struct {
+	*rag.Answer
+	QuestionID uint   `json:"questionId,omitempty"`
+	RequestID  string `json:"requestId,omitempty"`
+}
+
+// logQuery records an answered question when a database is connected and returns its ID.
+// Failures only log and return zero.
+func logQuery(requestID, question string, topK int, answer *rag.Answer, latency time.Duration) uint {
+	if repositories.DB == nil {
+		return 0
+	}
+	docIDs := make([]string, 0, len(answer.Sources))
+	for _, src := range answer.Sources {
+		docIDs = append(docIDs, src.DocumentID)
+	}
+	entry := repositories.QueryLog{
+		Question:          strings.TrimSpace(question),
+		TopK:              topK,
+		AnswerLength:      len(answer.Answer),
+		SourceDocumentIDs: docIDs,
+		LatencyMs:         latency.Milliseconds(),
+	}
+	if err := repositories.LogQuery(repositories.DB, &entry); err != nil {
+		log.Printf("[request %s] log rag query: %v", requestID, err)
+		return 0
+	}
+	return entry.ID
 }
 
 func headerLinks() map[string][]HeaderLinks {
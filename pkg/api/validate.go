@@ -0,0 +1,87 @@
+package api
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// fieldError describes one invalid field of a request payload.
+type fieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// validator collects field errors so a request can report every problem at once.
+type validator struct {
+	errors []fieldError
+}
+
+// fail records a field error.
+func (v *validator) fail(field, format string, args ...any) {
+	v.errors = append(v.errors, fieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// required fails field when value is blank.
+func (v *validator) required(field, value string) {
+	if strings.TrimSpace(value) == "" {
+		v.fail(field, "is required")
+	}
+}
+
+// maxLength fails field when value has more than limit characters.
+func (v *validator) maxLength(field, value string, limit int) {
+	if utf8.RuneCountInString(value) > limit {
+		v.fail(field, "must be at most %d characters", limit)
+	}
+}
+
+// intRange fails field when value is outside [lo, hi].
+func (v *validator) intRange(field string, value, lo, hi int) {
+	if value < lo || value > hi {
+		v.fail(field, "must be between %d and %d", lo, hi)
+	}
+}
+
+// question fails field when the question is blank or longer than maxQuestionLength.
+func (v *validator) question(field, value string) {
+	v.required(field, value)
+	v.maxLength(field, value, maxQuestionLength)
+}
+
+// topK fails the topK field when it is negative or above maxQueryTopK.
+func (v *validator) topK(value int) {
+	v.intRange("topK", value, 0, maxQueryTopK)
+}
+
+// check fails field with err's message when err is set.
+func (v *validator) check(field string, err error) {
+	if err != nil {
+		v.fail(field, "is invalid: %v", err)
+	}
+}
+
+// failed reports whether any field error was recorded.
+func (v *validator) failed() bool {
+	return len(v.errors) > 0
+}
+
+// summary joins the collected errors into one readable message.
+func (v *validator) summary() string {
+	messages := make([]string, len(v.errors))
+	for i, e := range v.errors {
+		messages[i] = e.Field + " " + e.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// respond answers 400 with the collected errors: a readable summary in error and the individual
+// problems in fields.
+func (v *validator) respond(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+		"error":  v.summary(),
+		"fields": v.errors,
+	})
+}
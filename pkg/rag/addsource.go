@@ -0,0 +1,186 @@
+package rag
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrSourceExists is returned by AddSource when the document is already indexed and upsert is off.
+var ErrSourceExists = errors.New("source already exists; set upsert to replace it")
+
+// ErrDuplicateSource is returned by AddSource when every chunk nearly duplicates an indexed one.
+var ErrDuplicateSource = errors.New("source duplicates indexed content")
+
+// ErrAddSourceUnsupported is returned when the active store cannot add single documents.
+var ErrAddSourceUnsupported = errors.New("store does not support adding individual sources")
+
+// NewSource describes a document added to a live index without a full reingest.
+type NewSource struct {
+	Title   string
+	URI     string
+	Content string
+}
+
+// AddSourceResult reports what AddSource did.
+type AddSourceResult struct {
+	DocumentID string `json:"documentId"`
+	Chunks     int    `json:"chunks"`
+	// Updated is true when an existing document's chunks were replaced rather than inserted.
+	Updated bool `json:"updated"`
+	// RemovedChunks counts the replaced document's previous chunks.
+	RemovedChunks int `json:"removedChunks,omitempty"`
+	// SkippedDuplicates counts chunks dropped as near-duplicates of other indexed documents.
+	SkippedDuplicates int `json:"skippedDuplicates,omitempty"`
+}
+
+// documentReplacer is implemented by stores that can swap one document's chunks in place.
+type documentReplacer interface {
+	// HasDocument reports whether any chunk belongs to documentID.
+	HasDocument(documentID string) (bool, error)
+	// ReplaceDocument removes documentID's chunks and adds chunks in one step, returning how
+	// many chunks were removed. When allowReplace is false and the document is already indexed
+	// it changes nothing and fails with ErrSourceExists; the check and the insert are atomic.
+	ReplaceDocument(documentID string, chunks []Chunk, allowReplace bool) (int, error)
+}
+
+var (
+	_ documentReplacer = (*VectorStore)(nil)
+	_ documentReplacer = (*PgVectorStore)(nil)
+)
+
+// SourceDocumentID derives the document ID AddSource uses: the slug of the title, or of the URI
+// when the title is blank.
+func SourceDocumentID(title, uri string) string {
+	return Slugify(firstNonEmpty(strings.TrimSpace(title), strings.TrimSpace(uri)))
+}
+
+// AddSource chunks, embeds, and indexes one document. When a document with the same ID is
+// already indexed it fails with ErrSourceExists, unless upsert is set, in which case the old
+// chunks are replaced so re-adding updated content never duplicates it. Chunks nearly identical
+// to another document's, per ServiceConfig.DuplicateThreshold, are skipped; when all are, it
+// fails with ErrDuplicateSource. The JSON index is saved after the change, under the same lock
+// as Clear and Reingest.
+func (s *Service) AddSource(ctx context.Context, src NewSource, chunkOpts ChunkOptions, upsert bool) (AddSourceResult, error) {
+	if s == nil || s.embedder == nil {
+		return AddSourceResult{}, errors.New("rag service is not initialized")
+	}
+	if strings.TrimSpace(src.Title) == "" && strings.TrimSpace(src.URI) == "" {
+		return AddSourceResult{}, errors.New("title or uri is required")
+	}
+	content := NormalizeWhitespace(src.Content, true)
+	if content == "" {
+		return AddSourceResult{}, errors.New("content is required")
+	}
+	replacer, ok := s.currentStore().(documentReplacer)
+	if !ok {
+		return AddSourceResult{}, ErrAddSourceUnsupported
+	}
+
+	doc := Document{
+		ID:        SourceDocumentID(src.Title, src.URI),
+		Title:     firstNonEmpty(strings.TrimSpace(src.Title), strings.TrimSpace(src.URI)),
+		URI:       strings.TrimSpace(src.URI),
+		Source:    "api",
+		Content:   content,
+		FetchedAt: time.Now().UTC(),
+	}
+	if !upsert {
+		// Fail early to skip embedding; ReplaceDocument repeats the check atomically.
+		exists, err := replacer.HasDocument(doc.ID)
+		if err != nil {
+			return AddSourceResult{}, err
+		}
+		if exists {
+			return AddSourceResult{}, fmt.Errorf("%w: %s", ErrSourceExists, doc.ID)
+		}
+	}
+
+	chunks := ChunkDocuments([]Document{doc}, chunkOpts)
+	if err := embedChunks(ctx, chunks, s.embedder, BuildOptions{BatchSize: s.embedBatch, DocumentPrefix: s.docPrefix}); err != nil {
+		return AddSourceResult{}, fmt.Errorf("%w: %w", ErrUpstream, err)
+	}
+
+	// A reingest may have swapped the store while the chunks were embedded; write to the current one.
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	store := s.currentStore()
+	if replacer, ok = store.(documentReplacer); !ok {
+		return AddSourceResult{}, ErrAddSourceUnsupported
+	}
+	chunks, skipped, err := dropNearDuplicates(store, chunks, s.config.DuplicateThreshold, doc.ID)
+	if err != nil {
+		return AddSourceResult{}, err
+	}
+	if len(chunks) == 0 {
+		return AddSourceResult{}, fmt.Errorf("%w: all %d chunks of %s match indexed chunks", ErrDuplicateSource, skipped, doc.ID)
+	}
+	removed, err := replacer.ReplaceDocument(doc.ID, chunks, upsert)
+	if err != nil {
+		return AddSourceResult{}, err
+	}
+	if vs, ok := store.(*VectorStore); ok {
+		vs.putDocument(doc)
+		if s.indexPath != "" {
+			if err := vs.SaveWithBackups(s.indexPath, s.config.IndexBackups); err != nil {
+				return AddSourceResult{}, fmt.Errorf("save vector store: %w", err)
+			}
+		}
+	}
+	return AddSourceResult{DocumentID: doc.ID, Chunks: len(chunks), Updated: removed > 0, RemovedChunks: removed, SkippedDuplicates: skipped}, nil
+}
+
+// HasDocument reports whether any chunk belongs to documentID.
+func (vs *VectorStore) HasDocument(documentID string) (bool, error) {
+	vs.mu.RLock()
+	defer vs.mu.RUnlock()
+	for _, chunk := range vs.Chunks {
+		if chunk.DocumentID == documentID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// ReplaceDocument drops documentID's chunks and appends chunks under one lock, so searches never
+// see the document missing or duplicated. The new chunks must match the remaining chunks'
+// embedding dimension. With allowReplace off an indexed document fails with ErrSourceExists.
+func (vs *VectorStore) ReplaceDocument(documentID string, chunks []Chunk, allowReplace bool) (int, error) {
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+	kept := make([]Chunk, 0, len(vs.Chunks)+len(chunks))
+	var keptNorms []float64
+	for i, chunk := range vs.Chunks {
+		if chunk.DocumentID != documentID {
+			kept = append(kept, chunk)
+			if i < len(vs.norms) {
+				keptNorms = append(keptNorms, vs.norms[i])
+			}
+		}
+	}
+	want := 0
+	if len(kept) > 0 {
+		want = len(kept[0].Embedding)
+	}
+	dim, err := checkChunkDimensions(chunks, want)
+	if err != nil {
+		return 0, err
+	}
+	removed := len(vs.Chunks) - len(kept)
+	if removed > 0 && !allowReplace {
+		return 0, fmt.Errorf("%w: %s", ErrSourceExists, documentID)
+	}
+	vs.Chunks = append(kept, chunks...)
+	if len(keptNorms) == len(kept) {
+		// Keep the cached magnitudes; Search computes the new chunks' on first use.
+		vs.norms = keptNorms
+	} else {
+		vs.norms = nil
+	}
+	vs.Metadata.EmbeddingDim = dim
+	vs.Metadata.ChunkCount = len(vs.Chunks)
+	vs.Metadata.SourceCount = countDocuments(vs.Chunks)
+	return removed, nil
+}
@@ -0,0 +1,68 @@
+package rag
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// DefaultBatchConcurrency is the number of chat completions AnswerBatch runs at once.
+const DefaultBatchConcurrency = 4
+
+// AnswerBatch answers several questions. All questions are embedded in a single Embed call, then
+// searched and answered with at most DefaultBatchConcurrency concurrent completions. The result
+// has one Answer per question, in order; items that fail carry Error instead of failing the batch.
+// An error is returned only when the batch as a whole cannot run, e.g. the embedding call fails.
+func (s *Service) AnswerBatch(ctx context.Context, questions []string, opts QueryOptions) ([]*Answer, error) {
+	if s == nil || s.currentStore() == nil {
+		return nil, errors.New("rag service is not initialized")
+	}
+	answers := make([]*Answer, len(questions))
+	trimmed := make([]string, len(questions))
+	itemOpts := make([]QueryOptions, len(questions))
+	var pending []int
+	for i, question := range questions {
+		q, o, err := s.prepareQuery(question, opts)
+		if err != nil {
+			answers[i] = &Answer{Sources: []SourceAttribution{}, Error: err.Error()}
+			continue
+		}
+		trimmed[i], itemOpts[i] = q, o
+		pending = append(pending, i)
+	}
+	if len(pending) == 0 {
+		return answers, nil
+	}
+
+	texts := make([]string, len(pending))
+	for j, i := range pending {
+		texts[j] = s.queryPrefix + trimmed[i]
+	}
+	embeddings, err := s.embedder.Embed(ctx, texts)
+	if err != nil {
+		return nil, fmt.Errorf("%w: embed batch: %w", ErrUpstream, err)
+	}
+	if len(embeddings) != len(texts) {
+		return nil, fmt.Errorf("embed batch: got %d embeddings for %d questions", len(embeddings), len(texts))
+	}
+
+	sem := make(chan struct{}, DefaultBatchConcurrency)
+	var wg sync.WaitGroup
+	for j, i := range pending {
+		wg.Add(1)
+		go func(i int, embedding []float32) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			answer, err := s.answerEmbedded(ctx, trimmed[i], embedding, itemOpts[i])
+			if err != nil {
+				answer = &Answer{Sources: []SourceAttribution{}, Error: err.Error()}
+			}
+			answers[i] = answer
+		}(i, embeddings[j])
+	}
+	wg.Wait()
+	return answers, nil
+}
@@ -0,0 +1,137 @@
+package rag
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// DefaultBoilerplatePatterns match common navigation and banner lines left by HTML conversion.
+// They are case-insensitive, must match a whole trimmed line, and are only tried on lines of at
+// most boilerplateMaxLineLength characters, so prose that merely contains the words survives.
+var DefaultBoilerplatePatterns = []string{
+	`skip to (main )?content`,
+	`(jump|back) to top`,
+	`(sign|log) ?(in|out)`,
+	`(accept|reject|allow|manage)( all)? cookies`,
+	`cookie (preferences|settings)`,
+	`(this site|we) uses? cookies\.?`,
+	`was this (page|article|topic) helpful\??`,
+	`(toggle|open|close) (navigation|menu|sidebar)`,
+	`(on this page|in this article|table of contents)`,
+	`(share|print)( this page)?`,
+}
+
+const (
+	// boilerplateMinDocuments is how many HTML documents from one host the line-frequency
+	// heuristic needs; with fewer, a repeated line is as likely to be content.
+	boilerplateMinDocuments = 3
+	// boilerplateShare is the fraction of a host's HTML documents a line must appear in to count
+	// as boilerplate.
+	boilerplateShare = 0.5
+	// boilerplateMaxLineLength is the longest line, in characters, the patterns are tried on.
+	boilerplateMaxLineLength = 60
+)
+
+// compileBoilerplatePatterns compiles the default and extra patterns into one whole-line,
+// case-insensitive expression.
+func compileBoilerplatePatterns(extra []string) (*regexp.Regexp, error) {
+	patterns := append(append([]string(nil), DefaultBoilerplatePatterns...), extra...)
+	for _, pattern := range extra {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return nil, fmt.Errorf("boilerplate pattern %q: %w", pattern, err)
+		}
+	}
+	return regexp.Compile(`(?i)^(?:` + strings.Join(patterns, `|`) + `)$`)
+}
+
+// lineShare counts, for the HTML documents of one host, how many contain each trimmed line.
+type lineShare struct {
+	documents int
+	lines     map[string]int
+}
+
+// repeated reports whether line is in at least half of the host's documents, once the host has
+// enough of them for the count to mean anything.
+func (s *lineShare) repeated(line string) bool {
+	if s.documents < boilerplateMinDocuments {
+		return false
+	}
+	return s.lines[line] >= max(2, int(float64(s.documents)*boilerplateShare+0.5))
+}
+
+// boilerplateHost groups a document for line counting: the host of its URI, else its source.
+func boilerplateHost(doc Document) string {
+	if u, err := url.Parse(doc.URI); err == nil && u.Host != "" {
+		return strings.ToLower(u.Host)
+	}
+	return doc.Source
+}
+
+// removeBoilerplate strips navigation and banner lines from the HTML-derived documents: short
+// lines matching blocklist, and, for hosts with enough HTML documents, lines found in at least
+// half of that host's documents, such as menus and footers. Lines are counted per host so one
+// site's chrome never removes another's content. Other documents are untouched. It returns a
+// note with the number of lines removed, or "" when there were none.
+func removeBoilerplate(documents []Document, blocklist *regexp.Regexp) string {
+	htmlDocs := 0
+	shares := map[string]*lineShare{}
+	for _, doc := range documents {
+		if !doc.fromHTML {
+			continue
+		}
+		htmlDocs++
+		host := boilerplateHost(doc)
+		share, ok := shares[host]
+		if !ok {
+			share = &lineShare{lines: map[string]int{}}
+			shares[host] = share
+		}
+		share.documents++
+		seen := map[string]struct{}{}
+		for _, line := range strings.Split(doc.Content, "\n") {
+			line = strings.TrimSpace(line)
+			if _, dup := seen[line]; line == "" || dup {
+				continue
+			}
+			seen[line] = struct{}{}
+			share.lines[line]++
+		}
+	}
+	if htmlDocs == 0 {
+		return ""
+	}
+
+	removed, touched := 0, 0
+	for i := range documents {
+		if !documents[i].fromHTML {
+			continue
+		}
+		share := shares[boilerplateHost(documents[i])]
+		lines := strings.Split(documents[i].Content, "\n")
+		kept := lines[:0]
+		for _, line := range lines {
+			trimmed := strings.TrimSpace(line)
+			if trimmed != "" && (share.repeated(trimmed) || isBoilerplateLine(trimmed, blocklist)) {
+				removed++
+				continue
+			}
+			kept = append(kept, line)
+		}
+		if len(kept) < len(lines) {
+			touched++
+			documents[i].Content = strings.Join(kept, "\n")
+		}
+	}
+	if removed == 0 {
+		return ""
+	}
+	return fmt.Sprintf("boilerplate removal stripped %d lines from %d of %d HTML documents", removed, touched, htmlDocs)
+}
+
+// isBoilerplateLine reports whether a short trimmed line matches blocklist.
+func isBoilerplateLine(line string, blocklist *regexp.Regexp) bool {
+	return utf8.RuneCountInString(line) <= boilerplateMaxLineLength && blocklist.MatchString(line)
+}
@@ -9,48 +9,144 @@ import (
 type ChunkOptions struct {
 	Size    int
 	Overlap int
+	// OverlapRatio, when positive, sets the overlap to this fraction of Size (e.g. 0.15) and
+	// overrides Overlap, so the relative overlap stays fixed while tuning Size.
+	OverlapRatio float64
+	// MinSize merges a trailing chunk shorter than this many runes into the previous chunk.
+	// Zero keeps every chunk.
+	MinSize int
+	// KeepCodeBlocks never splits a fenced code block (``` or ~~~) across chunks, letting a chunk
+	// grow past Size to hold one. Blocks longer than MaxCodeBlock are still split.
+	KeepCodeBlocks bool
+	// MaxCodeBlock is the hard cap, in runes, for a chunk holding a code block; zero uses four
+	// times Size.
+	MaxCodeBlock int
+	// MaxChunks stops chunking once this many chunks exist, keeping them; zero is unlimited.
+	MaxChunks int
 }
 
-// ChunkDocuments splits documents into overlapping windows for embedding.
+// ChunkDocuments splits documents into overlapping windows for embedding. Chunk IDs are
+// "<document ID>-chunk-<index>", so the same document and options always yield the same IDs.
 func ChunkDocuments(docs []Document, opts ChunkOptions) []Chunk {
+	chunks, _ := ChunkDocumentsWithNotes(docs, opts)
+	return chunks
+}
+
+// ChunkDocumentsWithNotes behaves like ChunkDocuments and also reports a note when MaxChunks cut
+// chunking short.
+func ChunkDocumentsWithNotes(docs []Document, opts ChunkOptions) ([]Chunk, []string) {
+	opts = opts.Normalized()
+	chunks := make([]Chunk, 0, len(docs)*4)
+
+	for i, doc := range docs {
+		windows := slidingWindows(doc.Content, opts)
+		lineOf := runeLines(doc)
+		for idx, w := range windows {
+			if opts.MaxChunks > 0 && len(chunks) >= opts.MaxChunks {
+				note := fmt.Sprintf("chunking stopped at the max chunks limit (%d) in %s; %d later documents were skipped",
+					opts.MaxChunks, doc.ID, len(docs)-i-1)
+				return chunks, []string{note}
+			}
+			chunkID := fmt.Sprintf("%s-chunk-%d", doc.ID, idx)
+			startLine, endLine := 0, 0
+			if lineOf != nil && w.end > w.start {
+				startLine, endLine = doc.Lines[lineOf[w.start]], doc.Lines[lineOf[w.end-1]]
+			}
+			chunks = append(chunks, Chunk{
+				ID:          chunkID,
+				DocumentID:  doc.ID,
+				Source:      doc.Title,
+				URI:         doc.URI,
+				Text:        w.text,
+				Index:       idx,
+				StartOffset: w.start,
+				EndOffset:   w.end,
+				Summary:     doc.Summary,
+				StartLine:   startLine,
+				EndLine:     endLine,
+				FetchedAt:   doc.FetchedAt,
+			})
+		}
+	}
+
+	return chunks, nil
+}
+
+// runeLines returns the 0-based content line of every rune in doc.Content, or nil when the
+// document has no line map.
+func runeLines(doc Document) []int {
+	if len(doc.Lines) == 0 {
+		return nil
+	}
+	lineOf := make([]int, 0, len(doc.Content))
+	line := 0
+	for _, r := range doc.Content {
+		lineOf = append(lineOf, min(line, len(doc.Lines)-1))
+		if r == '\n' {
+			line++
+		}
+	}
+	return lineOf
+}
+
+// SlidingWindows returns the chunk texts ChunkDocuments would produce for content, after applying
+// the same option defaults. It is intended for inspecting chunk boundaries.
+func SlidingWindows(content string, opts ChunkOptions) []string {
+	windows := slidingWindows(content, opts.Normalized())
+	texts := make([]string, len(windows))
+	for i, w := range windows {
+		texts[i] = w.text
+	}
+	return texts
+}
+
+// Normalized fills defaults, resolves OverlapRatio, and keeps the overlap below the chunk size.
+func (opts ChunkOptions) Normalized() ChunkOptions {
 	if opts.Size <= 0 {
 		opts.Size = 1200
 	}
+	if opts.OverlapRatio > 0 {
+		opts.Overlap = int(float64(opts.Size) * opts.OverlapRatio)
+	}
 	if opts.Overlap < 0 {
 		opts.Overlap = 0
 	}
 	if opts.Overlap >= opts.Size {
 		opts.Overlap = opts.Size / 4
 	}
-
-	chunks := make([]Chunk, 0, len(docs)*4)
-
-	for _, doc := range docs {
-		windows := slidingWindows(doc.Content, opts.Size, opts.Overlap)
-		for idx, text := range windows {
-			chunkID := fmt.Sprintf("%s-chunk-%d", doc.ID, idx)
-			chunks = append(chunks, Chunk{
-				ID:         chunkID,
-				DocumentID: doc.ID,
-				Source:     doc.Title,
-				URI:        doc.URI,
-				Text:       text,
-				Index:      idx,
-			})
-		}
+	if opts.MinSize < 0 {
+		opts.MinSize = 0
+	}
+	if opts.MaxChunks < 0 {
+		opts.MaxChunks = 0
+	}
+	if opts.MaxCodeBlock <= 0 {
+		opts.MaxCodeBlock = 4 * opts.Size
+	}
+	if opts.MaxCodeBlock < opts.Size {
+		opts.MaxCodeBlock = opts.Size
 	}
+	return opts
+}
 
-	return chunks
+// window is a slice of document content; start and end are rune offsets, end exclusive.
+type window struct {
+	text       string
+	start, end int
 }
 
-func slidingWindows(content string, size, overlap int) []string {
+// slidingWindows splits content into windows of opts.Size runes overlapping by opts.Overlap,
+// keeping fenced code blocks whole when opts.KeepCodeBlocks is set. A final window shorter than
+// opts.MinSize is folded into the one before it.
+func slidingWindows(content string, opts ChunkOptions) []window {
+	size, overlap := opts.Size, opts.Overlap
 	runeCount := utf8.RuneCountInString(content)
 	if runeCount == 0 {
 		return nil
 	}
 
 	if runeCount <= size {
-		return []string{content}
+		return []window{{text: content, start: 0, end: runeCount}}
 	}
 
 	step := size - overlap
@@ -58,18 +154,29 @@ func slidingWindows(content string, size, overlap int) []string {
 		step = size
 	}
 
-	windows := []string{}
+	windows := []window{}
 	runes := []rune(content)
-	for start := 0; start < len(runes); start += step {
-		end := start + size
-		if end > len(runes) {
-			end = len(runes)
+	var blocks []span
+	if opts.KeepCodeBlocks {
+		blocks = fencedBlocks(runes)
+	}
+	for start := 0; start < len(runes); {
+		end := min(start+size, len(runes))
+		next := start + step
+		if len(blocks) > 0 {
+			end, next = codeAwareBounds(runes, blocks, start, end, next, opts.MaxCodeBlock)
 		}
-		window := runes[start:end]
-		windows = append(windows, string(window))
+		windows = append(windows, window{text: string(runes[start:end]), start: start, end: end})
 		if end == len(runes) {
 			break
 		}
+		start = next
+	}
+	if n := len(windows); n > 1 && windows[n-1].end-windows[n-1].start < opts.MinSize {
+		prev := &windows[n-2]
+		prev.end = len(runes)
+		prev.text = string(runes[prev.start:prev.end])
+		windows = windows[:n-1]
 	}
 	return windows
 }
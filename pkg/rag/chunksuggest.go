@@ -0,0 +1,106 @@
+package rag
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Bounds for suggested chunk sizes, in runes. Smaller chunks lose context; larger ones dilute
+// the embedding and crowd the prompt.
+const (
+	minSuggestedChunkSize = 400
+	maxSuggestedChunkSize = 2000
+	// paragraphsPerChunk is how many typical paragraphs a suggested chunk holds.
+	paragraphsPerChunk = 4
+)
+
+// paragraphBreak separates paragraphs: a blank line, possibly holding whitespace.
+var paragraphBreak = regexp.MustCompile(`\n\s*\n`)
+
+// SuggestChunkOptions recommends a chunk size and overlap from the corpus's document and
+// paragraph lengths. It is a heuristic starting point; see SuggestChunkOptionsWithNotes for the
+// reasoning behind a suggestion.
+func SuggestChunkOptions(docs []Document) ChunkOptions {
+	opts, _ := SuggestChunkOptionsWithNotes(docs)
+	return opts
+}
+
+// SuggestChunkOptionsWithNotes behaves like SuggestChunkOptions and also explains each choice.
+// Chunks aim to hold a few typical paragraphs, shrink when most documents are shorter than that,
+// and overlap by about one paragraph. Paragraphs are separated by blank lines, so documents
+// should be collected with PreserveParagraphs. Fenced code blocks turn on KeepCodeBlocks.
+func SuggestChunkOptionsWithNotes(docs []Document) (ChunkOptions, []string) {
+	var docLengths, paragraphLengths []int
+	hasCode := false
+	for _, doc := range docs {
+		if doc.Content == "" {
+			continue
+		}
+		docLengths = append(docLengths, utf8.RuneCountInString(doc.Content))
+		for _, paragraph := range paragraphBreak.Split(doc.Content, -1) {
+			if paragraph = strings.TrimSpace(paragraph); paragraph != "" {
+				paragraphLengths = append(paragraphLengths, utf8.RuneCountInString(paragraph))
+			}
+		}
+		if !hasCode && len(fencedBlocks([]rune(doc.Content))) > 0 {
+			hasCode = true
+		}
+	}
+	if len(docLengths) == 0 {
+		return ChunkOptions{Size: DefaultChunkSize, Overlap: DefaultChunkOverlap},
+			[]string{"no document content to analyse; using the default size and overlap"}
+	}
+
+	docMedian := percentile(docLengths, 0.5)
+	paragraphMedian := percentile(paragraphLengths, 0.5)
+	notes := []string{fmt.Sprintf("documents: %d, median %d characters, 90th percentile %d; median paragraph %d characters",
+		len(docLengths), docMedian, percentile(docLengths, 0.9), paragraphMedian)}
+
+	target := paragraphMedian * paragraphsPerChunk
+	size := clampInt(target, minSuggestedChunkSize, maxSuggestedChunkSize)
+	note := fmt.Sprintf("%d median paragraphs come to about %d characters", paragraphsPerChunk, target)
+	if size != target {
+		note += fmt.Sprintf(", clamped to %d", size)
+	}
+	notes = append(notes, note)
+	if short := max(docMedian, minSuggestedChunkSize); short < size {
+		// Round up so median-length documents still fit in one chunk.
+		size = (short + 99) / 100 * 100
+		notes = append(notes, fmt.Sprintf("most documents are shorter than that, so size drops to %d and they stay whole", size))
+	} else {
+		size = roundTo(size, 100)
+	}
+	notes = append(notes, fmt.Sprintf("size %d", size))
+
+	overlap := roundTo(clampInt(paragraphMedian, size/10, size/4), 10)
+	notes = append(notes, fmt.Sprintf("overlap %d repeats about one paragraph across boundaries (kept within 10-25%% of size)", overlap))
+
+	opts := ChunkOptions{Size: size, Overlap: overlap}
+	if hasCode {
+		opts.KeepCodeBlocks = true
+		notes = append(notes, "fenced code blocks found, so KeepCodeBlocks keeps them in one chunk")
+	}
+	return opts, notes
+}
+
+// percentile returns the value at fraction p of the sorted values, or zero when there are none.
+func percentile(values []int, p float64) int {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]int(nil), values...)
+	sort.Ints(sorted)
+	return sorted[int(p*float64(len(sorted)-1))]
+}
+
+func clampInt(v, lo, hi int) int {
+	return min(max(v, lo), hi)
+}
+
+// roundTo rounds v to the nearest multiple of step.
+func roundTo(v, step int) int {
+	return (v + step/2) / step * step
+}
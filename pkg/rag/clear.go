@@ -0,0 +1,68 @@
+package rag
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrClearUnsupported is returned when the active store cannot be emptied in place.
+var ErrClearUnsupported = errors.New("store does not support clearing")
+
+// storeClearer is implemented by stores that can drop every chunk at once.
+type storeClearer interface {
+	// Clear removes all chunks and returns how many were removed.
+	Clear() (int, error)
+}
+
+var (
+	_ storeClearer = (*VectorStore)(nil)
+	_ storeClearer = (*PgVectorStore)(nil)
+)
+
+// Clear removes every chunk and stored document and resets the metadata, including the embedding
+// dimension and canary, so the next ingest may use any embedding model.
+func (vs *VectorStore) Clear() (int, error) {
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+	removed := len(vs.Chunks)
+	vs.Chunks = nil
+	vs.Documents = nil
+	vs.norms = nil
+	vs.Metadata = Metadata{GeneratedAt: time.Now().UTC()}
+	return removed, nil
+}
+
+// Clear deletes every row of the chunks table.
+func (ps *PgVectorStore) Clear() (int, error) {
+	result := ps.db.Exec(`DELETE FROM chunks`)
+	if result.Error != nil {
+		return 0, fmt.Errorf("pgvector clear: %w", result.Error)
+	}
+	return int(result.RowsAffected), nil
+}
+
+// Clear empties the active store and, for the JSON index, saves the empty index, keeping a backup
+// of the previous one when IndexBackups is set. It returns the number of chunks removed.
+func (s *Service) Clear() (int, error) {
+	if s == nil {
+		return 0, errors.New("rag service is not initialized")
+	}
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	store := s.currentStore()
+	clearer, ok := store.(storeClearer)
+	if !ok {
+		return 0, ErrClearUnsupported
+	}
+	removed, err := clearer.Clear()
+	if err != nil {
+		return 0, err
+	}
+	if vs, ok := store.(*VectorStore); ok && s.indexPath != "" {
+		if err := vs.SaveWithBackups(s.indexPath, s.config.IndexBackups); err != nil {
+			return removed, fmt.Errorf("save vector store: %w", err)
+		}
+	}
+	return removed, nil
+}
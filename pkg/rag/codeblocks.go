@@ -0,0 +1,87 @@
+package rag
+
+import "strings"
+
+// span is a half-open range of rune offsets.
+type span struct {
+	start, end int
+}
+
+// fencedBlocks finds fenced code blocks in runes, each spanning its opening fence line through
+// its closing fence line. A fence is a line starting with at least three backticks or tildes,
+// closed by a line of at least as many of the same character; an unclosed block runs to the end.
+func fencedBlocks(runes []rune) []span {
+	var blocks []span
+	var fence string
+	open := -1
+	for lineStart := 0; lineStart < len(runes); {
+		lineEnd := lineStart
+		for lineEnd < len(runes) && runes[lineEnd] != '\n' {
+			lineEnd++
+		}
+		line := strings.TrimSpace(string(runes[lineStart:lineEnd]))
+		switch {
+		case open < 0:
+			if marker := fenceMarker(line); marker != "" {
+				open, fence = lineStart, marker
+			}
+		case strings.HasPrefix(line, fence) && strings.Trim(line, fence[:1]) == "":
+			blocks = append(blocks, span{start: open, end: lineEnd})
+			open = -1
+		}
+		lineStart = lineEnd + 1
+	}
+	if open >= 0 {
+		blocks = append(blocks, span{start: open, end: len(runes)})
+	}
+	return blocks
+}
+
+// fenceMarker returns the run of backticks or tildes opening a fenced block, or "".
+func fenceMarker(line string) string {
+	if !strings.HasPrefix(line, "```") && !strings.HasPrefix(line, "~~~") {
+		return ""
+	}
+	n := len(line) - len(strings.TrimLeft(line, line[:1]))
+	return line[:n]
+}
+
+// codeAwareBounds adjusts a window [start, end) and the next window's start so no code block is
+// cut. A block the window ends inside is pulled in whole when the window stays within maxSize,
+// otherwise the window stops before it; a block longer than maxSize is split into pieces of at
+// most maxSize runes, at line breaks where possible. Windows never start inside a block, so the
+// overlap is dropped next to code.
+func codeAwareBounds(runes []rune, blocks []span, start, end, next, maxSize int) (int, int) {
+	if b, ok := blockAround(blocks, end); ok {
+		switch {
+		case b.end-start <= maxSize:
+			end = b.end
+		case b.start > start:
+			end = b.start
+		default:
+			end = start + maxSize
+			// Break after the last newline in the second half, so pieces stay reasonably large.
+			for i := end - 1; i > start+maxSize/2; i-- {
+				if runes[i] == '\n' {
+					end = i + 1
+					break
+				}
+			}
+		}
+		return end, end
+	}
+	if b, ok := blockAround(blocks, next); ok {
+		next = b.end
+	}
+	return end, next
+}
+
+// blockAround returns the block that offset falls strictly inside of.
+func blockAround(blocks []span, offset int) (span, bool) {
+	for _, b := range blocks {
+		if b.start < offset && offset < b.end {
+			return b, true
+		}
+	}
+	return span{}, false
+}
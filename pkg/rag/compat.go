@@ -0,0 +1,50 @@
+package rag
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// EmbeddingCanaryText is embedded at ingest and startup to fingerprint the embedding model.
+const EmbeddingCanaryText = "Selling Partner API rate limits, throttling, and usage plans."
+
+// canarySimilarityFloor is the minimum similarity between ingest and query-time canaries.
+// The same model reproduces the canary almost exactly; a different model lands far lower.
+const canarySimilarityFloor = 0.98
+
+// ErrEmbedderMismatch reports that the query-time embedder differs from the one used at ingest.
+var ErrEmbedderMismatch = errors.New("embedder does not match the index")
+
+// VerifyEmbedderCompatibility embeds a canary string and compares it with the index: the vector
+// length must match the stored dimension and, when the index recorded a canary, the two canary
+// embeddings must be near-identical.
+func (s *Service) VerifyEmbedderCompatibility(ctx context.Context) error {
+	if s == nil || s.currentStore() == nil || s.embedder == nil {
+		return errors.New("rag service is not initialized")
+	}
+	meta := s.currentStore().Meta()
+	dim := meta.EmbeddingDim
+	if vs, ok := s.currentStore().(*VectorStore); ok && dim == 0 {
+		dim = vs.embeddingDim()
+	}
+
+	canary, err := s.embedder.EmbedOne(ctx, EmbeddingCanaryText)
+	if err != nil {
+		return fmt.Errorf("embed canary: %w", err)
+	}
+
+	if dim > 0 && len(canary) != dim {
+		return fmt.Errorf("%w: index has %d dimensions, embedder produces %d", ErrEmbedderMismatch, dim, len(canary))
+	}
+	if len(meta.EmbeddingCanary) > 0 {
+		similarity, err := CosineSimilarity(canary, meta.EmbeddingCanary)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrEmbedderMismatch, err)
+		}
+		if similarity < canarySimilarityFloor {
+			return fmt.Errorf("%w: canary similarity %.3f is below %.2f; the embedding model likely changed since ingest", ErrEmbedderMismatch, similarity, canarySimilarityFloor)
+		}
+	}
+	return nil
+}
@@ -1,15 +1,23 @@
 package rag
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 )
 
 const (
 	ProviderOllama = "ollama"
 	ProviderOpenAI = "openai"
+	// ProviderOpenAICompatible targets any server speaking the OpenAI API at ServiceConfig.OpenAIBaseURL
+	// (e.g. http://localhost:8000/v1), such as vLLM, LM Studio, Together, or Groq.
+	ProviderOpenAICompatible = "openai-compatible"
+	// ProviderLocal embeds with an ONNX model on this machine (see LocalEmbedder). It serves
+	// embeddings only, so pair it with another chat provider via RAG_EMBEDDING_PROVIDER.
+	ProviderLocal = "local"
 
 	// DefaultIndexPath points to the generated vector store relative to the repository root.
 	DefaultIndexPath = "data/rag_index.json"
@@ -21,63 +29,446 @@ const (
 	DefaultOpenAIEmbeddingModel = "text-embedding-3-large"
 	DefaultOpenAIChatModel      = "gpt-4o-mini"
 
+	DefaultOpenAIMaxTokens   = 800
+	DefaultOpenAIChatTimeout = 45 * time.Second
+	DefaultOllamaChatTimeout = 60 * time.Second
+
+	// DefaultTemperature is the sampling temperature for answers when a query sets none.
+	DefaultTemperature float32 = 0.2
+	// MaxTemperature is the highest temperature sent to a chat client; both providers accept 0-2.
+	MaxTemperature float32 = 2
+
 	DefaultSystemPrompt    = "You are an assistant that answers questions about Amazon Selling Partner integrations. Reply with concise, implementation-focused answers and cite the provided context snippets."
 	DefaultTopK            = 4
+	DefaultChunkSize       = 1400
+	DefaultChunkOverlap    = 200
+	DefaultEmbedBatchSize  = 16
+	DefaultQueryCacheSize  = 128
 	DefaultLocalDocsFolder = "docs"
 	DefaultProvider        = ProviderOllama
 )
 
+// DefaultOllamaMaxConcurrentQueries bounds simultaneous queries when Ollama generates answers
+// and RAG_MAX_CONCURRENT_QUERIES is unset; a single Ollama instance serves generations one at a
+// time, so a deeper queue only adds timeouts. Other providers are unlimited by default.
+const DefaultOllamaMaxConcurrentQueries = 2
+
+// DefaultDuplicateThreshold is the cosine similarity above which AddSource, AppendDocuments, and
+// VectorStore.AddUnique treat a chunk as a copy of an indexed one.
+const DefaultDuplicateThreshold = 0.98
+
+// NoAnswerMessage is returned without calling the LLM when no context clears the score threshold.
+const NoAnswerMessage = "I do not have that information in the indexed sources."
+
+// SourcesOnlyMessage is the answer text when generation failed and only the sources are returned.
+const SourcesOnlyMessage = "The answer could not be generated right now; the most relevant sources are listed below."
+
+// DefaultRefusalPatterns are case-insensitive phrases that mark a completion as a refusal.
+var DefaultRefusalPatterns = []string{
+	"do not have that information",
+	"don't have that information",
+	"do not have enough information",
+	"don't have enough information",
+	"not present in the provided context",
+	"not mentioned in the provided context",
+	"the provided context does not",
+}
+
+// DefaultPromptTemplate renders retrieved context and the question into the user prompt.
+// Templates receive a promptData value exposing .Question and .Sources.
+const DefaultPromptTemplate = `Context sections (numbered by relevance, [1] is the most relevant):
+{{range .Sources}}[{{.Number}}] Source: {{.Source}} ({{.URI}})
+{{.Text}}
+
+{{end}}Instructions:
+1. Use only the provided context sections.
+2. If the answer is not present, say you do not have that information.
+3. When relevant, cite the source title in parentheses.
+4. Highlight Amazon-specific constraints (rate limits, launch phases, pilots) explicitly.
+
+Question:
+{{.Question}}`
+
 // ServiceConfig controls how the runtime RAG service behaves.
 type ServiceConfig struct {
-	Provider       string
-	IndexPath      string
-	OpenAIAPIKey   string
-	OllamaBaseURL  string
-	EmbeddingModel string
-	ChatModel      string
-	SystemPrompt   string
-	DefaultTopK    int
+	Provider string
+	// EmbeddingProvider and ChatProvider override Provider per component when set.
+	EmbeddingProvider string
+	ChatProvider      string
+	Store             string
+	IndexPath         string
+	DatabaseURL       string
+	OpenAIAPIKey      string
+	OpenAIBaseURL     string
+	OllamaBaseURL     string
+	EmbeddingModel    string
+	ChatModel         string
+	SystemPrompt      string
+	PromptTemplate    string
+	DefaultTopK       int
+	// MaxTokens caps completion length; zero uses the provider default.
+	MaxTokens int
+	// Timeout bounds a single chat completion; zero uses the provider default.
+	Timeout time.Duration
+	// ScoreThreshold drops matches scoring below it; zero disables filtering.
+	ScoreThreshold float64
+	// RefusalPatterns mark completions that decline to answer; nil uses DefaultRefusalPatterns.
+	RefusalPatterns []string
+	// EmbeddingDimensions shortens OpenAI text-embedding-3 vectors; zero keeps the model default.
+	EmbeddingDimensions int
+	// QueryCacheSize bounds the LRU of query embeddings; zero disables caching.
+	QueryCacheSize int
+	// EmbedBatchSize is the number of chunks per embedding request during ingestion.
+	EmbedBatchSize int
+	// EmbedDocumentPrefix and EmbedQueryPrefix are prepended to chunk texts at ingestion and to
+	// questions at query time before embedding, for models trained with task prefixes such as
+	// nomic-embed-text's "search_document: " and "search_query: ". Chunk.Text is stored unprefixed.
+	EmbedDocumentPrefix string
+	EmbedQueryPrefix    string
+	// RerankModel names the Ollama model used to rerank matches before generation; empty disables reranking.
+	RerankModel string
+	// RerankCandidates is how many vector matches are reranked down to TopK.
+	RerankCandidates int
+	// StripTags names wrapper tags, e.g. "think", whose sections are removed from completions
+	// before they become answers. Empty leaves completions untouched.
+	StripTags []string
+	// SanitizeContext strips likely prompt injections from retrieved text, wraps each section in
+	// delimiters, and tells the model to treat the context as untrusted.
+	SanitizeContext bool
+	// FocusTerms starts each prompt with a "Focus terms" line naming the question's key terms,
+	// steering the model toward the relevant parts of long context sections.
+	FocusTerms bool
+	// FallbackProvider, when set, serves embedding and chat calls that fail on the primary
+	// provider. FallbackEmbedding and FallbackChat name its models; empty uses its defaults.
+	// Fallback embeddings are only used when they match the index dimension.
+	FallbackProvider  string
+	FallbackEmbedding string
+	FallbackChat      string
+	// Retry governs retries of failed provider calls; zero fields use the RetryPolicy defaults.
+	Retry RetryPolicy
+	// MaxConcurrentQueries bounds the queries the API answers at once; further ones are turned
+	// away. Zero is unlimited.
+	MaxConcurrentQueries int
+	// IndexBackups is how many earlier versions of the JSON index to keep as .bak files when it
+	// is saved. Zero disables backups.
+	IndexBackups int
+	// LocalModelPath is the directory holding model.onnx and tokenizer.json for the local
+	// embedding provider; ONNXRuntimeLib is the ONNX Runtime shared library that runs it.
+	LocalModelPath string
+	ONNXRuntimeLib string
+	// DuplicateThreshold skips added or appended chunks whose cosine similarity to a chunk of
+	// another indexed document exceeds it. Zero or less disables the check.
+	DuplicateThreshold float64
+	// ModelPrices adds or overrides DefaultModelPrices for usage cost estimates, as
+	// "model=input/output" entries in USD per million tokens.
+	ModelPrices []string
 }
 
 // LoadServiceConfigFromEnv loads runtime RAG configuration from environment variables.
 func LoadServiceConfigFromEnv() ServiceConfig {
 	indexPath := firstNonEmpty(os.Getenv("RAG_INDEX_PATH"), DefaultIndexPath)
-	provider := strings.ToLower(firstNonEmpty(os.Getenv("RAG_PROVIDER"), DefaultProvider))
-	if provider != ProviderOpenAI && provider != ProviderOllama {
-		provider = DefaultProvider
+	provider := parseProvider(os.Getenv("RAG_PROVIDER"), DefaultProvider)
+	embeddingProvider := parseProvider(os.Getenv("RAG_EMBEDDING_PROVIDER"), provider)
+	chatProvider := parseProvider(os.Getenv("RAG_CHAT_PROVIDER"), provider)
+
+	embeddingModel := firstNonEmpty(os.Getenv("RAG_EMBEDDING_MODEL"), defaultEmbeddingModel(embeddingProvider))
+	chatModel := firstNonEmpty(os.Getenv("RAG_CHAT_MODEL"), defaultChatModel(chatProvider))
+
+	systemPrompt := firstNonEmpty(os.Getenv("RAG_SYSTEM_PROMPT"), DefaultSystemPrompt)
+	promptTemplate := firstNonEmpty(os.Getenv("RAG_PROMPT_TEMPLATE"), DefaultPromptTemplate)
+	topK := parseIntEnv("RAG_DEFAULT_TOP_K", DefaultTopK)
+	refusalPatterns := DefaultRefusalPatterns
+	if raw := os.Getenv("RAG_REFUSAL_PATTERNS"); raw != "" {
+		refusalPatterns = splitList(raw)
+	}
+
+	store := strings.ToLower(firstNonEmpty(os.Getenv("RAG_STORE"), StoreJSON))
+	if store != StoreJSON && store != StorePgVector {
+		store = StoreJSON
+	}
+
+	return ServiceConfig{
+		Provider:            provider,
+		EmbeddingProvider:   embeddingProvider,
+		ChatProvider:        chatProvider,
+		Store:               store,
+		IndexPath:           resolveWorkspacePath(indexPath),
+		DatabaseURL:         firstNonEmpty(os.Getenv("RAG_DATABASE_URL"), os.Getenv("DB_URL")),
+		OpenAIAPIKey:        os.Getenv("OPENAI_API_KEY"),
+		OpenAIBaseURL:       os.Getenv("RAG_OPENAI_BASE_URL"),
+		OllamaBaseURL:       firstNonEmpty(os.Getenv("RAG_OLLAMA_BASE_URL"), DefaultOllamaBaseURL),
+		EmbeddingModel:      embeddingModel,
+		ChatModel:           chatModel,
+		SystemPrompt:        systemPrompt,
+		PromptTemplate:      promptTemplate,
+		DefaultTopK:         topK,
+		MaxTokens:           parseIntEnv("RAG_MAX_TOKENS", 0),
+		Timeout:             parseDurationEnv("RAG_CHAT_TIMEOUT", 0),
+		ScoreThreshold:      parseFloatEnv("RAG_SCORE_THRESHOLD", 0),
+		RefusalPatterns:     refusalPatterns,
+		QueryCacheSize:      parseIntEnv("RAG_QUERY_CACHE_SIZE", DefaultQueryCacheSize),
+		EmbedBatchSize:      parseIntEnv("RAG_EMBED_BATCH_SIZE", DefaultEmbedBatchSize),
+		EmbedDocumentPrefix: os.Getenv("RAG_EMBED_DOCUMENT_PREFIX"),
+		EmbedQueryPrefix:    os.Getenv("RAG_EMBED_QUERY_PREFIX"),
+		EmbeddingDimensions: parseIntEnv("RAG_EMBEDDING_DIMENSIONS", 0),
+		RerankModel:         os.Getenv("RAG_RERANK_MODEL"),
+		RerankCandidates:    parseIntEnv("RAG_RERANK_CANDIDATES", DefaultRerankCandidates),
+		SanitizeContext:     parseBoolEnv("RAG_SANITIZE_CONTEXT", false),
+		FocusTerms:          parseBoolEnv("RAG_FOCUS_TERMS", false),
+		StripTags:           splitList(os.Getenv("RAG_STRIP_TAGS")),
+		FallbackProvider:    strings.ToLower(strings.TrimSpace(os.Getenv("RAG_FALLBACK_PROVIDER"))),
+		FallbackEmbedding:   os.Getenv("RAG_FALLBACK_EMBEDDING_MODEL"),
+		FallbackChat:        os.Getenv("RAG_FALLBACK_CHAT_MODEL"),
+		Retry: RetryPolicy{
+			MaxAttempts: parseIntEnv("RAG_RETRY_MAX_ATTEMPTS", DefaultRetryAttempts),
+			BaseDelay:   parseDurationEnv("RAG_RETRY_BASE_DELAY", DefaultRetryBaseDelay),
+			MaxDelay:    parseDurationEnv("RAG_RETRY_MAX_DELAY", DefaultRetryMaxDelay),
+			Jitter:      parseFloatEnv("RAG_RETRY_JITTER", DefaultRetryJitter),
+		},
+		MaxConcurrentQueries: parseIntEnv("RAG_MAX_CONCURRENT_QUERIES", defaultMaxConcurrentQueries(chatProvider)),
+		IndexBackups:         parseIntEnv("RAG_INDEX_BACKUPS", 0),
+		LocalModelPath:       resolveWorkspacePath(os.Getenv("RAG_LOCAL_MODEL_PATH")),
+		ONNXRuntimeLib:       os.Getenv("RAG_ONNXRUNTIME_LIB"),
+		DuplicateThreshold:   parseFloatEnv("RAG_DUPLICATE_THRESHOLD", DefaultDuplicateThreshold),
+		ModelPrices:          splitList(os.Getenv("RAG_MODEL_PRICES")),
+	}
+}
+
+// defaultMaxConcurrentQueries returns the query concurrency limit used when none is configured.
+func defaultMaxConcurrentQueries(chatProvider string) int {
+	if chatProvider == ProviderOllama {
+		return DefaultOllamaMaxConcurrentQueries
 	}
+	return 0
+}
 
-	embeddingModel := os.Getenv("RAG_EMBEDDING_MODEL")
-	if embeddingModel == "" {
-		if provider == ProviderOllama {
-			embeddingModel = DefaultOllamaEmbeddingModel
-		} else {
-			embeddingModel = DefaultOpenAIEmbeddingModel
+// defaultEmbeddingModel returns the embedding model used when none is configured. OpenAI-compatible
+// servers host arbitrary models, so they get no default.
+func defaultEmbeddingModel(provider string) string {
+	switch provider {
+	case ProviderOllama:
+		return DefaultOllamaEmbeddingModel
+	case ProviderOpenAI:
+		return DefaultOpenAIEmbeddingModel
+	case ProviderLocal:
+		// Name the model after its directory so cache keys and logs tell models apart.
+		if dir := os.Getenv("RAG_LOCAL_MODEL_PATH"); dir != "" {
+			return filepath.Base(dir)
 		}
+		return ""
+	default:
+		return ""
+	}
+}
+
+// defaultChatModel returns the chat model used when none is configured.
+func defaultChatModel(provider string) string {
+	switch provider {
+	case ProviderOllama:
+		return DefaultOllamaChatModel
+	case ProviderOpenAI:
+		return DefaultOpenAIChatModel
+	default:
+		return ""
+	}
+}
+
+// fallbackConfig returns c with the fallback provider and models in place of the primary ones,
+// or false when no fallback provider is configured.
+func (c ServiceConfig) fallbackConfig() (ServiceConfig, bool) {
+	if c.FallbackProvider == "" {
+		return c, false
 	}
+	fallback := c
+	fallback.Provider, fallback.EmbeddingProvider, fallback.ChatProvider = c.FallbackProvider, "", ""
+	fallback.EmbeddingModel = firstNonEmpty(c.FallbackEmbedding, defaultEmbeddingModel(c.FallbackProvider))
+	fallback.ChatModel = firstNonEmpty(c.FallbackChat, defaultChatModel(c.FallbackProvider))
+	fallback.FallbackProvider = ""
+	return fallback, true
+}
 
-	chatModel := os.Getenv("RAG_CHAT_MODEL")
-	if chatModel == "" {
-		if provider == ProviderOllama {
-			chatModel = DefaultOllamaChatModel
-		} else {
-			chatModel = DefaultOpenAIChatModel
+// parseProvider normalizes a provider name, returning fallback for empty or unknown values.
+func parseProvider(raw, fallback string) string {
+	switch provider := strings.ToLower(strings.TrimSpace(raw)); provider {
+	case ProviderOpenAI, ProviderOllama, ProviderOpenAICompatible, ProviderLocal:
+		return provider
+	default:
+		return fallback
+	}
+}
+
+// redacted stands in for secrets in PublicConfig.
+const redacted = "[redacted]"
+
+// PublicConfig is the operator-facing view of a ServiceConfig. Secrets are redacted and the
+// database URL, which may embed a password, is reduced to whether one is set.
+type PublicConfig struct {
+	Provider            string   `json:"provider"`
+	EmbeddingProvider   string   `json:"embeddingProvider"`
+	ChatProvider        string   `json:"chatProvider"`
+	EmbeddingModel      string   `json:"embeddingModel"`
+	ChatModel           string   `json:"chatModel"`
+	EmbeddingDimensions int      `json:"embeddingDimensions,omitempty"`
+	OpenAIBaseURL       string   `json:"openaiBaseUrl,omitempty"`
+	OllamaBaseURL       string   `json:"ollamaBaseUrl,omitempty"`
+	OpenAIAPIKey        string   `json:"openaiApiKey,omitempty"`
+	Store               string   `json:"store"`
+	IndexPath           string   `json:"indexPath,omitempty"`
+	DatabaseConfigured  bool     `json:"databaseConfigured"`
+	DefaultTopK         int      `json:"defaultTopK"`
+	ScoreThreshold      float64  `json:"scoreThreshold"`
+	MaxTokens           int      `json:"maxTokens"`
+	ChatTimeout         string   `json:"chatTimeout,omitempty"`
+	RerankModel         string   `json:"rerankModel,omitempty"`
+	EmbedBatchSize      int      `json:"embedBatchSize"`
+	QueryCacheSize      int      `json:"queryCacheSize"`
+	SanitizeContext     bool     `json:"sanitizeContext"`
+	FocusTerms          bool     `json:"focusTerms"`
+	StripTags           []string `json:"stripTags,omitempty"`
+	FallbackProvider    string   `json:"fallbackProvider,omitempty"`
+	RetryMaxAttempts    int      `json:"retryMaxAttempts"`
+	RetryBaseDelay      string   `json:"retryBaseDelay"`
+	RetryMaxDelay       string   `json:"retryMaxDelay"`
+	RetryJitter         float64  `json:"retryJitter"`
+	// MaxConcurrentQueries is zero when queries are unlimited.
+	MaxConcurrentQueries int     `json:"maxConcurrentQueries"`
+	IndexBackups         int     `json:"indexBackups"`
+	LocalModelPath       string  `json:"localModelPath,omitempty"`
+	DuplicateThreshold   float64 `json:"duplicateThreshold"`
+}
+
+// Public returns the non-secret configuration. Base URLs are only reported for the providers in use.
+func (c ServiceConfig) Public() PublicConfig {
+	public := PublicConfig{
+		Provider:            c.Provider,
+		EmbeddingProvider:   c.EmbeddingBackend(),
+		ChatProvider:        c.ChatBackend(),
+		EmbeddingModel:      c.EmbeddingModel,
+		ChatModel:           c.ChatModel,
+		EmbeddingDimensions: c.EmbeddingDimensions,
+		Store:               firstNonEmpty(c.Store, StoreJSON),
+		DatabaseConfigured:  c.DatabaseURL != "",
+		DefaultTopK:         c.DefaultTopK,
+		ScoreThreshold:      c.ScoreThreshold,
+		MaxTokens:           c.MaxTokens,
+		RerankModel:         c.RerankModel,
+		EmbedBatchSize:      c.EmbedBatchSize,
+		QueryCacheSize:      c.QueryCacheSize,
+		SanitizeContext:     c.SanitizeContext,
+		FocusTerms:          c.FocusTerms,
+		StripTags:           c.StripTags,
+		FallbackProvider:    c.FallbackProvider,
+	}
+	retry := c.Retry.normalized()
+	public.RetryMaxAttempts = retry.MaxAttempts
+	public.RetryBaseDelay = retry.BaseDelay.String()
+	public.RetryMaxDelay = retry.MaxDelay.String()
+	public.RetryJitter = retry.Jitter
+	public.MaxConcurrentQueries = c.MaxConcurrentQueries
+	public.IndexBackups = c.IndexBackups
+	public.DuplicateThreshold = c.DuplicateThreshold
+	if public.Store == StoreJSON {
+		public.IndexPath = c.IndexPath
+	}
+	if c.Timeout > 0 {
+		public.ChatTimeout = c.Timeout.String()
+	}
+	if c.OpenAIAPIKey != "" {
+		public.OpenAIAPIKey = redacted
+	}
+	for _, provider := range []string{public.EmbeddingProvider, public.ChatProvider} {
+		switch provider {
+		case ProviderOpenAICompatible:
+			public.OpenAIBaseURL = c.OpenAIBaseURL
+		case ProviderOllama:
+			public.OllamaBaseURL = c.OllamaBaseURL
+		case ProviderLocal:
+			public.LocalModelPath = c.LocalModelPath
 		}
 	}
+	if c.RerankModel != "" {
+		public.OllamaBaseURL = c.OllamaBaseURL
+	}
+	return public
+}
 
-	systemPrompt := firstNonEmpty(os.Getenv("RAG_SYSTEM_PROMPT"), DefaultSystemPrompt)
-	topK := parseIntEnv("RAG_DEFAULT_TOP_K", DefaultTopK)
+// EmbeddingBackend returns the provider used for embeddings.
+func (c ServiceConfig) EmbeddingBackend() string {
+	return firstNonEmpty(c.EmbeddingProvider, c.Provider)
+}
 
-	return ServiceConfig{
-		Provider:       provider,
-		IndexPath:      resolveWorkspacePath(indexPath),
-		OpenAIAPIKey:   os.Getenv("OPENAI_API_KEY"),
-		OllamaBaseURL:  firstNonEmpty(os.Getenv("RAG_OLLAMA_BASE_URL"), DefaultOllamaBaseURL),
-		EmbeddingModel: embeddingModel,
-		ChatModel:      chatModel,
-		SystemPrompt:   systemPrompt,
-		DefaultTopK:    topK,
+// ChatBackend returns the provider used for chat completions.
+func (c ServiceConfig) ChatBackend() string {
+	return firstNonEmpty(c.ChatProvider, c.Provider)
+}
+
+// Validate checks generation limits and provider-specific requirements for each component independently.
+func (c ServiceConfig) Validate() error {
+	if err := c.generationLimits().validate(); err != nil {
+		return err
+	}
+	if c.EmbeddingDimensions < 0 {
+		return fmt.Errorf("embedding dimensions must not be negative, got %d", c.EmbeddingDimensions)
+	}
+	if c.EmbedBatchSize <= 0 {
+		return fmt.Errorf("embed batch size must be positive, got %d", c.EmbedBatchSize)
+	}
+	if c.RerankCandidates < 0 {
+		return fmt.Errorf("rerank candidates must not be negative, got %d", c.RerankCandidates)
+	}
+	if _, err := newTagStripper(c.StripTags); err != nil {
+		return err
+	}
+	if c.DuplicateThreshold > 1 {
+		return fmt.Errorf("duplicate threshold must be at most 1, got %g", c.DuplicateThreshold)
+	}
+	if _, err := parseModelPrices(c.ModelPrices, DefaultModelPrices); err != nil {
+		return err
 	}
+	if err := c.Retry.validate(); err != nil {
+		return err
+	}
+	if c.MaxConcurrentQueries < 0 {
+		return fmt.Errorf("max concurrent queries must not be negative, got %d", c.MaxConcurrentQueries)
+	}
+	if c.IndexBackups < 0 {
+		return fmt.Errorf("index backups must not be negative, got %d", c.IndexBackups)
+	}
+	if fallback, ok := c.fallbackConfig(); ok {
+		if err := fallback.Validate(); err != nil {
+			return fmt.Errorf("RAG_FALLBACK_PROVIDER: %w", err)
+		}
+	}
+	components := []struct{ name, provider, model, modelEnv string }{
+		{"embedding", c.EmbeddingBackend(), c.EmbeddingModel, "RAG_EMBEDDING_MODEL"},
+		{"chat", c.ChatBackend(), c.ChatModel, "RAG_CHAT_MODEL"},
+	}
+	for _, component := range components {
+		switch component.provider {
+		case ProviderOpenAI:
+			if c.OpenAIAPIKey == "" {
+				return fmt.Errorf("OPENAI_API_KEY must be set when the %s provider is openai", component.name)
+			}
+		case ProviderOpenAICompatible:
+			if c.OpenAIBaseURL == "" {
+				return fmt.Errorf("RAG_OPENAI_BASE_URL must be set when the %s provider is %s", component.name, ProviderOpenAICompatible)
+			}
+			if component.model == "" {
+				return fmt.Errorf("%s must be set when the %s provider is %s", component.modelEnv, component.name, ProviderOpenAICompatible)
+			}
+		case ProviderOllama:
+		case ProviderLocal:
+			if component.name != "embedding" {
+				return fmt.Errorf("the %s provider only serves embeddings; set RAG_CHAT_PROVIDER to another provider", ProviderLocal)
+			}
+			if c.LocalModelPath == "" {
+				return fmt.Errorf("RAG_LOCAL_MODEL_PATH must be set when the embedding provider is %s", ProviderLocal)
+			}
+		default:
+			return fmt.Errorf("unsupported %s provider %q", component.name, component.provider)
+		}
+	}
+	return nil
 }
 
 func firstNonEmpty(values ...string) string {
@@ -98,6 +489,44 @@ func parseIntEnv(key string, fallback int) int {
 	return fallback
 }
 
+func parseFloatEnv(key string, fallback float64) float64 {
+	if raw := os.Getenv(key); raw != "" {
+		if val, err := strconv.ParseFloat(raw, 64); err == nil {
+			return val
+		}
+	}
+	return fallback
+}
+
+func parseBoolEnv(key string, fallback bool) bool {
+	if raw := os.Getenv(key); raw != "" {
+		if val, err := strconv.ParseBool(raw); err == nil {
+			return val
+		}
+	}
+	return fallback
+}
+
+func parseDurationEnv(key string, fallback time.Duration) time.Duration {
+	if raw := os.Getenv(key); raw != "" {
+		if val, err := time.ParseDuration(raw); err == nil {
+			return val
+		}
+	}
+	return fallback
+}
+
+// splitList parses a comma-separated env value, dropping empty entries.
+func splitList(raw string) []string {
+	var out []string
+	for _, part := range strings.Split(raw, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
 // ResolveWorkspacePath exposes the internal helper for other packages, e.g. CLI tooling.
 func ResolveWorkspacePath(pathValue string) string {
 	return resolveWorkspacePath(pathValue)
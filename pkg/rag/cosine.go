@@ -0,0 +1,35 @@
+//go:build !gonum
+
+package rag
+
+// CosineBackend names the cosine similarity kernel compiled in. Build with -tags gonum to use
+// gonum's BLAS routines instead of this pure-Go loop.
+const CosineBackend = "go"
+
+// dotAndSquaredNorms returns a·b, |a|², and |b|² for vectors of equal length.
+func dotAndSquaredNorms(a, b []float32) (dot, squaredA, squaredB float64) {
+	for i := range a {
+		dot += float64(a[i] * b[i])
+		squaredA += float64(a[i] * a[i])
+		squaredB += float64(b[i] * b[i])
+	}
+	return dot, squaredA, squaredB
+}
+
+// dot returns a·b for vectors of equal length.
+func dot(a, b []float32) float64 {
+	var sum float64
+	for i := range a {
+		sum += float64(a[i] * b[i])
+	}
+	return sum
+}
+
+// squaredNorm returns |a|².
+func squaredNorm(a []float32) float64 {
+	var sum float64
+	for _, v := range a {
+		sum += float64(v * v)
+	}
+	return sum
+}
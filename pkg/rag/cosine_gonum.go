@@ -0,0 +1,28 @@
+//go:build gonum
+
+package rag
+
+import "gonum.org/v1/gonum/blas/blas32"
+
+// CosineBackend names the cosine similarity kernel compiled in.
+const CosineBackend = "gonum"
+
+// dotAndSquaredNorms returns a·b, |a|², and |b|² for vectors of equal length using gonum's
+// float32 BLAS, which has assembly kernels on amd64 and can be replaced by a native BLAS through
+// blas32.Use. Dsdot accumulates in float64 like the pure-Go loop. gonum/floats is not used because
+// it only takes float64 slices, and converting every stored vector would cost more than it saves.
+func dotAndSquaredNorms(a, b []float32) (dot, squaredA, squaredB float64) {
+	impl := blas32.Implementation()
+	n := len(a)
+	return impl.Dsdot(n, a, 1, b, 1), impl.Dsdot(n, a, 1, a, 1), impl.Dsdot(n, b, 1, b, 1)
+}
+
+// dot returns a·b for vectors of equal length.
+func dot(a, b []float32) float64 {
+	return blas32.Implementation().Dsdot(len(a), a, 1, b, 1)
+}
+
+// squaredNorm returns |a|².
+func squaredNorm(a []float32) float64 {
+	return blas32.Implementation().Dsdot(len(a), a, 1, a, 1)
+}
@@ -0,0 +1,189 @@
+package rag
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// CrawlSource declares a website to crawl by following in-page links from SeedURL.
+type CrawlSource struct {
+	SeedURL string
+	// MaxDepth is the number of link hops followed from the seed; zero uses DefaultCrawlDepth.
+	MaxDepth int
+	// SameHostOnly restricts the crawl to the seed's host.
+	SameHostOnly bool
+	// MaxPages caps the number of documents produced; zero uses DefaultCrawlMaxPages.
+	MaxPages int
+	// AllowPathPrefix, when set, skips links whose path does not start with it.
+	AllowPathPrefix string
+}
+
+const (
+	DefaultCrawlDepth    = 2
+	DefaultCrawlMaxPages = 50
+)
+
+// skippedCrawlExtensions are assets that never contain crawlable HTML.
+var skippedCrawlExtensions = map[string]struct{}{
+	".png": {}, ".jpg": {}, ".jpeg": {}, ".gif": {}, ".svg": {}, ".ico": {}, ".webp": {},
+	".css": {}, ".js": {}, ".pdf": {}, ".zip": {}, ".gz": {}, ".mp4": {}, ".woff": {}, ".woff2": {},
+}
+
+type crawlItem struct {
+	url   string
+	depth int
+}
+
+// crawlRemoteDocuments walks src breadth-first, turning each fetched page into a Document.
+func crawlRemoteDocuments(ctx context.Context, f *fetcher, src CrawlSource) ([]Document, []string, error) {
+	seed, err := url.Parse(src.SeedURL)
+	if err != nil {
+		return nil, nil, fmt.Errorf("parse seed %s: %w", src.SeedURL, err)
+	}
+	maxDepth := src.MaxDepth
+	if maxDepth <= 0 {
+		maxDepth = DefaultCrawlDepth
+	}
+	maxPages := src.MaxPages
+	if maxPages <= 0 {
+		maxPages = DefaultCrawlMaxPages
+	}
+
+	var documents []Document
+	var notes []string
+	seedURL := canonicalCrawlURL(seed)
+	visited := map[string]struct{}{seedURL: {}}
+	queue := []crawlItem{{url: seedURL}}
+
+	for len(queue) > 0 && len(documents) < maxPages {
+		if err := ctx.Err(); err != nil {
+			return nil, nil, err
+		}
+		item := queue[0]
+		queue = queue[1:]
+
+		body, err := f.get(ctx, item.url)
+		if err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return nil, nil, ctxErr
+			}
+			notes = append(notes, fmt.Sprintf("crawl skipped %s: %v", item.url, err))
+			continue
+		}
+
+		title, links := parseHTMLPage(string(body))
+		text, err := convertPayload(string(body), FormatHTML, f.htmlOpts)
+		if err != nil {
+			notes = append(notes, fmt.Sprintf("crawl skipped %s: %v", item.url, err))
+			continue
+		}
+		if title == "" {
+			title = item.url
+		}
+		documents = append(documents, Document{
+			ID:      Slugify(item.url),
+			Title:   title,
+			URI:     item.url,
+			Source:  "crawl: " + seed.Host,
+			Content: text,
+
+			fromHTML: true,
+		})
+
+		if item.depth >= maxDepth {
+			continue
+		}
+		base, _ := url.Parse(item.url)
+		for _, href := range links {
+			next, ok := resolveCrawlLink(base, href, seed, src)
+			if !ok {
+				continue
+			}
+			if _, seen := visited[next]; seen {
+				continue
+			}
+			visited[next] = struct{}{}
+			queue = append(queue, crawlItem{url: next, depth: item.depth + 1})
+		}
+	}
+
+	if len(documents) >= maxPages && len(queue) > 0 {
+		notes = append(notes, fmt.Sprintf("crawl of %s stopped at %d pages", src.SeedURL, maxPages))
+	}
+	if len(documents) == 0 {
+		return nil, notes, errors.New("no pages could be fetched")
+	}
+	return documents, notes, nil
+}
+
+// resolveCrawlLink makes href absolute and applies the source's scope rules.
+func resolveCrawlLink(base *url.URL, href string, seed *url.URL, src CrawlSource) (string, bool) {
+	ref, err := url.Parse(strings.TrimSpace(href))
+	if err != nil {
+		return "", false
+	}
+	next := base.ResolveReference(ref)
+	if next.Scheme != "http" && next.Scheme != "https" {
+		return "", false
+	}
+	if src.SameHostOnly && !strings.EqualFold(next.Host, seed.Host) {
+		return "", false
+	}
+	if src.AllowPathPrefix != "" && !strings.HasPrefix(next.Path, src.AllowPathPrefix) {
+		return "", false
+	}
+	if _, skip := skippedCrawlExtensions[strings.ToLower(path.Ext(next.Path))]; skip {
+		return "", false
+	}
+	return canonicalCrawlURL(next), true
+}
+
+// canonicalCrawlURL drops fragments so anchors on the same page dedupe.
+func canonicalCrawlURL(u *url.URL) string {
+	clone := *u
+	clone.Fragment = ""
+	clone.RawFragment = ""
+	return clone.String()
+}
+
+// parseHTMLPage returns the document title and every anchor href in order.
+func parseHTMLPage(raw string) (string, []string) {
+	var title string
+	var links []string
+	inTitle := false
+	tokenizer := html.NewTokenizer(strings.NewReader(raw))
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return strings.TrimSpace(title), links
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := tokenizer.TagName()
+			switch string(name) {
+			case "title":
+				inTitle = title == ""
+			case "a":
+				for hasAttr {
+					var key, val []byte
+					key, val, hasAttr = tokenizer.TagAttr()
+					if string(key) == "href" {
+						links = append(links, string(val))
+					}
+				}
+			}
+		case html.EndTagToken:
+			if name, _ := tokenizer.TagName(); string(name) == "title" {
+				inTitle = false
+			}
+		case html.TextToken:
+			if inTitle {
+				title += string(tokenizer.Text())
+			}
+		}
+	}
+}
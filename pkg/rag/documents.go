@@ -4,15 +4,11 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"io"
-	"net/http"
 	"os"
 	"path/filepath"
 	"regexp"
 	"strings"
 	"time"
-
-	"github.com/jaytaylor/html2text"
 )
 
 // RemoteFormat enumerates the strategies for parsing downloaded content.
@@ -23,6 +19,7 @@ const (
 	FormatHTML     RemoteFormat = "html"
 	FormatText     RemoteFormat = "text"
 	FormatTSV      RemoteFormat = "tsv"
+	FormatDOCX     RemoteFormat = "docx"
 )
 
 // RemoteSource declares a remote artifact to ingest.
@@ -31,6 +28,9 @@ type RemoteSource struct {
 	URL         string
 	Format      RemoteFormat
 	Description string
+	// Headers are sent when fetching this source, overriding SourceOptions.HTTPHeaders,
+	// e.g. a cookie or Authorization header for gated docs.
+	Headers map[string]string
 }
 
 // SourceOptions controls how we discover documents.
@@ -38,6 +38,49 @@ type SourceOptions struct {
 	LocalDocsDir      string
 	IncludeExtensions []string
 	RemoteSources     []RemoteSource
+	CrawlSources      []CrawlSource
+	SitemapSources    []SitemapSource
+	GitHubSources     []GitHubSource
+	// GitHubToken authenticates GitHub requests for higher rate limits and private repos.
+	GitHubToken string
+	// UserAgent is sent with every remote request; empty uses DefaultUserAgent.
+	UserAgent string
+	// HTTPHeaders are sent with every remote request. A User-Agent entry overrides UserAgent.
+	HTTPHeaders map[string]string
+	// CrawlDelay is the minimum pause between requests to the same host.
+	// A longer Crawl-delay from robots.txt takes precedence.
+	CrawlDelay time.Duration
+	// IgnoreRobots skips robots.txt checks for remote sources.
+	IgnoreRobots bool
+	// DetectLanguage records each document's language without filtering.
+	DetectLanguage bool
+	// AllowLanguages keeps only documents detected in these ISO 639-1 codes; empty keeps everything.
+	AllowLanguages []string
+	// PreserveParagraphs keeps single blank lines between paragraphs instead of removing them.
+	PreserveParagraphs bool
+	// Summarize asks the chat model for a summary of every document, which is prepended to each
+	// chunk when embedding. It costs one completion per document.
+	Summarize bool
+	// FetchTimeout bounds each remote request; zero uses DefaultFetchTimeout.
+	FetchTimeout time.Duration
+	// MaxBytes caps each remote response body; zero uses DefaultMaxFetchBytes.
+	MaxBytes int64
+	// Retry governs retries of remote requests after transient failures; zero fields use the
+	// RetryPolicy defaults.
+	Retry RetryPolicy
+	// HTML tunes the conversion of HTML pages from every remote source.
+	HTML HTMLOptions
+	// StoreFullDocuments keeps the original documents in the JSON index next to their chunks, so
+	// they can be re-chunked offline or shown whole. It roughly doubles the index size.
+	StoreFullDocuments bool
+	// MaxDocuments stops collection once this many documents are gathered, keeping those and
+	// noting when later documents were skipped. No collector fetches more than still fits. Zero
+	// is unlimited.
+	MaxDocuments int
+	// ExcludeGlobs skips local files matching any gitignore-style pattern, tested against the
+	// slash-separated path relative to LocalDocsDir; "**" matches any number of directories.
+	// Patterns in a .ragignore file at the top of LocalDocsDir are added.
+	ExcludeGlobs []string
 }
 
 // DefaultSourceOptions returns a pre-populated list using the resources shared by the team.
@@ -49,7 +92,8 @@ func DefaultSourceOptions(baseDir string) SourceOptions {
 
 	return SourceOptions{
 		LocalDocsDir:      baseDir,
-		IncludeExtensions: []string{".md", ".markdown", ".txt"},
+		IncludeExtensions: []string{".md", ".markdown", ".txt", ".docx"},
+		GitHubToken:       os.Getenv("GITHUB_TOKEN"),
 		RemoteSources: []RemoteSource{
 			{
 				Name:        "Amazon Selling Partner API Samples (README)",
@@ -87,140 +131,342 @@ func DefaultSourceOptions(baseDir string) SourceOptions {
 
 // CollectDocuments walks both local and remote sources.
 func CollectDocuments(ctx context.Context, opts SourceOptions) ([]Document, error) {
+	documents, _, err := CollectDocumentsWithNotes(ctx, opts)
+	return documents, err
+}
+
+// CollectDocumentsWithNotes behaves like CollectDocuments and also reports notes about
+// skipped sources, suitable for Metadata.Notes.
+func CollectDocumentsWithNotes(ctx context.Context, opts SourceOptions) ([]Document, []string, error) {
 	var documents []Document
+	var notes []string
 
-	if localDocs, err := collectLocalDocuments(opts); err == nil {
+	var blocklist *regexp.Regexp
+	if opts.HTML.RemoveBoilerplate {
+		var err error
+		if blocklist, err = compileBoilerplatePatterns(opts.HTML.BoilerplatePatterns); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// skipped is set once a document or source is left out because of MaxDocuments.
+	skipped := false
+	full := func() bool {
+		return opts.MaxDocuments > 0 && len(documents) >= opts.MaxDocuments
+	}
+	// remaining is the limit passed to collectors; zero, when MaxDocuments is unset, is unlimited.
+	remaining := func() int {
+		if opts.MaxDocuments <= 0 {
+			return 0
+		}
+		return opts.MaxDocuments - len(documents)
+	}
+	limited := func(err error) error {
+		if errors.Is(err, errDocumentLimit) {
+			skipped = true
+			return nil
+		}
+		return err
+	}
+
+	localDocs, localNotes, err := collectLocalDocuments(opts)
+	if err = limited(err); err == nil {
 		documents = append(documents, localDocs...)
+		notes = append(notes, localNotes...)
 	} else if !errors.Is(err, os.ErrNotExist) {
-		return nil, fmt.Errorf("collect local docs: %w", err)
+		return nil, nil, fmt.Errorf("collect local docs: %w", err)
 	}
 
+	f := newFetcher(opts)
 	if len(opts.RemoteSources) > 0 {
-		remoteDocs, err := collectRemoteDocuments(ctx, opts.RemoteSources)
+		if full() {
+			skipped = true
+		} else {
+			remoteDocs, remoteNotes, err := collectRemoteDocuments(ctx, f, opts.RemoteSources, remaining())
+			if err = limited(err); err != nil {
+				return nil, nil, fmt.Errorf("collect remote docs: %w", err)
+			}
+			documents = append(documents, remoteDocs...)
+			notes = append(notes, remoteNotes...)
+		}
+	}
+
+	for _, src := range opts.CrawlSources {
+		if full() {
+			skipped = true
+			break
+		}
+		src.MaxPages = documentBudget(src.MaxPages, DefaultCrawlMaxPages, opts.MaxDocuments, len(documents))
+		crawled, crawlNotes, err := crawlRemoteDocuments(ctx, f, src)
+		notes = append(notes, crawlNotes...)
 		if err != nil {
-			return nil, fmt.Errorf("collect remote docs: %w", err)
+			return nil, nil, fmt.Errorf("crawl %s: %w", src.SeedURL, err)
 		}
-		documents = append(documents, remoteDocs...)
+		documents = append(documents, crawled...)
 	}
 
-	return documents, nil
+	for _, src := range opts.SitemapSources {
+		if full() {
+			skipped = true
+			break
+		}
+		src.MaxURLs = documentBudget(src.MaxURLs, DefaultSitemapMaxURLs, opts.MaxDocuments, len(documents))
+		pages, sitemapNotes, err := collectSitemapDocuments(ctx, f, src)
+		notes = append(notes, sitemapNotes...)
+		if err != nil {
+			return nil, nil, fmt.Errorf("sitemap %s: %w", src.URL, err)
+		}
+		documents = append(documents, pages...)
+	}
+
+	for _, src := range opts.GitHubSources {
+		if full() {
+			skipped = true
+			break
+		}
+		repoDocs, repoNotes, err := collectGitHubDocuments(ctx, f, src, opts.GitHubToken, remaining())
+		if err = limited(err); err != nil {
+			return nil, nil, fmt.Errorf("collect github %s/%s: %w", src.Owner, src.Repo, err)
+		}
+		documents = append(documents, repoDocs...)
+		notes = append(notes, repoNotes...)
+	}
+
+	if skipped {
+		notes = append(notes, fmt.Sprintf("collection stopped at the max documents limit (%d); later documents were skipped", opts.MaxDocuments))
+	}
+
+	if blocklist != nil {
+		if note := removeBoilerplate(documents, blocklist); note != "" {
+			notes = append(notes, note)
+		}
+	}
+
+	fetchedAt := time.Now().UTC()
+	for i := range documents {
+		if documents[i].FetchedAt.IsZero() {
+			documents[i].FetchedAt = fetchedAt
+		}
+	}
+
+	if !opts.PreserveParagraphs {
+		for i := range documents {
+			content, lines := normalizeWhitespaceLines(documents[i].Content, false)
+			if documents[i].Lines != nil {
+				// Compose with the existing map so lines still point into the original file.
+				for j, line := range lines {
+					lines[j] = documents[i].Lines[line-1]
+				}
+				documents[i].Lines = lines
+			}
+			documents[i].Content = content
+		}
+	}
+
+	if opts.DetectLanguage || len(opts.AllowLanguages) > 0 {
+		var langNotes []string
+		documents, langNotes = applyLanguageFilter(documents, opts.AllowLanguages)
+		notes = append(notes, langNotes...)
+	}
+
+	return documents, notes, nil
 }
 
-func collectLocalDocuments(opts SourceOptions) ([]Document, error) {
+// errDocumentLimit is returned by a collector that stopped because its document limit was reached
+// while candidates were left, alongside the documents it collected.
+var errDocumentLimit = errors.New("document limit reached")
+
+// documentBudget returns limit, or fallback when limit is not positive, lowered to the number of
+// documents maxDocuments still allows after have were collected. A zero maxDocuments is unlimited.
+func documentBudget(limit, fallback, maxDocuments, have int) int {
+	if limit <= 0 {
+		limit = fallback
+	}
+	if maxDocuments > 0 {
+		limit = min(limit, maxDocuments-have)
+	}
+	return limit
+}
+
+func collectLocalDocuments(opts SourceOptions) ([]Document, []string, error) {
 	info, err := os.Stat(opts.LocalDocsDir)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	if !info.IsDir() {
-		return nil, fmt.Errorf("%s is not a directory", opts.LocalDocsDir)
+		return nil, nil, fmt.Errorf("%s is not a directory", opts.LocalDocsDir)
 	}
 
 	var documents []Document
+	var notes []string
 	allowed := map[string]struct{}{}
 	for _, ext := range opts.IncludeExtensions {
 		allowed[strings.ToLower(ext)] = struct{}{}
 	}
+	ignorePatterns, err := readIgnoreFile(filepath.Join(opts.LocalDocsDir, RagIgnoreFile))
+	if err != nil {
+		return nil, nil, err
+	}
+	rules := parseIgnoreRules(append(append([]string(nil), opts.ExcludeGlobs...), ignorePatterns...))
 
 	err = filepath.WalkDir(opts.LocalDocsDir, func(path string, entry os.DirEntry, walkErr error) error {
 		if walkErr != nil {
 			return walkErr
 		}
+		rel, _ := filepath.Rel(opts.LocalDocsDir, path)
+		if rel != "." && ignored(rules, filepath.ToSlash(rel), entry.IsDir()) {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
 		if entry.IsDir() {
 			return nil
 		}
-		if _, ok := allowed[strings.ToLower(filepath.Ext(entry.Name()))]; !ok {
+		ext := strings.ToLower(filepath.Ext(entry.Name()))
+		_, ok := allowed[ext]
+		if ext == ".doc" {
+			// A .doc next to allowed .docx files is most likely meant to be ingested too, so
+			// say why it was left out instead of dropping it silently.
+			if _, docx := allowed[".docx"]; ok || docx {
+				notes = append(notes, fmt.Sprintf("skipped %s: %v", rel, ErrLegacyDoc))
+			}
 			return nil
 		}
+		if !ok {
+			return nil
+		}
+		if opts.MaxDocuments > 0 && len(documents) >= opts.MaxDocuments {
+			return errDocumentLimit
+		}
 
 		data, err := os.ReadFile(path)
 		if err != nil {
 			return err
 		}
-		rel, _ := filepath.Rel(opts.LocalDocsDir, path)
-		content := normalizeWhitespace(string(data))
+		var content string
+		var lines []int
+		switch ext {
+		case ".docx":
+			// Extracted paragraphs have no lines in the original file to map back to.
+			text, err := docxToText(data)
+			if err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+			content = NormalizeWhitespace(text, true)
+		default:
+			content, lines = normalizeWhitespaceLines(string(data), true)
+		}
 		documents = append(documents, Document{
-			ID:      slugify(rel),
+			ID:      Slugify(rel),
 			Title:   fmt.Sprintf("Local: %s", rel),
 			URI:     path,
 			Source:  "local-docs",
 			Content: content,
+			Lines:   lines,
 		})
 		return nil
 	})
 
-	return documents, err
+	return documents, notes, err
 }
 
-func collectRemoteDocuments(ctx context.Context, sources []RemoteSource) ([]Document, error) {
-	client := &http.Client{Timeout: 45 * time.Second}
+// collectRemoteDocuments fetches each source in order. A positive limit stops it after that many
+// documents, returning them with errDocumentLimit when sources are left.
+func collectRemoteDocuments(ctx context.Context, f *fetcher, sources []RemoteSource, limit int) ([]Document, []string, error) {
 	documents := make([]Document, 0, len(sources))
+	var notes []string
 	for _, src := range sources {
-		req, err := http.NewRequestWithContext(ctx, http.MethodGet, src.URL, nil)
-		if err != nil {
-			return nil, err
+		if limit > 0 && len(documents) >= limit {
+			return documents, notes, errDocumentLimit
 		}
-		resp, err := client.Do(req)
-		if err != nil {
-			return nil, fmt.Errorf("fetch %s: %w", src.URL, err)
+		body, err := f.getWithHeaders(ctx, src.URL, httpHeader(src.Headers))
+		var fetchErr *FetchError
+		if errors.Is(err, errDisallowedByRobots) || (errors.As(err, &fetchErr) && fetchErr.Kind == FetchErrorEmpty) {
+			notes = append(notes, fmt.Sprintf("skipped %s: %v", src.URL, err))
+			continue
 		}
-		body, err := io.ReadAll(resp.Body)
-		resp.Body.Close()
 		if err != nil {
-			return nil, fmt.Errorf("read %s: %w", src.URL, err)
-		}
-		if resp.StatusCode >= http.StatusBadRequest {
-			return nil, fmt.Errorf("fetch %s: status %d", src.URL, resp.StatusCode)
+			return nil, nil, err
 		}
 
-		text, err := convertPayload(string(body), src.Format)
+		text, err := convertPayload(string(body), src.Format, f.htmlOpts)
 		if err != nil {
-			return nil, fmt.Errorf("convert %s: %w", src.URL, err)
+			return nil, nil, fmt.Errorf("convert %s: %w", src.URL, err)
 		}
 
 		documents = append(documents, Document{
-			ID:      slugify(src.Name),
+			ID:      Slugify(src.Name),
 			Title:   src.Name,
 			URI:     src.URL,
 			Source:  src.Description,
 			Content: text,
+
+			fromHTML: src.Format == FormatHTML,
 		})
 	}
-	return documents, nil
+	return documents, notes, nil
 }
 
-func convertPayload(raw string, format RemoteFormat) (string, error) {
+func convertPayload(raw string, format RemoteFormat, htmlOpts HTMLOptions) (string, error) {
 	switch format {
 	case FormatMarkdown, FormatText, FormatTSV:
-		return normalizeWhitespace(raw), nil
+		return NormalizeWhitespace(raw, true), nil
 	case FormatHTML:
-		text, err := html2text.FromString(raw, html2text.Options{PrettyTables: true})
+		text, err := htmlToText(raw, htmlOpts)
+		if err != nil {
+			return "", err
+		}
+		return NormalizeWhitespace(text, true), nil
+	case FormatDOCX:
+		text, err := docxToText([]byte(raw))
 		if err != nil {
 			return "", err
 		}
-		return normalizeWhitespace(text), nil
+		return NormalizeWhitespace(text, true), nil
 	default:
 		return "", fmt.Errorf("unsupported format %s", format)
 	}
 }
 
-func normalizeWhitespace(input string) string {
+// NormalizeWhitespace normalizes line endings and trims every line. Blank lines are dropped unless
+// preserveBlankLines is set, in which case runs of them collapse to a single paragraph break.
+func NormalizeWhitespace(input string, preserveBlankLines bool) string {
+	normalized, _ := normalizeWhitespaceLines(input, preserveBlankLines)
+	return normalized
+}
+
+// normalizeWhitespaceLines is NormalizeWhitespace that also returns, for each output line, its
+// 1-based line number in input. A paragraph break takes the number of the line that follows it.
+func normalizeWhitespaceLines(input string, preserveBlankLines bool) (string, []int) {
 	cleaned := strings.ReplaceAll(input, "\r\n", "\n")
 	cleaned = strings.ReplaceAll(cleaned, "\r", "\n")
 	lines := strings.Split(cleaned, "\n")
 	trimmed := make([]string, 0, len(lines))
-	for _, line := range lines {
+	origins := make([]int, 0, len(lines))
+	blank := false
+	for i, line := range lines {
 		line = strings.TrimSpace(line)
 		if line == "" {
+			blank = true
 			continue
 		}
+		if blank && preserveBlankLines && len(trimmed) > 0 {
+			trimmed = append(trimmed, "")
+			origins = append(origins, i+1)
+		}
+		blank = false
 		trimmed = append(trimmed, line)
+		origins = append(origins, i+1)
 	}
-	return strings.Join(trimmed, "\n")
+	return strings.Join(trimmed, "\n"), origins
 }
 
 var slugMatcher = regexp.MustCompile(`[^a-z0-9]+`)
 
-func slugify(input string) string {
+// Slugify lowercases input and collapses every run of non-alphanumeric characters into a dash.
+// Document IDs are built with it, so the same source always maps to the same ID.
+func Slugify(input string) string {
 	lower := strings.ToLower(input)
 	slug := slugMatcher.ReplaceAllString(lower, "-")
 	slug = strings.Trim(slug, "-")
@@ -0,0 +1,104 @@
+package rag
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ErrLegacyDoc is returned for Word 97-2003 .doc files, whose binary format is not supported.
+var ErrLegacyDoc = errors.New("legacy .doc files are not supported; save the file as .docx")
+
+// maxDocxXMLBytes caps the decompressed size of word/document.xml to guard against zip bombs.
+const maxDocxXMLBytes = 64 << 20
+
+// docxSkipped are WordprocessingML elements whose content is left out: images and embedded
+// objects, deleted or moved-away text from tracked changes, and field instructions.
+var docxSkipped = map[string]bool{
+	"drawing":      true,
+	"pict":         true,
+	"object":       true,
+	"del":          true,
+	"moveFrom":     true,
+	"instrText":    true,
+	"rPrChange":    true,
+	"pPrChange":    true,
+	"sectPrChange": true,
+}
+
+// docxToText extracts the paragraph text of a .docx archive, one paragraph per line. Tracked
+// insertions are kept, so the result reads like the document with all changes accepted.
+func docxToText(data []byte) (string, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		if bytes.HasPrefix(data, []byte("\xd0\xcf\x11\xe0")) {
+			return "", ErrLegacyDoc
+		}
+		return "", fmt.Errorf("open docx: %w", err)
+	}
+	var document *zip.File
+	for _, file := range archive.File {
+		if file.Name == "word/document.xml" {
+			document = file
+			break
+		}
+	}
+	if document == nil {
+		return "", errors.New("docx has no word/document.xml")
+	}
+	rc, err := document.Open()
+	if err != nil {
+		return "", fmt.Errorf("open word/document.xml: %w", err)
+	}
+	defer rc.Close()
+	return docxParagraphs(io.LimitReader(rc, maxDocxXMLBytes))
+}
+
+// docxParagraphs walks word/document.xml, joining the runs of each w:p into a line.
+func docxParagraphs(r io.Reader) (string, error) {
+	decoder := xml.NewDecoder(r)
+	var out, paragraph strings.Builder
+	inText := false
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("parse word/document.xml: %w", err)
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch name := t.Name.Local; {
+			case docxSkipped[name]:
+				if err := decoder.Skip(); err != nil {
+					return "", fmt.Errorf("parse word/document.xml: %w", err)
+				}
+			case name == "t":
+				inText = true
+			case name == "tab":
+				paragraph.WriteByte('\t')
+			case name == "br" || name == "cr":
+				paragraph.WriteByte('\n')
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				out.WriteString(strings.TrimRight(paragraph.String(), " \t"))
+				out.WriteByte('\n')
+				paragraph.Reset()
+			}
+		case xml.CharData:
+			if inText {
+				paragraph.Write(t)
+			}
+		}
+	}
+	return strings.TrimRight(out.String(), "\n"), nil
+}
@@ -0,0 +1,60 @@
+package rag
+
+import (
+	"container/list"
+	"sync"
+)
+
+// embeddingCache is a fixed-size LRU of query embeddings. A nil cache stores nothing.
+type embeddingCache struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type embeddingCacheEntry struct {
+	key       string
+	embedding []float32
+}
+
+// newEmbeddingCache returns a cache holding up to size embeddings, or nil when size is not positive.
+func newEmbeddingCache(size int) *embeddingCache {
+	if size <= 0 {
+		return nil
+	}
+	return &embeddingCache{size: size, order: list.New(), entries: map[string]*list.Element{}}
+}
+
+func (c *embeddingCache) get(key string) ([]float32, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	return elem.Value.(*embeddingCacheEntry).embedding, true
+}
+
+func (c *embeddingCache) put(key string, embedding []float32) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[key]; ok {
+		elem.Value.(*embeddingCacheEntry).embedding = embedding
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&embeddingCacheEntry{key: key, embedding: embedding})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*embeddingCacheEntry).key)
+	}
+}
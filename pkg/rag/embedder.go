@@ -6,6 +6,8 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log"
+	"math"
 	"net/http"
 	"strings"
 	"time"
@@ -16,6 +18,20 @@ import (
 // Embedder converts text into vector representations.
 type Embedder interface {
 	Embed(ctx context.Context, texts []string) ([][]float32, error)
+	// EmbedOne embeds a single text, e.g. a query.
+	EmbedOne(ctx context.Context, text string) ([]float32, error)
+}
+
+// embedOne implements EmbedOne on top of a batch Embed call.
+func embedOne(ctx context.Context, embed func(context.Context, []string) ([][]float32, error), text string) ([]float32, error) {
+	embeddings, err := embed(ctx, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	if len(embeddings) == 0 || len(embeddings[0]) == 0 {
+		return nil, errors.New("empty embedding returned")
+	}
+	return embeddings[0], nil
 }
 
 // ChatClient generates answers from context-augmented prompts.
@@ -23,46 +39,162 @@ type ChatClient interface {
 	Complete(ctx context.Context, systemPrompt, prompt string, temperature float32) (string, error)
 }
 
-// NewEmbedder returns an embedder based on the configured provider.
+// GenerationLimits bound a chat completion. Zero values use the provider defaults.
+type GenerationLimits struct {
+	MaxTokens int
+	Timeout   time.Duration
+}
+
+func (l GenerationLimits) validate() error {
+	if l.MaxTokens < 0 {
+		return fmt.Errorf("max tokens must be positive, got %d", l.MaxTokens)
+	}
+	if l.Timeout < 0 {
+		return fmt.Errorf("chat timeout must not be negative, got %s", l.Timeout)
+	}
+	return nil
+}
+
+func (c ServiceConfig) generationLimits() GenerationLimits {
+	return GenerationLimits{MaxTokens: c.MaxTokens, Timeout: c.Timeout}
+}
+
+// NewEmbedder returns an embedder for the configured embedding provider, wrapped in a
+// FallbackEmbedder when a fallback provider is configured. Each provider retries transient
+// failures according to cfg.Retry before the fallback is tried. Set the wrapper's Dimension to
+// the index dimension when it is known.
 func NewEmbedder(cfg ServiceConfig) (Embedder, error) {
-	switch cfg.Provider {
+	primary, err := newProviderEmbedder(cfg)
+	if err != nil {
+		return nil, err
+	}
+	fallbackCfg, ok := cfg.fallbackConfig()
+	if !ok {
+		return primary, nil
+	}
+	secondary, err := newProviderEmbedder(fallbackCfg)
+	if err != nil {
+		return nil, fmt.Errorf("fallback embedder: %w", err)
+	}
+	return NewFallbackEmbedder(primary, secondary, 0), nil
+}
+
+func newProviderEmbedder(cfg ServiceConfig) (Embedder, error) {
+	var (
+		embedder Embedder
+		err      error
+	)
+	switch provider := cfg.EmbeddingBackend(); provider {
 	case ProviderOllama:
-		return NewOllamaEmbedder(cfg.OllamaBaseURL, cfg.EmbeddingModel)
+		embedder, err = NewOllamaEmbedder(cfg.OllamaBaseURL, cfg.EmbeddingModel)
 	case ProviderOpenAI:
-		return NewOpenAIEmbedder(cfg.OpenAIAPIKey, cfg.EmbeddingModel)
+		embedder, err = NewOpenAIEmbedder(cfg.OpenAIAPIKey, cfg.EmbeddingModel, cfg.EmbeddingDimensions)
+	case ProviderOpenAICompatible:
+		embedder, err = NewOpenAICompatibleEmbedder(cfg.OpenAIBaseURL, cfg.OpenAIAPIKey, cfg.EmbeddingModel, cfg.EmbeddingDimensions)
+	case ProviderLocal:
+		embedder, err = NewLocalEmbedder(cfg.LocalModelPath, cfg.ONNXRuntimeLib)
 	default:
-		return nil, fmt.Errorf("unsupported provider %s", cfg.Provider)
+		return nil, fmt.Errorf("unsupported provider %s", provider)
 	}
+	if err != nil {
+		return nil, err
+	}
+	return retryEmbedder{Embedder: embedder, policy: cfg.Retry}, nil
 }
 
-// NewChatClient returns a chat client for the configured provider.
+// NewChatClient returns a chat client for the configured chat provider, wrapped in a
+// FallbackChatClient when a fallback provider is configured. Each provider retries transient
+// failures according to cfg.Retry before the fallback is tried.
 func NewChatClient(cfg ServiceConfig) (ChatClient, error) {
-	switch cfg.Provider {
+	primary, err := newProviderChatClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	fallbackCfg, ok := cfg.fallbackConfig()
+	if !ok {
+		return primary, nil
+	}
+	secondary, err := newProviderChatClient(fallbackCfg)
+	if err != nil {
+		return nil, fmt.Errorf("fallback chat client: %w", err)
+	}
+	return NewFallbackChatClient(primary, secondary), nil
+}
+
+func newProviderChatClient(cfg ServiceConfig) (ChatClient, error) {
+	limits := cfg.generationLimits()
+	if err := limits.validate(); err != nil {
+		return nil, err
+	}
+	var (
+		client ChatClient
+		err    error
+	)
+	switch provider := cfg.ChatBackend(); provider {
 	case ProviderOllama:
-		return NewOllamaChatClient(cfg.OllamaBaseURL, cfg.ChatModel), nil
+		client = NewOllamaChatClient(cfg.OllamaBaseURL, cfg.ChatModel, limits)
 	case ProviderOpenAI:
-		return NewOpenAIChatClient(cfg.OpenAIAPIKey, cfg.ChatModel)
+		client, err = NewOpenAIChatClient(cfg.OpenAIAPIKey, cfg.ChatModel, limits)
+	case ProviderOpenAICompatible:
+		client, err = NewOpenAICompatibleChatClient(cfg.OpenAIBaseURL, cfg.OpenAIAPIKey, cfg.ChatModel, limits)
 	default:
-		return nil, fmt.Errorf("unsupported provider %s", cfg.Provider)
+		return nil, fmt.Errorf("unsupported provider %s", provider)
 	}
+	if err != nil {
+		return nil, err
+	}
+	return retryChatClient{ChatClient: client, policy: cfg.Retry}, nil
 }
 
 // OpenAIEmbedder implements Embedder using the OpenAI embeddings API.
 type OpenAIEmbedder struct {
-	client *openai.Client
-	model  string
+	client     *openai.Client
+	model      string
+	dimensions int
 }
 
-// NewOpenAIEmbedder constructs an embedder for the supplied model.
-func NewOpenAIEmbedder(apiKey, model string) (*OpenAIEmbedder, error) {
+// NewOpenAIEmbedder constructs an embedder for the supplied model. A positive dimensions shortens
+// the vectors of models that support it (text-embedding-3-*) and is ignored for other models.
+func NewOpenAIEmbedder(apiKey, model string, dimensions int) (*OpenAIEmbedder, error) {
 	if apiKey == "" {
 		return nil, errors.New("OPENAI_API_KEY is required")
 	}
 	if model == "" {
 		model = DefaultOpenAIEmbeddingModel
 	}
+	return newOpenAIEmbedder(openai.DefaultConfig(apiKey), model, dimensions), nil
+}
+
+// NewOpenAICompatibleEmbedder constructs an embedder for a server exposing the OpenAI embeddings API
+// at baseURL. The API key is optional since many self-hosted servers do not check it.
+func NewOpenAICompatibleEmbedder(baseURL, apiKey, model string, dimensions int) (*OpenAIEmbedder, error) {
+	if baseURL == "" {
+		return nil, errors.New("RAG_OPENAI_BASE_URL is required")
+	}
+	if model == "" {
+		return nil, errors.New("an embedding model is required")
+	}
+	return newOpenAIEmbedder(openAICompatibleConfig(baseURL, apiKey), model, dimensions), nil
+}
+
+func newOpenAIEmbedder(cfg openai.ClientConfig, model string, dimensions int) *OpenAIEmbedder {
+	if dimensions > 0 && !supportsEmbeddingDimensions(model) {
+		log.Printf("RAG_EMBEDDING_DIMENSIONS ignored: %s does not support custom dimensions", model)
+		dimensions = 0
+	}
+	return &OpenAIEmbedder{client: openai.NewClientWithConfig(cfg), model: model, dimensions: dimensions}
+}
+
+// openAICompatibleConfig points the OpenAI client at baseURL, trimming a trailing slash.
+func openAICompatibleConfig(baseURL, apiKey string) openai.ClientConfig {
 	cfg := openai.DefaultConfig(apiKey)
-	return &OpenAIEmbedder{client: openai.NewClientWithConfig(cfg), model: model}, nil
+	cfg.BaseURL = strings.TrimRight(baseURL, "/")
+	return cfg
+}
+
+// supportsEmbeddingDimensions reports whether model accepts the dimensions parameter.
+func supportsEmbeddingDimensions(model string) bool {
+	return strings.HasPrefix(model, "text-embedding-3-")
 }
 
 // Embed converts one or more texts into embedding vectors.
@@ -71,13 +203,15 @@ func (e *OpenAIEmbedder) Embed(ctx context.Context, texts []string) ([][]float32
 		return nil, nil
 	}
 	req := openai.EmbeddingRequest{
-		Model: openai.EmbeddingModel(e.model),
-		Input: texts,
+		Model:      openai.EmbeddingModel(e.model),
+		Input:      texts,
+		Dimensions: e.dimensions,
 	}
 	resp, err := e.client.CreateEmbeddings(ctx, req)
 	if err != nil {
 		return nil, err
 	}
+	recordEmbeddingUsage(ctx, e.model, resp.Usage.PromptTokens)
 	embeddings := make([][]float32, len(resp.Data))
 	for i, data := range resp.Data {
 		embeddings[i] = data.Embedding
@@ -85,51 +219,135 @@ func (e *OpenAIEmbedder) Embed(ctx context.Context, texts []string) ([][]float32
 	return embeddings, nil
 }
 
+// EmbedOne embeds a single text.
+func (e *OpenAIEmbedder) EmbedOne(ctx context.Context, text string) ([]float32, error) {
+	return embedOne(ctx, e.Embed, text)
+}
+
 // OpenAIChatClient implements ChatClient using the Chat Completions API.
 type OpenAIChatClient struct {
-	client *openai.Client
-	model  string
+	client    *openai.Client
+	model     string
+	maxTokens int
+	timeout   time.Duration
+	// streamUsage asks for token usage at the end of streamed completions. Only the official API
+	// is known to accept the option.
+	streamUsage bool
 }
 
 // NewOpenAIChatClient creates a chat completion client.
-func NewOpenAIChatClient(apiKey, model string) (*OpenAIChatClient, error) {
+func NewOpenAIChatClient(apiKey, model string, limits GenerationLimits) (*OpenAIChatClient, error) {
 	if apiKey == "" {
 		return nil, errors.New("OPENAI_API_KEY is required")
 	}
 	if model == "" {
 		model = DefaultOpenAIChatModel
 	}
-	cfg := openai.DefaultConfig(apiKey)
-	return &OpenAIChatClient{client: openai.NewClientWithConfig(cfg), model: model}, nil
+	client := newOpenAIChatClient(openai.DefaultConfig(apiKey), model, limits)
+	client.streamUsage = true
+	return client, nil
+}
+
+// NewOpenAICompatibleChatClient creates a chat completion client for a server exposing the OpenAI
+// Chat Completions API at baseURL. The API key is optional.
+func NewOpenAICompatibleChatClient(baseURL, apiKey, model string, limits GenerationLimits) (*OpenAIChatClient, error) {
+	if baseURL == "" {
+		return nil, errors.New("RAG_OPENAI_BASE_URL is required")
+	}
+	if model == "" {
+		return nil, errors.New("a chat model is required")
+	}
+	return newOpenAIChatClient(openAICompatibleConfig(baseURL, apiKey), model, limits), nil
+}
+
+func newOpenAIChatClient(cfg openai.ClientConfig, model string, limits GenerationLimits) *OpenAIChatClient {
+	if limits.MaxTokens <= 0 {
+		limits.MaxTokens = DefaultOpenAIMaxTokens
+	}
+	if limits.Timeout <= 0 {
+		limits.Timeout = DefaultOpenAIChatTimeout
+	}
+	return &OpenAIChatClient{
+		client:    openai.NewClientWithConfig(cfg),
+		model:     model,
+		maxTokens: limits.MaxTokens,
+		timeout:   limits.Timeout,
+	}
 }
 
 // Complete generates an answer using the provided prompt.
 func (c *OpenAIChatClient) Complete(ctx context.Context, systemPrompt, prompt string, temperature float32) (string, error) {
-	if temperature == 0 {
-		temperature = 0.2
-	}
 	req := openai.ChatCompletionRequest{
 		Model: c.model,
 		Messages: []openai.ChatCompletionMessage{
 			{Role: openai.ChatMessageRoleSystem, Content: systemPrompt},
 			{Role: openai.ChatMessageRoleUser, Content: prompt},
 		},
-		Temperature: temperature,
-		MaxTokens:   800,
+		Temperature: openAITemperature(temperature),
+		MaxTokens:   maxTokensFor(ctx, c.maxTokens),
 	}
-	ctx, cancel := context.WithTimeout(ctx, 45*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
 	defer cancel()
 
 	resp, err := c.client.CreateChatCompletion(ctx, req)
 	if err != nil {
 		return "", err
 	}
+	recordChatUsage(ctx, c.model, resp.Usage.PromptTokens, resp.Usage.CompletionTokens)
 	if len(resp.Choices) == 0 {
 		return "", fmt.Errorf("no chat completion choices returned")
 	}
 	return resp.Choices[0].Message.Content, nil
 }
 
+// openAITemperature maps temperature for the request struct, whose zero value is omitted from the
+// JSON and would fall back to the API default of 1. The smallest positive float32 is sent for 0
+// instead, which is effectively greedy decoding.
+func openAITemperature(temperature float32) float32 {
+	if temperature <= 0 {
+		return math.SmallestNonzeroFloat32
+	}
+	return temperature
+}
+
+// post sends a chat request and returns the successful response, whose body the caller closes.
+func (c *OllamaChatClient) post(ctx context.Context, systemPrompt, prompt string, temperature float32, stream bool) (*http.Response, error) {
+	// Sampling settings belong in options; Ollama ignores them at the top level.
+	options := map[string]interface{}{"temperature": temperature}
+	if maxTokens := maxTokensFor(ctx, c.maxTokens); maxTokens > 0 {
+		options["num_predict"] = maxTokens
+	}
+	payload := map[string]interface{}{
+		"model": c.model,
+		"messages": []map[string]string{
+			{"role": "system", "content": systemPrompt},
+			{"role": "user", "content": prompt},
+		},
+		"stream":  stream,
+		"options": options,
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/api/chat", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		resp.Body.Close()
+		return nil, &statusError{op: "ollama chat", code: resp.StatusCode, status: resp.Status}
+	}
+	return resp, nil
+}
+
 // OllamaEmbedder implements Embedder using a local Ollama instance.
 type OllamaEmbedder struct {
 	baseURL    string
@@ -176,16 +394,18 @@ func (e *OllamaEmbedder) Embed(ctx context.Context, texts []string) ([][]float32
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode >= http.StatusBadRequest {
-		return nil, fmt.Errorf("ollama embed failed: %s", resp.Status)
+		return nil, &statusError{op: "ollama embed", code: resp.StatusCode, status: resp.Status}
 	}
 
 	var parsed struct {
-		Embeddings [][]float64 `json:"embeddings"`
-		Embedding  []float64   `json:"embedding"`
+		Embeddings      [][]float64 `json:"embeddings"`
+		Embedding       []float64   `json:"embedding"`
+		PromptEvalCount int         `json:"prompt_eval_count"`
 	}
 	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
 		return nil, err
 	}
+	recordEmbeddingUsage(ctx, e.model, parsed.PromptEvalCount)
 
 	float32s := func(src []float64) []float32 {
 		dst := make([]float32, len(src))
@@ -209,70 +429,56 @@ func (e *OllamaEmbedder) Embed(ctx context.Context, texts []string) ([][]float32
 	}
 }
 
+// EmbedOne embeds a single text.
+func (e *OllamaEmbedder) EmbedOne(ctx context.Context, text string) ([]float32, error) {
+	return embedOne(ctx, e.Embed, text)
+}
+
 // OllamaChatClient talks to Ollama's /api/chat endpoint.
 type OllamaChatClient struct {
 	baseURL    string
 	model      string
+	maxTokens  int
 	httpClient *http.Client
 }
 
-// NewOllamaChatClient constructs a chat client for Ollama.
-func NewOllamaChatClient(baseURL, model string) *OllamaChatClient {
+// NewOllamaChatClient constructs a chat client for Ollama. A zero MaxTokens leaves the model default.
+func NewOllamaChatClient(baseURL, model string, limits GenerationLimits) *OllamaChatClient {
 	if model == "" {
 		model = DefaultOllamaChatModel
 	}
 	if baseURL == "" {
 		baseURL = DefaultOllamaBaseURL
 	}
+	if limits.Timeout <= 0 {
+		limits.Timeout = DefaultOllamaChatTimeout
+	}
 	return &OllamaChatClient{
 		baseURL:    strings.TrimRight(baseURL, "/"),
 		model:      model,
-		httpClient: &http.Client{Timeout: 60 * time.Second},
+		maxTokens:  limits.MaxTokens,
+		httpClient: &http.Client{Timeout: limits.Timeout},
 	}
 }
 
 func (c *OllamaChatClient) Complete(ctx context.Context, systemPrompt, prompt string, temperature float32) (string, error) {
-	if temperature == 0 {
-		temperature = 0.2
-	}
-	payload := map[string]interface{}{
-		"model": c.model,
-		"messages": []map[string]string{
-			{"role": "system", "content": systemPrompt},
-			{"role": "user", "content": prompt},
-		},
-		"stream":      false,
-		"temperature": temperature,
-	}
-	body, err := json.Marshal(payload)
-	if err != nil {
-		return "", err
-	}
-
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/api/chat", bytes.NewReader(body))
-	if err != nil {
-		return "", err
-	}
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.post(ctx, systemPrompt, prompt, temperature, false)
 	if err != nil {
 		return "", err
 	}
 	defer resp.Body.Close()
-	if resp.StatusCode >= http.StatusBadRequest {
-		return "", fmt.Errorf("ollama chat failed: %s", resp.Status)
-	}
 
 	var parsed struct {
 		Message *struct {
 			Content string `json:"content"`
 		} `json:"message"`
 		Response string `json:"response"`
+		ollamaUsage
 	}
 	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
 		return "", err
 	}
+	recordChatUsage(ctx, c.model, parsed.PromptEvalCount, parsed.EvalCount)
 
 	switch {
 	case parsed.Message != nil:
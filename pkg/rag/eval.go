@@ -0,0 +1,109 @@
+package rag
+
+import (
+	"context"
+	"strings"
+)
+
+// evalCutoffs are the k values EvalReport.RecallAtK is computed for.
+var evalCutoffs = []int{1, 3, 5, 10}
+
+// EvalCase is a question with the documents a good retrieval should return.
+type EvalCase struct {
+	Question            string   `json:"question"`
+	ExpectedDocumentIDs []string `json:"expectedDocumentIds"`
+}
+
+// EvalCaseResult reports retrieval quality for one case.
+type EvalCaseResult struct {
+	Question string `json:"question"`
+	// FirstHitRank is the 1-based rank of the first chunk from an expected document, 0 when none matched.
+	FirstHitRank int `json:"firstHitRank"`
+	// RetrievedDocumentIDs lists the distinct documents retrieved, best first.
+	RetrievedDocumentIDs []string `json:"retrievedDocumentIds"`
+	Error                string   `json:"error,omitempty"`
+}
+
+// EvalReport aggregates retrieval metrics over the evaluated cases. Cases that failed to embed
+// are counted in Failed and excluded from the averages.
+type EvalReport struct {
+	Cases  int `json:"cases"`
+	Failed int `json:"failed"`
+	// RecallAtK maps k to the mean fraction of expected documents found in the top k chunks.
+	RecallAtK map[int]float64 `json:"recallAtK"`
+	// MRR is the mean reciprocal rank of the first relevant chunk.
+	MRR     float64          `json:"mrr"`
+	Results []EvalCaseResult `json:"results"`
+}
+
+// Evaluate runs retrieval for each case and computes recall@k for k in 1, 3, 5, 10 and MRR over the
+// top 10 chunks. Questions are embedded with queryPrefix prepended. No chat completions are made.
+func Evaluate(ctx context.Context, store Store, embedder Embedder, queryPrefix string, cases []EvalCase) EvalReport {
+	maxK := evalCutoffs[len(evalCutoffs)-1]
+	report := EvalReport{Cases: len(cases), RecallAtK: map[int]float64{}}
+	scored := 0
+	for _, c := range cases {
+		result := EvalCaseResult{Question: c.Question}
+		embedding, err := embedder.EmbedOne(ctx, queryPrefix+strings.TrimSpace(c.Question))
+		if err != nil {
+			result.Error = err.Error()
+			report.Failed++
+			report.Results = append(report.Results, result)
+			continue
+		}
+
+		expected := map[string]struct{}{}
+		for _, id := range c.ExpectedDocumentIDs {
+			expected[id] = struct{}{}
+		}
+		matches := store.Search(embedding, maxK)
+		if vs, ok := store.(*VectorStore); ok {
+			vs.warnDimensionMismatch(ctx, len(embedding))
+		}
+		found := map[string]struct{}{}
+		seen := map[string]struct{}{}
+		cutoff := 0
+		for rank, match := range matches {
+			docID := match.Chunk.DocumentID
+			if _, ok := seen[docID]; !ok {
+				seen[docID] = struct{}{}
+				result.RetrievedDocumentIDs = append(result.RetrievedDocumentIDs, docID)
+			}
+			if _, ok := expected[docID]; ok {
+				found[docID] = struct{}{}
+				if result.FirstHitRank == 0 {
+					result.FirstHitRank = rank + 1
+				}
+			}
+			// Record recall at each cutoff reached after this rank.
+			for cutoff < len(evalCutoffs) && evalCutoffs[cutoff] == rank+1 {
+				report.RecallAtK[evalCutoffs[cutoff]] += fraction(len(found), len(expected))
+				cutoff++
+			}
+		}
+		// Fewer matches than a cutoff: recall stays at its final value.
+		for ; cutoff < len(evalCutoffs); cutoff++ {
+			report.RecallAtK[evalCutoffs[cutoff]] += fraction(len(found), len(expected))
+		}
+		if result.FirstHitRank > 0 {
+			report.MRR += 1 / float64(result.FirstHitRank)
+		}
+		scored++
+		report.Results = append(report.Results, result)
+	}
+
+	if scored > 0 {
+		for k := range report.RecallAtK {
+			report.RecallAtK[k] /= float64(scored)
+		}
+		report.MRR /= float64(scored)
+	}
+	return report
+}
+
+func fraction(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) / float64(total)
+}
@@ -0,0 +1,90 @@
+package rag
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync/atomic"
+)
+
+// FallbackEmbedder embeds with Primary and retries failed calls, e.g. after a rate limit, with
+// Secondary. Vectors from a model of another dimension would make search meaningless, so the
+// secondary's results are only returned when they match the index dimension.
+type FallbackEmbedder struct {
+	Primary   Embedder
+	Secondary Embedder
+	// Dimension is the index's embedding dimension. Zero uses the dimension of the primary's
+	// last successful result; until there is one, the secondary is not tried.
+	Dimension int
+	learned   atomic.Int64
+}
+
+// NewFallbackEmbedder wraps primary so failures fall back to secondary. dimension is the index
+// embedding dimension, or zero when it is not known yet.
+func NewFallbackEmbedder(primary, secondary Embedder, dimension int) *FallbackEmbedder {
+	return &FallbackEmbedder{Primary: primary, Secondary: secondary, Dimension: dimension}
+}
+
+// Embed embeds texts with the primary, falling back to the secondary on error.
+func (f *FallbackEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	embeddings, err := f.Primary.Embed(ctx, texts)
+	if err == nil {
+		if len(embeddings) > 0 {
+			f.learned.Store(int64(len(embeddings[0])))
+		}
+		return embeddings, nil
+	}
+	if ctx.Err() != nil {
+		return nil, err
+	}
+	want := f.Dimension
+	if want == 0 {
+		want = int(f.learned.Load())
+	}
+	if want == 0 {
+		return nil, fmt.Errorf("%w (fallback embedder not used: the index embedding dimension is unknown)", err)
+	}
+
+	log.Printf("rag: primary embedder failed, trying fallback: %v", err)
+	fallback, fallbackErr := f.Secondary.Embed(ctx, texts)
+	if fallbackErr != nil {
+		return nil, fmt.Errorf("primary embedder: %w; fallback embedder: %w", err, fallbackErr)
+	}
+	for _, embedding := range fallback {
+		if len(embedding) != want {
+			return nil, fmt.Errorf("primary embedder: %w; fallback embedder: %w: it returned %d dimensions, the index has %d",
+				err, ErrDimensionMismatch, len(embedding), want)
+		}
+	}
+	return fallback, nil
+}
+
+// EmbedOne embeds a single text.
+func (f *FallbackEmbedder) EmbedOne(ctx context.Context, text string) ([]float32, error) {
+	return embedOne(ctx, f.Embed, text)
+}
+
+// FallbackChatClient completes with Primary and retries failed calls with Secondary.
+type FallbackChatClient struct {
+	Primary   ChatClient
+	Secondary ChatClient
+}
+
+// NewFallbackChatClient wraps primary so failures fall back to secondary.
+func NewFallbackChatClient(primary, secondary ChatClient) *FallbackChatClient {
+	return &FallbackChatClient{Primary: primary, Secondary: secondary}
+}
+
+// Complete asks the primary, falling back to the secondary on error.
+func (f *FallbackChatClient) Complete(ctx context.Context, systemPrompt, prompt string, temperature float32) (string, error) {
+	answer, err := f.Primary.Complete(ctx, systemPrompt, prompt, temperature)
+	if err == nil || ctx.Err() != nil {
+		return answer, err
+	}
+	log.Printf("rag: primary chat client failed, trying fallback: %v", err)
+	answer, fallbackErr := f.Secondary.Complete(ctx, systemPrompt, prompt, temperature)
+	if fallbackErr != nil {
+		return "", fmt.Errorf("primary chat client: %w; fallback chat client: %w", err, fallbackErr)
+	}
+	return answer, nil
+}
@@ -0,0 +1,307 @@
+package rag
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultUserAgent identifies the ingestion crawler to remote hosts.
+const DefaultUserAgent = "tripframe-rag/1.0 (+https://github.com/ghizdavur/rag)"
+
+const (
+	// DefaultFetchTimeout bounds a single remote request when SourceOptions.FetchTimeout is zero.
+	DefaultFetchTimeout = 45 * time.Second
+	// DefaultMaxFetchBytes caps a response body when SourceOptions.MaxBytes is zero.
+	DefaultMaxFetchBytes int64 = 20 << 20
+)
+
+const (
+	// maxFetchRedirects caps redirects followed for a single request.
+	maxFetchRedirects = 5
+)
+
+var (
+	// errDisallowedByRobots marks URLs skipped because of robots.txt.
+	errDisallowedByRobots = errors.New("disallowed by robots.txt")
+	errTooManyRedirects   = fmt.Errorf("stopped after %d redirects", maxFetchRedirects)
+	errExceededMaxBytes   = errors.New("exceeded max bytes")
+)
+
+// FetchErrorKind classifies why a remote fetch failed.
+type FetchErrorKind string
+
+const (
+	FetchErrorDNS      FetchErrorKind = "dns"
+	FetchErrorTimeout  FetchErrorKind = "timeout"
+	FetchErrorStatus   FetchErrorKind = "status"
+	FetchErrorEmpty    FetchErrorKind = "empty"
+	FetchErrorRedirect FetchErrorKind = "redirect"
+	FetchErrorTooLarge FetchErrorKind = "too_large"
+	FetchErrorNetwork  FetchErrorKind = "network"
+)
+
+// FetchError describes a failed remote fetch after retries.
+type FetchError struct {
+	URL  string
+	Kind FetchErrorKind
+	// Status is the HTTP status code for FetchErrorStatus.
+	Status int
+	Err    error
+}
+
+func (e *FetchError) Error() string {
+	switch e.Kind {
+	case FetchErrorDNS:
+		return fmt.Sprintf("fetch %s: DNS lookup failed: %v", e.URL, e.Err)
+	case FetchErrorTimeout:
+		return fmt.Sprintf("fetch %s: timed out: %v", e.URL, e.Err)
+	case FetchErrorStatus:
+		return fmt.Sprintf("fetch %s: status %d", e.URL, e.Status)
+	case FetchErrorEmpty:
+		return fmt.Sprintf("fetch %s: empty response body", e.URL)
+	default:
+		return fmt.Sprintf("fetch %s: %v", e.URL, e.Err)
+	}
+}
+
+func (e *FetchError) Unwrap() error {
+	return e.Err
+}
+
+// retryable reports whether another attempt may succeed.
+func (e *FetchError) retryable() bool {
+	switch e.Kind {
+	case FetchErrorTimeout, FetchErrorNetwork:
+		return true
+	case FetchErrorStatus:
+		return e.Status == http.StatusTooManyRequests || e.Status >= http.StatusInternalServerError
+	default:
+		return false
+	}
+}
+
+// fetcher performs polite GET requests: it sets the User-Agent, honours robots.txt,
+// and spaces out requests to the same host.
+type fetcher struct {
+	client       *http.Client
+	userAgent    string
+	crawlDelay   time.Duration
+	ignoreRobots bool
+	robots       map[string]*robotsRules
+	lastFetch    map[string]time.Time
+	maxBytes     int64
+	// headers are added to every request after the User-Agent.
+	headers http.Header
+	// htmlOpts is applied by every source converting fetched HTML.
+	htmlOpts HTMLOptions
+	// retry governs repeated attempts after transient failures.
+	retry RetryPolicy
+}
+
+func newFetcher(opts SourceOptions) *fetcher {
+	userAgent := opts.UserAgent
+	if userAgent == "" {
+		userAgent = DefaultUserAgent
+	}
+	timeout := opts.FetchTimeout
+	if timeout <= 0 {
+		timeout = DefaultFetchTimeout
+	}
+	maxBytes := opts.MaxBytes
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxFetchBytes
+	}
+	client := &http.Client{
+		Timeout: timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxFetchRedirects {
+				return errTooManyRedirects
+			}
+			return nil
+		},
+	}
+	return &fetcher{
+		client:       client,
+		userAgent:    userAgent,
+		crawlDelay:   opts.CrawlDelay,
+		ignoreRobots: opts.IgnoreRobots,
+		robots:       map[string]*robotsRules{},
+		lastFetch:    map[string]time.Time{},
+		maxBytes:     maxBytes,
+		headers:      httpHeader(opts.HTTPHeaders),
+		htmlOpts:     opts.HTML,
+		retry:        opts.Retry,
+	}
+}
+
+// httpHeader converts a header map to http.Header, canonicalizing the names.
+func httpHeader(values map[string]string) http.Header {
+	if len(values) == 0 {
+		return nil
+	}
+	header := make(http.Header, len(values))
+	for name, value := range values {
+		header.Set(name, value)
+	}
+	return header
+}
+
+// get downloads rawURL, returning errDisallowedByRobots when robots.txt forbids it. Transient
+// failures are retried with backoff; other failures are returned as *FetchError.
+func (f *fetcher) get(ctx context.Context, rawURL string) ([]byte, error) {
+	return f.getWithHeaders(ctx, rawURL, nil)
+}
+
+// getWithHeaders is get with extra request headers that override the fetcher's own.
+func (f *fetcher) getWithHeaders(ctx context.Context, rawURL string, header http.Header) ([]byte, error) {
+	target, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	rules := f.rulesFor(ctx, target)
+	if !f.ignoreRobots && !rules.allowed(target.EscapedPath()+querySuffix(target)) {
+		return nil, errDisallowedByRobots
+	}
+	if err := f.wait(ctx, target.Host, rules); err != nil {
+		return nil, err
+	}
+
+	var body []byte
+	err = doWithRetry(ctx, f.retry, func(ctx context.Context) error {
+		var fetchErr *FetchError
+		body, fetchErr = f.attempt(ctx, rawURL, header)
+		switch {
+		case fetchErr == nil:
+			return nil
+		case !fetchErr.retryable():
+			return permanent(fetchErr)
+		default:
+			return fetchErr
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// attempt performs one GET and classifies any failure.
+func (f *fetcher) attempt(ctx context.Context, rawURL string, header http.Header) ([]byte, *FetchError) {
+	body, status, err := f.do(ctx, rawURL, header)
+	if err != nil {
+		return nil, &FetchError{URL: rawURL, Kind: classifyFetchError(err), Err: err}
+	}
+	if status < http.StatusOK || status >= http.StatusMultipleChoices {
+		return nil, &FetchError{URL: rawURL, Kind: FetchErrorStatus, Status: status}
+	}
+	if len(strings.TrimSpace(string(body))) == 0 {
+		return nil, &FetchError{URL: rawURL, Kind: FetchErrorEmpty}
+	}
+	return body, nil
+}
+
+func classifyFetchError(err error) FetchErrorKind {
+	if errors.Is(err, errTooManyRedirects) {
+		return FetchErrorRedirect
+	}
+	if errors.Is(err, errExceededMaxBytes) {
+		return FetchErrorTooLarge
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && !dnsErr.IsTimeout {
+		return FetchErrorDNS
+	}
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return FetchErrorTimeout
+	}
+	return FetchErrorNetwork
+}
+
+// do issues a single GET without robots or delay checks. The request carries the User-Agent, then
+// the fetcher's headers, then header, each replacing same-named values before it. Bodies larger
+// than the fetcher's byte cap fail with errExceededMaxBytes.
+func (f *fetcher) do(ctx context.Context, rawURL string, header http.Header) ([]byte, int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("User-Agent", f.userAgent)
+	for _, extra := range []http.Header{f.headers, header} {
+		for key, values := range extra {
+			req.Header[key] = values
+		}
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, f.maxBytes+1))
+	if err != nil {
+		return nil, resp.StatusCode, err
+	}
+	if int64(len(body)) > f.maxBytes {
+		return nil, resp.StatusCode, fmt.Errorf("%w (%d)", errExceededMaxBytes, f.maxBytes)
+	}
+	return body, resp.StatusCode, nil
+}
+
+// rulesFor returns the cached robots.txt rules for the URL's host, fetching them once.
+// Missing or unreachable robots files allow everything.
+func (f *fetcher) rulesFor(ctx context.Context, target *url.URL) *robotsRules {
+	if f.ignoreRobots {
+		return nil
+	}
+	key := target.Scheme + "://" + target.Host
+	if rules, ok := f.robots[key]; ok {
+		return rules
+	}
+	var rules *robotsRules
+	body, status, err := f.do(ctx, key+"/robots.txt", nil)
+	if err == nil && status == http.StatusOK {
+		rules = parseRobots(string(body), f.userAgent)
+	}
+	f.robots[key] = rules
+	f.lastFetch[target.Host] = time.Now()
+	return rules
+}
+
+// wait sleeps until the host's crawl delay has elapsed, honouring cancellation.
+func (f *fetcher) wait(ctx context.Context, host string, rules *robotsRules) error {
+	delay := f.crawlDelay
+	if rules != nil && rules.crawlDelay > delay {
+		delay = rules.crawlDelay
+	}
+	defer func() { f.lastFetch[host] = time.Now() }()
+	last, ok := f.lastFetch[host]
+	if !ok || delay <= 0 {
+		return nil
+	}
+	remaining := delay - time.Since(last)
+	if remaining <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(remaining)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func querySuffix(u *url.URL) string {
+	if u.RawQuery == "" {
+		return ""
+	}
+	return "?" + u.RawQuery
+}
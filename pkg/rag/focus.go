@@ -0,0 +1,70 @@
+package rag
+
+import (
+	"strings"
+	"unicode"
+)
+
+// maxFocusTerms caps the terms listed in a prompt's focus line.
+const maxFocusTerms = 8
+
+// focusFillerWords are common in questions but rarely name what is being asked about. They are
+// dropped on top of keywordStopWords.
+var focusFillerWords = map[string]struct{}{
+	"about": {}, "all": {}, "any": {}, "as": {}, "at": {}, "be": {}, "been": {}, "between": {},
+	"but": {}, "by": {}, "could": {}, "did": {}, "difference": {}, "each": {}, "explain": {},
+	"get": {}, "give": {}, "happen": {}, "has": {}, "have": {}, "if": {}, "into": {}, "its": {},
+	"just": {}, "know": {}, "like": {}, "make": {}, "me": {}, "mean": {}, "means": {}, "more": {},
+	"my": {}, "need": {}, "not": {}, "our": {}, "please": {}, "show": {}, "so": {}, "some": {},
+	"tell": {}, "than": {}, "that": {}, "their": {}, "them": {}, "then": {}, "there": {},
+	"these": {}, "they": {}, "this": {}, "those": {}, "use": {}, "using": {}, "want": {}, "was": {},
+	"were": {}, "will": {}, "work": {}, "works": {}, "would": {}, "your": {},
+}
+
+// focusFillerAdverbs qualify a question without naming its subject. The list is explicit because
+// "-ly" words such as "hourly" or "monthly" are often the point of a question.
+var focusFillerAdverbs = map[string]struct{}{
+	"actually": {}, "already": {}, "also": {}, "basically": {}, "correctly": {}, "currently": {},
+	"exactly": {}, "generally": {}, "likely": {}, "mostly": {}, "normally": {}, "only": {},
+	"possibly": {}, "probably": {}, "properly": {}, "quickly": {}, "really": {}, "simply": {},
+	"specifically": {}, "still": {}, "typically": {}, "usually": {},
+}
+
+// extractKeyTerms returns the question's likely subject terms in order of appearance: words that
+// are not stop words, filler words or adverbs, or bare numbers. Identifiers keep their case and inner
+// punctuation, so "SP-API" and "getOrders" survive intact. Duplicates are dropped case-insensitively.
+func extractKeyTerms(question string) []string {
+	var terms []string
+	seen := map[string]struct{}{}
+	for _, field := range strings.Fields(question) {
+		term := strings.TrimFunc(field, func(r rune) bool { return !isWordRune(r) })
+		lower := strings.ToLower(term)
+		if len([]rune(term)) < 2 || !strings.ContainsFunc(term, unicode.IsLetter) {
+			continue
+		}
+		if _, stop := keywordStopWords[lower]; stop {
+			continue
+		}
+		if _, filler := focusFillerWords[lower]; filler {
+			continue
+		}
+		if _, adverb := focusFillerAdverbs[lower]; adverb {
+			continue
+		}
+		if _, dup := seen[lower]; dup {
+			continue
+		}
+		seen[lower] = struct{}{}
+		terms = append(terms, term)
+	}
+	return terms
+}
+
+// focusPrompt is the "Focus terms" line for question, or "" when it has no key terms.
+func focusPrompt(question string) string {
+	terms := extractKeyTerms(question)
+	if len(terms) == 0 {
+		return ""
+	}
+	return "Focus terms: " + strings.Join(terms[:min(len(terms), maxFocusTerms)], ", ") + "\n\n"
+}
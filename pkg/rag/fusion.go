@@ -0,0 +1,35 @@
+package rag
+
+import "sort"
+
+// DefaultRRFK is the usual reciprocal rank fusion constant; larger values flatten the advantage of top ranks.
+const DefaultRRFK = 60
+
+// FuseResults merges ranked result lists with reciprocal rank fusion. Chunks are deduplicated by
+// ID and scored by the sum of 1/(k+rank) over every list they appear in, rank being 1-based; the
+// fused score replaces Score and the other fields come from the first occurrence. Results are
+// sorted by fused score, with ties kept in order of first appearance. A k of zero or less uses
+// DefaultRRFK.
+func FuseResults(lists [][]SearchResult, k int) []SearchResult {
+	if k <= 0 {
+		k = DefaultRRFK
+	}
+	index := map[string]int{}
+	var fused []SearchResult
+	for _, list := range lists {
+		for rank, match := range list {
+			contribution := 1 / float64(k+rank+1)
+			if i, ok := index[match.Chunk.ID]; ok {
+				fused[i].Score += contribution
+				continue
+			}
+			index[match.Chunk.ID] = len(fused)
+			match.Score = contribution
+			fused = append(fused, match)
+		}
+	}
+	sort.SliceStable(fused, func(i, j int) bool {
+		return fused[i].Score > fused[j].Score
+	})
+	return fused
+}
@@ -0,0 +1,143 @@
+package rag
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// GitHubSource declares repository files to ingest.
+type GitHubSource struct {
+	Owner string
+	Repo  string
+	// Ref is a branch, tag, or commit; empty uses the default branch.
+	Ref string
+	// PathGlobs restricts files by repository path (supports "**"); empty matches everything.
+	PathGlobs []string
+	// IncludeExtensions restricts files by extension; empty matches everything.
+	IncludeExtensions []string
+}
+
+const (
+	githubAPIBaseURL = "https://api.github.com"
+	githubRawBaseURL = "https://raw.githubusercontent.com"
+	// githubMaxFileBytes skips generated or vendored blobs that would swamp the index.
+	githubMaxFileBytes = 1 << 20
+)
+
+type githubTree struct {
+	Tree []struct {
+		Path string `json:"path"`
+		Type string `json:"type"`
+		Size int    `json:"size"`
+	} `json:"tree"`
+	Truncated bool `json:"truncated"`
+}
+
+// collectGitHubDocuments lists the repository tree and downloads each matching file. A positive
+// limit stops it after that many documents, returning them with errDocumentLimit when matching
+// files are left.
+func collectGitHubDocuments(ctx context.Context, f *fetcher, src GitHubSource, token string, limit int) ([]Document, []string, error) {
+	if src.Owner == "" || src.Repo == "" {
+		return nil, nil, fmt.Errorf("github source requires owner and repo")
+	}
+	ref := src.Ref
+	if ref == "" {
+		ref = "HEAD"
+	}
+	header := http.Header{}
+	if token != "" {
+		header.Set("Authorization", "Bearer "+token)
+	}
+
+	treeHeader := header.Clone()
+	treeHeader.Set("Accept", "application/vnd.github+json")
+	treeURL := fmt.Sprintf("%s/repos/%s/%s/git/trees/%s?recursive=1", githubAPIBaseURL, src.Owner, src.Repo, ref)
+	body, status, err := f.do(ctx, treeURL, treeHeader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("list %s/%s: %w", src.Owner, src.Repo, err)
+	}
+	if status >= http.StatusBadRequest {
+		return nil, nil, fmt.Errorf("list %s/%s: status %d", src.Owner, src.Repo, status)
+	}
+	var tree githubTree
+	if err := json.Unmarshal(body, &tree); err != nil {
+		return nil, nil, fmt.Errorf("decode tree %s/%s: %w", src.Owner, src.Repo, err)
+	}
+
+	var notes []string
+	if tree.Truncated {
+		notes = append(notes, fmt.Sprintf("github tree for %s/%s was truncated by the API", src.Owner, src.Repo))
+	}
+
+	allowed := map[string]struct{}{}
+	for _, ext := range src.IncludeExtensions {
+		allowed[strings.ToLower(ext)] = struct{}{}
+	}
+
+	var documents []Document
+	for _, entry := range tree.Tree {
+		if entry.Type != "blob" {
+			continue
+		}
+		if len(src.PathGlobs) > 0 && !matchAnyGlob(src.PathGlobs, entry.Path) {
+			continue
+		}
+		ext := strings.ToLower(path.Ext(entry.Path))
+		if len(allowed) > 0 {
+			if _, ok := allowed[ext]; !ok {
+				continue
+			}
+		}
+		if entry.Size > githubMaxFileBytes {
+			notes = append(notes, fmt.Sprintf("skipped %s/%s/%s: %d bytes exceeds limit", src.Owner, src.Repo, entry.Path, entry.Size))
+			continue
+		}
+		if limit > 0 && len(documents) >= limit {
+			return documents, notes, errDocumentLimit
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, nil, err
+		}
+
+		rawURL := fmt.Sprintf("%s/%s/%s/%s/%s", githubRawBaseURL, src.Owner, src.Repo, ref, escapeRepoPath(entry.Path))
+		content, status, err := f.do(ctx, rawURL, header)
+		if err == nil && status >= http.StatusBadRequest {
+			err = fmt.Errorf("status %d", status)
+		}
+		if err != nil {
+			notes = append(notes, fmt.Sprintf("skipped %s/%s/%s: %v", src.Owner, src.Repo, entry.Path, err))
+			continue
+		}
+
+		format := FormatText
+		if ext == ".md" || ext == ".markdown" {
+			format = FormatMarkdown
+		}
+		text, err := convertPayload(string(content), format, f.htmlOpts)
+		if err != nil {
+			return nil, nil, fmt.Errorf("convert %s: %w", entry.Path, err)
+		}
+		repoName := src.Owner + "/" + src.Repo
+		documents = append(documents, Document{
+			ID:      Slugify(repoName + "/" + entry.Path),
+			Title:   fmt.Sprintf("%s: %s", repoName, entry.Path),
+			URI:     fmt.Sprintf("https://github.com/%s/blob/%s/%s", repoName, ref, escapeRepoPath(entry.Path)),
+			Source:  "github: " + repoName,
+			Content: text,
+		})
+	}
+	return documents, notes, nil
+}
+
+func escapeRepoPath(p string) string {
+	parts := strings.Split(p, "/")
+	for i, part := range parts {
+		parts[i] = url.PathEscape(part)
+	}
+	return strings.Join(parts, "/")
+}
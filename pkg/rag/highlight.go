@@ -0,0 +1,52 @@
+package rag
+
+import (
+	"strings"
+	"unicode"
+)
+
+// DefaultHighlightMarker wraps highlighted terms when QueryOptions.HighlightMarker is empty,
+// rendering them bold in markdown.
+const DefaultHighlightMarker = "**"
+
+// highlightTerms wraps every whole-word, case-insensitive occurrence of the question's significant
+// terms in marker. Terms are tokenized like keyword search, so stop words and one-letter words
+// are never highlighted.
+func highlightTerms(text, question, marker string) string {
+	terms := map[string]struct{}{}
+	for _, term := range keywordTokens(question) {
+		terms[term] = struct{}{}
+	}
+	if len(terms) == 0 || text == "" {
+		return text
+	}
+
+	var b strings.Builder
+	runes := []rune(text)
+	for i := 0; i < len(runes); {
+		if !isWordRune(runes[i]) {
+			b.WriteRune(runes[i])
+			i++
+			continue
+		}
+		j := i
+		for j < len(runes) && isWordRune(runes[j]) {
+			j++
+		}
+		word := string(runes[i:j])
+		if _, ok := terms[strings.ToLower(word)]; ok {
+			b.WriteString(marker)
+			b.WriteString(word)
+			b.WriteString(marker)
+		} else {
+			b.WriteString(word)
+		}
+		i = j
+	}
+	return b.String()
+}
+
+// isWordRune matches the runes keywordTokens keeps inside a token.
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
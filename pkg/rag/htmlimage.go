@@ -0,0 +1,90 @@
+package rag
+
+import (
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// HTMLOptions tune how HTML pages are converted to text.
+type HTMLOptions struct {
+	// ImageText keeps img alt text, figcaptions, and title attributes, which plain conversion drops.
+	// It is opt-in because decorative images add noise on many sites.
+	ImageText bool
+	// RemoveBoilerplate strips navigation and banner lines, such as "Skip to content" and cookie
+	// notices, from the collected HTML documents: short lines matching DefaultBoilerplatePatterns
+	// or BoilerplatePatterns, and lines repeated across at least half of one host's HTML
+	// documents when it has three or more. It is opt-in since it can drop real content.
+	RemoveBoilerplate bool
+	// BoilerplatePatterns are extra regular expressions for RemoveBoilerplate, matched
+	// case-insensitively against whole trimmed lines of at most 60 characters.
+	BoilerplatePatterns []string
+}
+
+// annotateImages rewrites n in place so descriptive image text survives conversion: each img with
+// alt or title text becomes an "Image: ..." paragraph, figcaptions are prefixed with "Figure:", and
+// other elements with a title attribute get it appended in parentheses. It reports whether
+// anything changed.
+func annotateImages(n *html.Node) bool {
+	changed := false
+	for child := n.FirstChild; child != nil; {
+		next := child.NextSibling
+		if child.Type != html.ElementNode {
+			child = next
+			continue
+		}
+		switch child.DataAtom {
+		case atom.Script, atom.Style:
+		case atom.Img:
+			if text := imageText(child); text != "" {
+				n.InsertBefore(textParagraph("Image: "+text), child)
+				changed = true
+			}
+			n.RemoveChild(child)
+		default:
+			if annotateImages(child) {
+				changed = true
+			}
+			if child.DataAtom == atom.Figcaption && nodeText(child) != "" {
+				child.InsertBefore(&html.Node{Type: html.TextNode, Data: "Figure: "}, child.FirstChild)
+				changed = true
+			}
+			if title := attrValue(child, "title"); title != "" && title != nodeText(child) {
+				child.AppendChild(&html.Node{Type: html.TextNode, Data: " (" + title + ")"})
+				changed = true
+			}
+		}
+		child = next
+	}
+	return changed
+}
+
+// imageText joins an img's alt and title, skipping the title when it repeats the alt.
+func imageText(img *html.Node) string {
+	alt := attrValue(img, "alt")
+	title := attrValue(img, "title")
+	switch {
+	case alt == "":
+		return title
+	case title == "" || title == alt:
+		return alt
+	default:
+		return alt + " (" + title + ")"
+	}
+}
+
+func attrValue(n *html.Node, key string) string {
+	for _, attr := range n.Attr {
+		if attr.Key == key {
+			return strings.Join(strings.Fields(attr.Val), " ")
+		}
+	}
+	return ""
+}
+
+func textParagraph(text string) *html.Node {
+	p := &html.Node{Type: html.ElementNode, Data: "p", DataAtom: atom.P}
+	p.AppendChild(&html.Node{Type: html.TextNode, Data: text})
+	return p
+}
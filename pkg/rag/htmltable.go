@@ -0,0 +1,184 @@
+package rag
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/jaytaylor/html2text"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// htmlToText converts an HTML page to plain text, rendering <table> elements as GitHub-flavored
+// markdown tables so rows and columns stay aligned. Everything else goes through html2text.
+func htmlToText(raw string, opts HTMLOptions) (string, error) {
+	root, err := html.Parse(strings.NewReader(raw))
+	if err != nil {
+		return html2text.FromString(raw, html2text.Options{PrettyTables: true})
+	}
+
+	changed := opts.ImageText && annotateImages(root)
+	var tables []string
+	var replace func(n *html.Node)
+	replace = func(n *html.Node) {
+		for child := n.FirstChild; child != nil; {
+			next := child.NextSibling
+			if child.Type == html.ElementNode && child.DataAtom == atom.Table {
+				placeholder := textParagraph(tablePlaceholder(len(tables)))
+				tables = append(tables, tableToMarkdown(child))
+				n.InsertBefore(placeholder, child)
+				n.RemoveChild(child)
+			} else {
+				replace(child)
+			}
+			child = next
+		}
+	}
+	replace(root)
+	if len(tables) == 0 && !changed {
+		return html2text.FromString(raw, html2text.Options{PrettyTables: true})
+	}
+
+	var rendered bytes.Buffer
+	if err := html.Render(&rendered, root); err != nil {
+		return "", err
+	}
+	text, err := html2text.FromString(rendered.String(), html2text.Options{PrettyTables: true})
+	if err != nil {
+		return "", err
+	}
+	for i, table := range tables {
+		text = strings.Replace(text, tablePlaceholder(i), "\n"+table+"\n", 1)
+	}
+	return text, nil
+}
+
+func tablePlaceholder(i int) string {
+	return fmt.Sprintf("ragtable%dplaceholder", i)
+}
+
+// tableToMarkdown renders a table node as a markdown table. The first row is the header;
+// cells spanning several columns are repeated so every row has the same width.
+func tableToMarkdown(table *html.Node) string {
+	var rows [][]string
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			if child.Type != html.ElementNode {
+				continue
+			}
+			switch child.DataAtom {
+			case atom.Tr:
+				if row := tableRowCells(child); len(row) > 0 {
+					rows = append(rows, row)
+				}
+			case atom.Table:
+				// Nested tables are flattened into their parent cell's text.
+			default:
+				walk(child)
+			}
+		}
+	}
+	walk(table)
+	if len(rows) == 0 {
+		return ""
+	}
+
+	columns := 0
+	for _, row := range rows {
+		if len(row) > columns {
+			columns = len(row)
+		}
+	}
+	widths := make([]int, columns)
+	for _, row := range rows {
+		for i, cell := range row {
+			if n := len([]rune(cell)); n > widths[i] {
+				widths[i] = n
+			}
+		}
+	}
+	for i := range widths {
+		if widths[i] < 3 {
+			widths[i] = 3
+		}
+	}
+
+	var b strings.Builder
+	writeRow := func(cells []string) {
+		b.WriteString("|")
+		for i := 0; i < columns; i++ {
+			cell := ""
+			if i < len(cells) {
+				cell = cells[i]
+			}
+			b.WriteString(" " + cell + strings.Repeat(" ", widths[i]-len([]rune(cell))) + " |")
+		}
+		b.WriteString("\n")
+	}
+	writeRow(rows[0])
+	separator := make([]string, columns)
+	for i := range separator {
+		separator[i] = strings.Repeat("-", widths[i])
+	}
+	writeRow(separator)
+	for _, row := range rows[1:] {
+		writeRow(row)
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// tableRowCells extracts the text of each th/td in a row, repeating cells for colspan.
+func tableRowCells(tr *html.Node) []string {
+	var cells []string
+	for cell := tr.FirstChild; cell != nil; cell = cell.NextSibling {
+		if cell.Type != html.ElementNode || (cell.DataAtom != atom.Td && cell.DataAtom != atom.Th) {
+			continue
+		}
+		text := strings.ReplaceAll(nodeText(cell), "|", `\|`)
+		span := 1
+		for _, attr := range cell.Attr {
+			if attr.Key == "colspan" {
+				fmt.Sscanf(attr.Val, "%d", &span)
+			}
+		}
+		if span < 1 || span > 50 {
+			span = 1
+		}
+		for i := 0; i < span; i++ {
+			cells = append(cells, text)
+		}
+	}
+	return cells
+}
+
+// inlineAtoms are elements whose text joins its neighbours without a separating space.
+var inlineAtoms = map[atom.Atom]struct{}{
+	atom.A: {}, atom.Abbr: {}, atom.B: {}, atom.Code: {}, atom.Em: {}, atom.I: {},
+	atom.Small: {}, atom.Span: {}, atom.Strong: {}, atom.Sub: {}, atom.Sup: {},
+}
+
+// nodeText returns the whitespace-collapsed text content of n.
+func nodeText(n *html.Node) string {
+	var b strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		switch {
+		case n.Type == html.TextNode:
+			b.WriteString(n.Data)
+		case n.Type == html.ElementNode && (n.DataAtom == atom.Script || n.DataAtom == atom.Style):
+			return
+		case n.Type == html.ElementNode && n.DataAtom == atom.Br:
+			b.WriteByte(' ')
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+		if _, inline := inlineAtoms[n.DataAtom]; n.Type == html.ElementNode && !inline {
+			b.WriteByte(' ')
+		}
+	}
+	walk(n)
+	return strings.Join(strings.Fields(b.String()), " ")
+}
@@ -0,0 +1,80 @@
+package rag
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// RagIgnoreFile names the file in the local docs folder whose patterns are added to
+// SourceOptions.ExcludeGlobs.
+const RagIgnoreFile = ".ragignore"
+
+// ignoreRule is one gitignore-style pattern. A pattern without a slash matches a name at any
+// depth, a leading slash anchors it to the docs folder, and a trailing slash limits it to
+// directories.
+type ignoreRule struct {
+	glob    string
+	dirOnly bool
+}
+
+func parseIgnoreRules(patterns []string) []ignoreRule {
+	rules := make([]ignoreRule, 0, len(patterns))
+	for _, pattern := range patterns {
+		pattern = strings.TrimSpace(filepath.ToSlash(pattern))
+		if pattern == "" || strings.HasPrefix(pattern, "#") {
+			continue
+		}
+		rule := ignoreRule{}
+		if strings.HasSuffix(pattern, "/") {
+			rule.dirOnly = true
+			pattern = strings.TrimRight(pattern, "/")
+		}
+		if strings.HasPrefix(pattern, "/") {
+			pattern = strings.TrimLeft(pattern, "/")
+		} else if !strings.Contains(pattern, "/") {
+			pattern = "**/" + pattern
+		}
+		if pattern == "" {
+			continue
+		}
+		rule.glob = pattern
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// ignored reports whether the slash-separated path rel, relative to the docs folder, matches a
+// rule. Directory rules are only checked against directories.
+func ignored(rules []ignoreRule, rel string, isDir bool) bool {
+	for _, rule := range rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		if matchGlob(rule.glob, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// readIgnoreFile returns the patterns in path, one per line; a missing file has none.
+func readIgnoreFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var patterns []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		patterns = append(patterns, scanner.Text())
+	}
+	return patterns, scanner.Err()
+}
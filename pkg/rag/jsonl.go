@@ -0,0 +1,63 @@
+package rag
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// ExportJSONL writes every chunk, embedding included, as one JSON object per line.
+func (vs *VectorStore) ExportJSONL(w io.Writer) error {
+	vs.mu.RLock()
+	defer vs.mu.RUnlock()
+	buffered := bufio.NewWriter(w)
+	encoder := json.NewEncoder(buffered)
+	for _, chunk := range vs.Chunks {
+		if err := encoder.Encode(chunk); err != nil {
+			return fmt.Errorf("export chunk %s: %w", chunk.ID, err)
+		}
+	}
+	return buffered.Flush()
+}
+
+// ImportJSONL reads chunks written by ExportJSONL, one per line, and builds a store from them.
+// Metadata counts and the embedding dimension are derived from the chunks; every chunk must
+// carry an embedding of the same length.
+func ImportJSONL(r io.Reader) (*VectorStore, error) {
+	decoder := json.NewDecoder(bufio.NewReader(r))
+	var chunks []Chunk
+	documents := map[string]struct{}{}
+	for line := 1; ; line++ {
+		var chunk Chunk
+		err := decoder.Decode(&chunk)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("import record %d: %w", line, err)
+		}
+		if len(chunk.Embedding) == 0 {
+			return nil, fmt.Errorf("import record %d: chunk %s has no embedding", line, chunk.ID)
+		}
+		if len(chunks) > 0 && len(chunk.Embedding) != len(chunks[0].Embedding) {
+			return nil, fmt.Errorf("import record %d: %w: %d vs %d", line, ErrDimensionMismatch, len(chunk.Embedding), len(chunks[0].Embedding))
+		}
+		documents[chunk.DocumentID] = struct{}{}
+		chunks = append(chunks, chunk)
+	}
+	if len(chunks) == 0 {
+		return nil, errors.New("no chunks to import")
+	}
+
+	meta := Metadata{
+		GeneratedAt:  time.Now().UTC(),
+		SourceCount:  len(documents),
+		ChunkCount:   len(chunks),
+		EmbeddingDim: len(chunks[0].Embedding),
+		Notes:        []string{"imported from JSONL"},
+	}
+	return &VectorStore{Metadata: meta, Chunks: chunks}, nil
+}
@@ -0,0 +1,144 @@
+package rag
+
+import (
+	"strings"
+	"unicode"
+)
+
+// minKeywordOverlap is the fraction of query terms a chunk must match to be returned by KeywordSearch.
+const minKeywordOverlap = 0.5
+
+// fuzzyTermLimit is the largest query, in terms, for which typo-tolerant matching is used.
+const fuzzyTermLimit = 3
+
+// keywordStopWords are dropped from keyword queries since they match nearly every chunk.
+var keywordStopWords = map[string]struct{}{
+	"a": {}, "an": {}, "and": {}, "are": {}, "can": {}, "do": {}, "does": {}, "for": {}, "from": {},
+	"how": {}, "i": {}, "in": {}, "is": {}, "it": {}, "of": {}, "on": {}, "or": {}, "should": {},
+	"the": {}, "to": {}, "we": {}, "what": {}, "when": {}, "which": {}, "who": {}, "why": {},
+	"with": {}, "you": {},
+}
+
+// KeywordSearch ranks chunks by normalized token overlap with terms. For short queries a term also
+// matches tokens within a small edit distance, so typos still find their chunk. Results are marked
+// as keyword matches and score the fraction of terms found.
+func (vs *VectorStore) KeywordSearch(terms []string, topK int) []SearchResult {
+	if vs == nil {
+		return nil
+	}
+	if topK <= 0 {
+		topK = 4
+	}
+	normalized := make([]string, 0, len(terms))
+	for _, term := range terms {
+		normalized = append(normalized, keywordTokens(term)...)
+	}
+	if len(normalized) == 0 {
+		return nil
+	}
+	fuzzy := len(normalized) <= fuzzyTermLimit
+
+	vs.mu.RLock()
+	defer vs.mu.RUnlock()
+	var results []SearchResult
+	for _, chunk := range vs.Chunks {
+		tokens := map[string]struct{}{}
+		for _, token := range keywordTokens(chunk.Text) {
+			tokens[token] = struct{}{}
+		}
+		matched := 0
+		for _, term := range normalized {
+			if termMatches(term, tokens, fuzzy) {
+				matched++
+			}
+		}
+		score := float64(matched) / float64(len(normalized))
+		if score >= minKeywordOverlap {
+			results = append(results, SearchResult{Chunk: chunk, Score: score, KeywordMatch: true})
+		}
+	}
+	sortByScore(results)
+	if len(results) > topK {
+		results = results[:topK]
+	}
+	return results
+}
+
+// keywordTokens lowercases text and splits it on non-alphanumeric runes, dropping stop words
+// and single-character tokens.
+func keywordTokens(text string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	tokens := fields[:0]
+	for _, field := range fields {
+		if len([]rune(field)) < 2 {
+			continue
+		}
+		if _, stop := keywordStopWords[field]; stop {
+			continue
+		}
+		tokens = append(tokens, field)
+	}
+	return tokens
+}
+
+func termMatches(term string, tokens map[string]struct{}, fuzzy bool) bool {
+	if _, ok := tokens[term]; ok {
+		return true
+	}
+	tolerance := editTolerance(term)
+	if !fuzzy || tolerance == 0 {
+		return false
+	}
+	for token := range tokens {
+		if levenshtein(term, token, tolerance) <= tolerance {
+			return true
+		}
+	}
+	return false
+}
+
+// editTolerance allows one typo in medium terms and two in long ones; short terms must match exactly.
+func editTolerance(term string) int {
+	switch n := len([]rune(term)); {
+	case n >= 8:
+		return 2
+	case n >= 4:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// levenshtein returns the edit distance between a and b, or max+1 once it must exceed max.
+func levenshtein(a, b string, max int) int {
+	ra, rb := []rune(a), []rune(b)
+	if diff := len(ra) - len(rb); diff > max || -diff > max {
+		return max + 1
+	}
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		rowMin := curr[0]
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+			if curr[j] < rowMin {
+				rowMin = curr[j]
+			}
+		}
+		if rowMin > max {
+			return max + 1
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
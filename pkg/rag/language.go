@@ -0,0 +1,49 @@
+package rag
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/abadojack/whatlanggo"
+)
+
+// languageSampleRunes bounds how much of each document is fed to the detector.
+const languageSampleRunes = 4000
+
+// detectLanguage returns the ISO 639-1 code for text, or "" when detection is unreliable.
+func detectLanguage(text string) string {
+	runes := []rune(text)
+	if len(runes) > languageSampleRunes {
+		runes = runes[:languageSampleRunes]
+	}
+	info := whatlanggo.Detect(string(runes))
+	if !info.IsReliable() {
+		return ""
+	}
+	return info.Lang.Iso6391()
+}
+
+// applyLanguageFilter fills Document.Language and, when allow is non-empty, drops documents
+// detected in another language. Documents whose language cannot be detected are kept.
+func applyLanguageFilter(docs []Document, allow []string) ([]Document, []string) {
+	allowed := map[string]struct{}{}
+	for _, lang := range allow {
+		allowed[strings.ToLower(strings.TrimSpace(lang))] = struct{}{}
+	}
+
+	kept := docs[:0]
+	var notes []string
+	for _, doc := range docs {
+		if doc.Language == "" {
+			doc.Language = detectLanguage(doc.Content)
+		}
+		if len(allowed) > 0 && doc.Language != "" {
+			if _, ok := allowed[doc.Language]; !ok {
+				notes = append(notes, fmt.Sprintf("skipped %s: language %q not allowed", doc.URI, doc.Language))
+				continue
+			}
+		}
+		kept = append(kept, doc)
+	}
+	return kept, notes
+}
@@ -0,0 +1,127 @@
+package rag
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+)
+
+// localEmbedBatch bounds how many texts are padded into one model run.
+const localEmbedBatch = 32
+
+// localModelFiles must exist in a local model directory.
+var localModelFiles = []string{"model.onnx", "tokenizer.json"}
+
+// LocalEmbedder embeds texts with a sentence-transformer ONNX model on this machine, with no
+// network or model server. Texts are tokenized in Go from tokenizer.json, run through ONNX
+// Runtime, then mean-pooled over the attention mask and L2-normalized. ONNX Runtime is linked
+// only in builds with -tags onnx; other builds fail to create the embedder.
+type LocalEmbedder struct {
+	tokenizer *wordPieceTokenizer
+	session   *onnxSession
+}
+
+// NewLocalEmbedder loads model.onnx and tokenizer.json from modelDir. libraryPath is the ONNX
+// Runtime shared library to load; empty uses the system's default search path.
+func NewLocalEmbedder(modelDir, libraryPath string) (*LocalEmbedder, error) {
+	if modelDir == "" {
+		return nil, errors.New("RAG_LOCAL_MODEL_PATH is required for the local provider")
+	}
+	for _, name := range localModelFiles {
+		if _, err := os.Stat(filepath.Join(modelDir, name)); err != nil {
+			return nil, fmt.Errorf("local model %s: %w", modelDir, err)
+		}
+	}
+	tokenizer, err := loadWordPieceTokenizer(filepath.Join(modelDir, "tokenizer.json"))
+	if err != nil {
+		return nil, fmt.Errorf("local model %s: %w", modelDir, err)
+	}
+	session, err := newONNXSession(filepath.Join(modelDir, "model.onnx"), libraryPath)
+	if err != nil {
+		return nil, fmt.Errorf("local model %s: %w", modelDir, err)
+	}
+	return &LocalEmbedder{tokenizer: tokenizer, session: session}, nil
+}
+
+// Embed returns one embedding per text.
+func (e *LocalEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	embeddings := make([][]float32, 0, len(texts))
+	for start := 0; start < len(texts); start += localEmbedBatch {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		batch, err := e.embedBatch(texts[start:min(start+localEmbedBatch, len(texts))])
+		if err != nil {
+			return nil, fmt.Errorf("local embedder: %w", err)
+		}
+		embeddings = append(embeddings, batch...)
+	}
+	return embeddings, nil
+}
+
+// EmbedOne embeds a single text.
+func (e *LocalEmbedder) EmbedOne(ctx context.Context, text string) ([]float32, error) {
+	return embedOne(ctx, e.Embed, text)
+}
+
+// Close releases the ONNX Runtime session.
+func (e *LocalEmbedder) Close() error {
+	return e.session.close()
+}
+
+// embedBatch pads the tokenized texts to the longest one and pools the model's token states.
+func (e *LocalEmbedder) embedBatch(texts []string) ([][]float32, error) {
+	encoded := make([][]int64, len(texts))
+	seqLen := 0
+	for i, text := range texts {
+		encoded[i] = e.tokenizer.encode(text)
+		seqLen = max(seqLen, len(encoded[i]))
+	}
+	ids := make([]int64, len(texts)*seqLen)
+	mask := make([]int64, len(texts)*seqLen)
+	for i, tokens := range encoded {
+		copy(ids[i*seqLen:], tokens)
+		for j := range tokens {
+			mask[i*seqLen+j] = 1
+		}
+	}
+
+	hidden, width, err := e.session.run(ids, mask, len(texts), seqLen)
+	if err != nil {
+		return nil, err
+	}
+	embeddings := make([][]float32, len(texts))
+	for i := range texts {
+		embeddings[i] = meanPool(hidden[i*seqLen*width:(i+1)*seqLen*width], mask[i*seqLen:(i+1)*seqLen], width)
+	}
+	return embeddings, nil
+}
+
+// meanPool averages the token states of one text where mask is set and L2-normalizes the result.
+func meanPool(states []float32, mask []int64, width int) []float32 {
+	sum := make([]float64, width)
+	count := 0
+	for token, on := range mask {
+		if on == 0 {
+			continue
+		}
+		count++
+		for j, v := range states[token*width : (token+1)*width] {
+			sum[j] += float64(v)
+		}
+	}
+	var norm float64
+	for j := range sum {
+		sum[j] /= float64(max(count, 1))
+		norm += sum[j] * sum[j]
+	}
+	norm = max(math.Sqrt(norm), 1e-12)
+	pooled := make([]float32, width)
+	for j, v := range sum {
+		pooled[j] = float32(v / norm)
+	}
+	return pooled
+}
@@ -0,0 +1,134 @@
+package rag
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// MergeVectorStores concatenates the chunks of several stores into a new one, keeping the first
+// chunk seen for each ID. All stores must share one embedding dimension. Metadata counts are
+// recomputed and notes combined. Stored original documents are kept, first seen per ID. Stores whose embedding canaries show different models are rejected.
+func MergeVectorStores(stores ...*VectorStore) (*VectorStore, error) {
+	if len(stores) == 0 {
+		return nil, errors.New("no stores to merge")
+	}
+	merged := &VectorStore{Metadata: Metadata{GeneratedAt: time.Now().UTC()}}
+	seen := map[string]struct{}{}
+	documents := map[string]struct{}{}
+	keptDocuments := map[string]struct{}{}
+	for i, store := range stores {
+		if store == nil {
+			return nil, fmt.Errorf("store %d is nil", i+1)
+		}
+		store.mu.RLock()
+		dim := store.Metadata.EmbeddingDim
+		if dim == 0 && len(store.Chunks) > 0 {
+			dim = len(store.Chunks[0].Embedding)
+		}
+		if dim != 0 && merged.Metadata.EmbeddingDim != 0 && dim != merged.Metadata.EmbeddingDim {
+			store.mu.RUnlock()
+			return nil, fmt.Errorf("store %d: %w: %d vs %d", i+1, ErrDimensionMismatch, dim, merged.Metadata.EmbeddingDim)
+		}
+		if merged.Metadata.EmbeddingDim == 0 {
+			merged.Metadata.EmbeddingDim = dim
+		}
+		if canary := store.Metadata.EmbeddingCanary; len(canary) > 0 {
+			if len(merged.Metadata.EmbeddingCanary) == 0 {
+				merged.Metadata.EmbeddingCanary = canary
+			} else if similarity, _ := CosineSimilarity(canary, merged.Metadata.EmbeddingCanary); similarity < canarySimilarityFloor {
+				store.mu.RUnlock()
+				return nil, fmt.Errorf("store %d: %w: canary similarity %.3f, the stores were embedded with different models", i+1, ErrEmbedderMismatch, similarity)
+			}
+		}
+		merged.Metadata.Notes = append(merged.Metadata.Notes, store.Metadata.Notes...)
+		for _, doc := range store.Documents {
+			if _, dup := keptDocuments[doc.ID]; !dup {
+				keptDocuments[doc.ID] = struct{}{}
+				merged.Documents = append(merged.Documents, doc)
+			}
+		}
+		for _, chunk := range store.Chunks {
+			if _, dup := seen[chunk.ID]; dup {
+				continue
+			}
+			if len(chunk.Embedding) != merged.Metadata.EmbeddingDim {
+				store.mu.RUnlock()
+				return nil, fmt.Errorf("store %d chunk %s: %w: %d vs %d", i+1, chunk.ID, ErrDimensionMismatch, len(chunk.Embedding), merged.Metadata.EmbeddingDim)
+			}
+			seen[chunk.ID] = struct{}{}
+			documents[chunk.DocumentID] = struct{}{}
+			merged.Chunks = append(merged.Chunks, chunk)
+		}
+		store.mu.RUnlock()
+	}
+	merged.Metadata.ChunkCount = len(merged.Chunks)
+	merged.Metadata.SourceCount = len(documents)
+	return merged, nil
+}
+
+// AppendDocuments adds the documents embedded in built to store, replacing the chunks of any
+// document already present, and returns how many documents were added and updated. Chunks more
+// similar than duplicateThreshold to another indexed document's are skipped and counted in
+// skipped; a document left with none is not indexed. Stores that
+// cannot replace single documents fail with ErrAddSourceUnsupported; an index embedded with a
+// different model fails with ErrEmbedderMismatch. For a JSON store, built's stored documents are
+// kept when the store keeps documents or was empty, and its notes are appended.
+func AppendDocuments(store Store, built *VectorStore, duplicateThreshold float64) (added, updated, skipped int, err error) {
+	replacer, ok := store.(documentReplacer)
+	if !ok {
+		return 0, 0, 0, ErrAddSourceUnsupported
+	}
+	meta := store.Meta()
+	if len(meta.EmbeddingCanary) > 0 && len(built.Metadata.EmbeddingCanary) > 0 {
+		if similarity, _ := CosineSimilarity(built.Metadata.EmbeddingCanary, meta.EmbeddingCanary); similarity < canarySimilarityFloor {
+			return 0, 0, 0, fmt.Errorf("%w: canary similarity %.3f, the index was embedded with a different model", ErrEmbedderMismatch, similarity)
+		}
+	}
+	wasEmpty := store.Len() == 0
+
+	var order []string
+	byDocument := map[string][]Chunk{}
+	for _, chunk := range built.Chunks {
+		if _, seen := byDocument[chunk.DocumentID]; !seen {
+			order = append(order, chunk.DocumentID)
+		}
+		byDocument[chunk.DocumentID] = append(byDocument[chunk.DocumentID], chunk)
+	}
+	for _, documentID := range order {
+		chunks, dropped, err := dropNearDuplicates(store, byDocument[documentID], duplicateThreshold, documentID)
+		if err != nil {
+			return added, updated, skipped, fmt.Errorf("document %s: %w", documentID, err)
+		}
+		skipped += dropped
+		if len(chunks) == 0 {
+			continue
+		}
+		removed, err := replacer.ReplaceDocument(documentID, chunks, true)
+		if err != nil {
+			return added, updated, skipped, fmt.Errorf("document %s: %w", documentID, err)
+		}
+		if removed > 0 {
+			updated++
+		} else {
+			added++
+		}
+	}
+
+	if vs, ok := store.(*VectorStore); ok {
+		vs.mu.Lock()
+		if wasEmpty {
+			vs.Metadata.EmbeddingCanary = built.Metadata.EmbeddingCanary
+			vs.Documents = built.Documents
+		}
+		vs.Metadata.GeneratedAt = time.Now().UTC()
+		vs.Metadata.Notes = append(vs.Metadata.Notes, built.Metadata.Notes...)
+		vs.mu.Unlock()
+		if !wasEmpty {
+			for _, doc := range built.Documents {
+				vs.putDocument(doc)
+			}
+		}
+	}
+	return added, updated, skipped, nil
+}
@@ -0,0 +1,87 @@
+package rag
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// MaxAutoVariants caps how many rephrasings QueryOptions.AutoVariants may request.
+const MaxAutoVariants = 5
+
+const variantSystemPrompt = "You rewrite search queries. Reply only with the rewritten queries, one per line."
+
+// retrieveMultiQuery searches the question and each of its variants, then fuses the rankings with
+// reciprocal rank fusion. Results are ordered by fused rank but keep their best similarity as Score.
+func (s *Service) retrieveMultiQuery(ctx context.Context, question string, opts QueryOptions) ([]SearchResult, error) {
+	queries := []string{question}
+	seen := map[string]struct{}{strings.ToLower(question): {}}
+	addQuery := func(q string) {
+		q = strings.TrimSpace(q)
+		if _, dup := seen[strings.ToLower(q)]; q == "" || dup {
+			return
+		}
+		seen[strings.ToLower(q)] = struct{}{}
+		queries = append(queries, q)
+	}
+	for _, variant := range opts.QueryVariants {
+		addQuery(variant)
+	}
+	if opts.AutoVariants > 0 {
+		generated, err := s.generateVariants(ctx, question, min(opts.AutoVariants, MaxAutoVariants))
+		if err != nil {
+			logf(ctx, "generate query variants failed, searching the given phrasings only: %v", err)
+		}
+		for _, variant := range generated {
+			addQuery(variant)
+		}
+	}
+
+	lists := make([][]SearchResult, 0, len(queries))
+	best := map[string]float64{}
+	for _, query := range queries {
+		embedding, err := s.embedQuery(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+		matches, err := s.search(ctx, query, embedding, opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, match := range matches {
+			if score, ok := best[match.Chunk.ID]; !ok || match.Score > score {
+				best[match.Chunk.ID] = match.Score
+			}
+		}
+		lists = append(lists, matches)
+	}
+
+	fused := limitPerSource(FuseResults(lists, DefaultRRFK), opts.MaxPerSource)
+	if len(fused) > opts.TopK {
+		fused = fused[:opts.TopK]
+	}
+	for i := range fused {
+		fused[i].Score = best[fused[i].Chunk.ID]
+	}
+	return fused, nil
+}
+
+// generateVariants asks the chat client for n alternative phrasings of question.
+func (s *Service) generateVariants(ctx context.Context, question string, n int) ([]string, error) {
+	prompt := fmt.Sprintf("Write %d different ways to ask the following question, using other words and synonyms a document might use. Keep each on its own line without numbering.\n\nQuestion: %s", n, question)
+	reply, err := s.chatClient.Complete(ctx, variantSystemPrompt, prompt, 0.7)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUpstream, err)
+	}
+	var variants []string
+	for _, line := range strings.Split(reply, "\n") {
+		line = strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(line), "-*•0123456789.) "))
+		if line != "" {
+			variants = append(variants, line)
+		}
+		if len(variants) == n {
+			break
+		}
+	}
+	return variants, nil
+}
@@ -0,0 +1,132 @@
+package rag
+
+import (
+	"context"
+	"log"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// MaxContextWindow caps QueryOptions.ContextWindow.
+const MaxContextWindow = 5
+
+// neighborFinder is implemented by stores that can list a chunk's neighbours in its document.
+type neighborFinder interface {
+	// NeighborsOf returns the chunks of chunk's document whose Index is within n of chunk's,
+	// excluding chunk itself, ordered by Index.
+	NeighborsOf(chunk Chunk, n int) []Chunk
+}
+
+var (
+	_ neighborFinder = (*VectorStore)(nil)
+	_ neighborFinder = (*PgVectorStore)(nil)
+)
+
+// NeighborsOf returns the chunks of chunk's document whose Index is within n of chunk's,
+// excluding chunk itself, ordered by Index.
+func (vs *VectorStore) NeighborsOf(chunk Chunk, n int) []Chunk {
+	if n <= 0 {
+		return nil
+	}
+	vs.mu.RLock()
+	defer vs.mu.RUnlock()
+	var neighbors []Chunk
+	for _, other := range vs.Chunks {
+		if other.DocumentID != chunk.DocumentID || other.ID == chunk.ID {
+			continue
+		}
+		if distance := other.Index - chunk.Index; distance >= -n && distance <= n {
+			neighbors = append(neighbors, other)
+		}
+	}
+	sort.Slice(neighbors, func(i, j int) bool { return neighbors[i].Index < neighbors[j].Index })
+	return neighbors
+}
+
+// NeighborsOf returns the chunks of chunk's document whose Index is within n of chunk's,
+// excluding chunk itself, ordered by Index. Query failures are logged and yield no chunks.
+func (ps *PgVectorStore) NeighborsOf(chunk Chunk, n int) []Chunk {
+	if n <= 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), pgSearchTimeout)
+	defer cancel()
+	var rows []pgChunkRow
+	err := ps.db.WithContext(ctx).Raw(`SELECT id, document_id, source, uri, text, chunk_index, start_offset, end_offset, summary, start_line, end_line, fetched_at
+		FROM chunks
+		WHERE document_id = ? AND id <> ? AND chunk_index BETWEEN ? AND ?
+		ORDER BY chunk_index`, chunk.DocumentID, chunk.ID, chunk.Index-n, chunk.Index+n).Scan(&rows).Error
+	if err != nil {
+		log.Printf("pgvector neighbor lookup failed: %v", err)
+		return nil
+	}
+	neighbors := make([]Chunk, 0, len(rows))
+	for _, row := range rows {
+		neighbors = append(neighbors, row.chunk())
+	}
+	return neighbors
+}
+
+// expandContext widens every match with up to window neighbouring chunks on each side, for the
+// prompt only. Matches are handled best first and a chunk is used at most once, so a neighbour
+// that is itself a match, or already joined a better match, is left out. Scores are unchanged.
+func (s *Service) expandContext(matches []SearchResult, window int) []SearchResult {
+	finder, ok := s.currentStore().(neighborFinder)
+	if !ok || window <= 0 {
+		return matches
+	}
+	window = min(window, MaxContextWindow)
+	used := make(map[string]struct{}, len(matches))
+	for _, match := range matches {
+		used[match.Chunk.ID] = struct{}{}
+	}
+	expanded := make([]SearchResult, len(matches))
+	for i, match := range matches {
+		group := []Chunk{match.Chunk}
+		for _, neighbor := range finder.NeighborsOf(match.Chunk, window) {
+			if _, dup := used[neighbor.ID]; dup {
+				continue
+			}
+			used[neighbor.ID] = struct{}{}
+			group = append(group, neighbor)
+		}
+		expanded[i] = match
+		if len(group) > 1 {
+			sort.Slice(group, func(a, b int) bool { return group[a].Index < group[b].Index })
+			first, last := group[0], group[len(group)-1]
+			expanded[i].Chunk.Text = joinChunkText(group)
+			expanded[i].Chunk.StartOffset, expanded[i].Chunk.EndOffset = first.StartOffset, last.EndOffset
+			if first.StartLine > 0 && last.EndLine > 0 {
+				expanded[i].Chunk.StartLine, expanded[i].Chunk.EndLine = first.StartLine, last.EndLine
+			}
+		}
+	}
+	return expanded
+}
+
+// joinChunkText concatenates the text of chunks of one document, ordered by Index. Consecutive
+// chunks whose rune offsets overlap have the repeated text removed; gaps are marked with an
+// ellipsis line.
+func joinChunkText(group []Chunk) string {
+	var b strings.Builder
+	b.WriteString(group[0].Text)
+	for i, chunk := range group[1:] {
+		prev, text := group[i], chunk.Text
+		switch {
+		case chunk.Index != prev.Index+1:
+			b.WriteString("\n...\n")
+		case hasExactOffsets(prev) && hasExactOffsets(chunk) && chunk.StartOffset >= prev.StartOffset && chunk.StartOffset <= prev.EndOffset:
+			text = string([]rune(text)[min(prev.EndOffset-chunk.StartOffset, utf8.RuneCountInString(text)):])
+		default:
+			b.WriteString("\n")
+		}
+		b.WriteString(text)
+	}
+	return b.String()
+}
+
+// hasExactOffsets reports whether chunk's offsets span exactly its text, as ChunkDocuments sets them.
+func hasExactOffsets(chunk Chunk) bool {
+	return chunk.EndOffset > chunk.StartOffset && chunk.EndOffset-chunk.StartOffset == utf8.RuneCountInString(chunk.Text)
+}
@@ -0,0 +1,107 @@
+//go:build onnx
+
+package rag
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+
+	ort "github.com/yalue/onnxruntime_go"
+)
+
+// onnxEnvironment initializes ONNX Runtime once per process; the shared library path of the
+// first session wins.
+var onnxEnvironment struct {
+	once sync.Once
+	err  error
+}
+
+// onnxSession runs a transformer encoder that takes input_ids and attention_mask (plus
+// token_type_ids when the model declares it) and returns per-token hidden states as its first
+// output.
+type onnxSession struct {
+	session *ort.DynamicAdvancedSession
+	typeIDs bool
+	width   int
+}
+
+func newONNXSession(modelPath, libraryPath string) (*onnxSession, error) {
+	onnxEnvironment.once.Do(func() {
+		if libraryPath != "" {
+			ort.SetSharedLibraryPath(libraryPath)
+		}
+		onnxEnvironment.err = ort.InitializeEnvironment()
+	})
+	if onnxEnvironment.err != nil {
+		return nil, fmt.Errorf("initialize ONNX Runtime: %w", onnxEnvironment.err)
+	}
+
+	inputs, outputs, err := ort.GetInputOutputInfo(modelPath)
+	if err != nil {
+		return nil, err
+	}
+	if len(outputs) == 0 {
+		return nil, fmt.Errorf("%s declares no outputs", modelPath)
+	}
+	hidden := outputs[0]
+	if len(hidden.Dimensions) != 3 || hidden.Dimensions[2] <= 0 {
+		return nil, fmt.Errorf("%s: output %s has shape %v; want [batch, tokens, width]", modelPath, hidden.Name, hidden.Dimensions)
+	}
+	names := make([]string, len(inputs))
+	for i, input := range inputs {
+		names[i] = input.Name
+	}
+	for _, required := range []string{"input_ids", "attention_mask"} {
+		if !slices.Contains(names, required) {
+			return nil, fmt.Errorf("%s has no %s input", modelPath, required)
+		}
+	}
+
+	s := &onnxSession{typeIDs: slices.Contains(names, "token_type_ids"), width: int(hidden.Dimensions[2])}
+	inputNames := []string{"input_ids", "attention_mask"}
+	if s.typeIDs {
+		inputNames = append(inputNames, "token_type_ids")
+	}
+	s.session, err = ort.NewDynamicAdvancedSession(modelPath, inputNames, []string{hidden.Name}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// run returns the hidden states for a padded batch, flattened as [batch, seqLen, width], and width.
+func (s *onnxSession) run(ids, mask []int64, batch, seqLen int) ([]float32, int, error) {
+	shape := ort.NewShape(int64(batch), int64(seqLen))
+	inputs := make([]ort.Value, 0, 3)
+	defer func() {
+		for _, input := range inputs {
+			input.Destroy()
+		}
+	}()
+	feeds := [][]int64{ids, mask}
+	if s.typeIDs {
+		feeds = append(feeds, make([]int64, len(ids)))
+	}
+	for _, feed := range feeds {
+		tensor, err := ort.NewTensor(shape, feed)
+		if err != nil {
+			return nil, 0, err
+		}
+		inputs = append(inputs, tensor)
+	}
+
+	output, err := ort.NewEmptyTensor[float32](ort.NewShape(int64(batch), int64(seqLen), int64(s.width)))
+	if err != nil {
+		return nil, 0, err
+	}
+	defer output.Destroy()
+	if err := s.session.Run(inputs, []ort.Value{output}); err != nil {
+		return nil, 0, err
+	}
+	return slices.Clone(output.GetData()), s.width, nil
+}
+
+func (s *onnxSession) close() error {
+	return s.session.Destroy()
+}
@@ -0,0 +1,18 @@
+//go:build !onnx
+
+package rag
+
+import "errors"
+
+// onnxSession is a placeholder in builds without ONNX Runtime. Build with -tags onnx to link it.
+type onnxSession struct{}
+
+func newONNXSession(modelPath, libraryPath string) (*onnxSession, error) {
+	return nil, errors.New("the local embedding provider needs ONNX Runtime; rebuild with -tags onnx")
+}
+
+func (s *onnxSession) run(ids, mask []int64, batch, seqLen int) ([]float32, int, error) {
+	return nil, 0, errors.New("ONNX Runtime is not compiled in")
+}
+
+func (s *onnxSession) close() error { return nil }
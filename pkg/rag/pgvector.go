@@ -0,0 +1,382 @@
+package rag
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// pgSearchTimeout bounds a single KNN query since Search has no caller context.
+const pgSearchTimeout = 10 * time.Second
+
+// PgVectorStore keeps chunks and embeddings in Postgres using the pgvector extension.
+type PgVectorStore struct {
+	db *gorm.DB
+}
+
+// OpenPgVectorStore connects to dsn and ensures the pgvector extension and chunks table exist.
+func OpenPgVectorStore(dsn string) (*PgVectorStore, error) {
+	if dsn == "" {
+		return nil, errors.New("RAG_DATABASE_URL or DB_URL is required for the pgvector store")
+	}
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	if err != nil {
+		return nil, fmt.Errorf("connect pgvector store: %w", err)
+	}
+	return NewPgVectorStore(db)
+}
+
+// NewPgVectorStore wraps an existing connection, creating the schema when missing.
+func NewPgVectorStore(db *gorm.DB) (*PgVectorStore, error) {
+	if db == nil {
+		return nil, errors.New("database connection is required")
+	}
+	statements := []string{
+		`CREATE EXTENSION IF NOT EXISTS vector`,
+		`CREATE TABLE IF NOT EXISTS chunks (
+			id TEXT PRIMARY KEY,
+			document_id TEXT NOT NULL,
+			source TEXT NOT NULL,
+			uri TEXT NOT NULL,
+			text TEXT NOT NULL,
+			chunk_index INTEGER NOT NULL,
+			start_offset INTEGER NOT NULL DEFAULT 0,
+			end_offset INTEGER NOT NULL DEFAULT 0,
+			summary TEXT NOT NULL DEFAULT '',
+			start_line INTEGER NOT NULL DEFAULT 0,
+			end_line INTEGER NOT NULL DEFAULT 0,
+			fetched_at TIMESTAMPTZ,
+			embedding vector NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp
+		)`,
+		`ALTER TABLE chunks ADD COLUMN IF NOT EXISTS start_offset INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE chunks ADD COLUMN IF NOT EXISTS end_offset INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE chunks ADD COLUMN IF NOT EXISTS summary TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE chunks ADD COLUMN IF NOT EXISTS start_line INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE chunks ADD COLUMN IF NOT EXISTS end_line INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE chunks ADD COLUMN IF NOT EXISTS fetched_at TIMESTAMPTZ`,
+		`CREATE INDEX IF NOT EXISTS chunks_document_id_idx ON chunks (document_id)`,
+	}
+	for _, stmt := range statements {
+		if err := db.Exec(stmt).Error; err != nil {
+			return nil, fmt.Errorf("prepare pgvector schema: %w", err)
+		}
+	}
+	return &PgVectorStore{db: db}, nil
+}
+
+// Add upserts chunks by ID in a single transaction, rejecting embeddings whose dimension differs
+// from the stored vectors.
+func (ps *PgVectorStore) Add(chunks []Chunk) error {
+	if _, err := checkChunkDimensions(chunks, ps.Meta().EmbeddingDim); err != nil {
+		return err
+	}
+	return ps.db.Transaction(func(tx *gorm.DB) error {
+		return insertPgChunks(tx, chunks)
+	})
+}
+
+// Replace swaps the table contents for chunks in one transaction so readers never see a partial index.
+func (ps *PgVectorStore) Replace(chunks []Chunk) error {
+	return ps.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(`DELETE FROM chunks`).Error; err != nil {
+			return err
+		}
+		return insertPgChunks(tx, chunks)
+	})
+}
+
+// HasDocument reports whether any chunk belongs to documentID.
+func (ps *PgVectorStore) HasDocument(documentID string) (bool, error) {
+	var exists bool
+	err := ps.db.Raw(`SELECT EXISTS (SELECT 1 FROM chunks WHERE document_id = ?)`, documentID).Scan(&exists).Error
+	if err != nil {
+		return false, fmt.Errorf("pgvector document lookup: %w", err)
+	}
+	return exists, nil
+}
+
+// ReplaceDocument deletes documentID's chunks and inserts chunks in one transaction. A
+// transaction-scoped advisory lock on the document ID serializes concurrent writers, so with
+// allowReplace off only one of them can insert and the others fail with ErrSourceExists.
+func (ps *PgVectorStore) ReplaceDocument(documentID string, chunks []Chunk, allowReplace bool) (int, error) {
+	var removed int64
+	err := ps.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(`SELECT pg_advisory_xact_lock(hashtext(?))`, documentID).Error; err != nil {
+			return err
+		}
+		result := tx.Exec(`DELETE FROM chunks WHERE document_id = ?`, documentID)
+		if result.Error != nil {
+			return result.Error
+		}
+		removed = result.RowsAffected
+		if removed > 0 && !allowReplace {
+			return fmt.Errorf("%w: %s", ErrSourceExists, documentID)
+		}
+		var dim *int
+		if err := tx.Raw(`SELECT MAX(vector_dims(embedding)) FROM chunks`).Scan(&dim).Error; err != nil {
+			return err
+		}
+		want := 0
+		if dim != nil {
+			want = *dim
+		}
+		if _, err := checkChunkDimensions(chunks, want); err != nil {
+			return err
+		}
+		return insertPgChunks(tx, chunks)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return int(removed), nil
+}
+
+// uniqueChunks drops chunks whose nearest stored neighbour outside excludeDocument, or a chunk
+// kept earlier in the call, is more similar than threshold.
+func (ps *PgVectorStore) uniqueChunks(chunks []Chunk, threshold float64, excludeDocument string) ([]Chunk, int, error) {
+	if threshold <= 0 {
+		return chunks, 0, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), pgSearchTimeout)
+	defer cancel()
+	kept := make([]Chunk, 0, len(chunks))
+	skipped := 0
+	for _, chunk := range chunks {
+		if isNearDuplicate(chunk.Embedding, kept, threshold) {
+			skipped++
+			continue
+		}
+		vector := formatVector(chunk.Embedding)
+		var best []float64
+		err := ps.db.WithContext(ctx).Raw(`SELECT 1 - (embedding <=> ?::vector) FROM chunks
+			WHERE document_id <> ? AND vector_dims(embedding) = ?
+			ORDER BY embedding <=> ?::vector
+			LIMIT 1`, vector, excludeDocument, len(chunk.Embedding), vector).Scan(&best).Error
+		if err != nil {
+			return nil, 0, fmt.Errorf("pgvector duplicate check: %w", err)
+		}
+		if len(best) > 0 && best[0] > threshold {
+			skipped++
+			continue
+		}
+		kept = append(kept, chunk)
+	}
+	return kept, skipped, nil
+}
+
+func insertPgChunks(tx *gorm.DB, chunks []Chunk) error {
+	for _, chunk := range chunks {
+		if len(chunk.Embedding) == 0 {
+			return fmt.Errorf("chunk %s has no embedding", chunk.ID)
+		}
+		var fetchedAt *time.Time
+		if !chunk.FetchedAt.IsZero() {
+			fetchedAt = &chunk.FetchedAt
+		}
+		err := tx.Exec(`INSERT INTO chunks (id, document_id, source, uri, text, chunk_index, start_offset, end_offset, summary, start_line, end_line, fetched_at, embedding)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?::vector)
+			ON CONFLICT (id) DO UPDATE SET document_id = EXCLUDED.document_id, source = EXCLUDED.source,
+				uri = EXCLUDED.uri, text = EXCLUDED.text, chunk_index = EXCLUDED.chunk_index,
+				start_offset = EXCLUDED.start_offset, end_offset = EXCLUDED.end_offset, summary = EXCLUDED.summary,
+				start_line = EXCLUDED.start_line, end_line = EXCLUDED.end_line, fetched_at = EXCLUDED.fetched_at,
+				embedding = EXCLUDED.embedding, created_at = current_timestamp`,
+			chunk.ID, chunk.DocumentID, chunk.Source, chunk.URI, chunk.Text, chunk.Index,
+			chunk.StartOffset, chunk.EndOffset, chunk.Summary, chunk.StartLine, chunk.EndLine, fetchedAt, formatVector(chunk.Embedding)).Error
+		if err != nil {
+			return fmt.Errorf("insert chunk %s: %w", chunk.ID, err)
+		}
+	}
+	return nil
+}
+
+// Save is a no-op: Add writes through to Postgres.
+func (ps *PgVectorStore) Save(string) error {
+	return nil
+}
+
+// Load is a no-op: queries always read the live table.
+func (ps *PgVectorStore) Load(string) error {
+	return nil
+}
+
+// Meta derives metadata from the chunks table. Failures are logged and yield zero counts.
+func (ps *PgVectorStore) Meta() Metadata {
+	var row struct {
+		ChunkCount   int
+		SourceCount  int
+		EmbeddingDim *int
+		GeneratedAt  *time.Time
+	}
+	err := ps.db.Raw(`SELECT COUNT(*) AS chunk_count, COUNT(DISTINCT document_id) AS source_count,
+		MAX(vector_dims(embedding)) AS embedding_dim, MAX(created_at) AS generated_at FROM chunks`).Scan(&row).Error
+	if err != nil {
+		log.Printf("pgvector metadata failed: %v", err)
+		return Metadata{}
+	}
+	meta := Metadata{ChunkCount: row.ChunkCount, SourceCount: row.SourceCount}
+	if row.EmbeddingDim != nil {
+		meta.EmbeddingDim = *row.EmbeddingDim
+	}
+	if row.GeneratedAt != nil {
+		meta.GeneratedAt = row.GeneratedAt.UTC()
+	}
+	return meta
+}
+
+// Len reports the number of stored chunks.
+func (ps *PgVectorStore) Len() int {
+	return ps.Meta().ChunkCount
+}
+
+// SourceSummaries groups the chunks table by document. Rows written before fetch times were
+// recorded fall back to their insertion time.
+func (ps *PgVectorStore) SourceSummaries() ([]SourceSummary, error) {
+	var rows []struct {
+		DocumentID string
+		Source     string
+		URI        string
+		Chunks     int
+		FetchedAt  time.Time
+	}
+	err := ps.db.Raw(`SELECT document_id, MIN(source) AS source, MIN(uri) AS uri, COUNT(*) AS chunks,
+		MIN(COALESCE(fetched_at, created_at)) AS fetched_at FROM chunks GROUP BY document_id`).Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("pgvector source summaries: %w", err)
+	}
+	summaries := make([]SourceSummary, len(rows))
+	for i, row := range rows {
+		summaries[i] = SourceSummary{
+			DocumentID: row.DocumentID,
+			Title:      row.Source,
+			URI:        row.URI,
+			Chunks:     row.Chunks,
+			FetchedAt:  row.FetchedAt.UTC(),
+		}
+	}
+	return summaries, nil
+}
+
+type pgChunkRow struct {
+	ID          string
+	DocumentID  string
+	Source      string
+	URI         string
+	Text        string
+	ChunkIndex  int
+	StartOffset int
+	EndOffset   int
+	Summary     string
+	StartLine   int
+	EndLine     int
+	FetchedAt   *time.Time
+	Embedding   string
+	Score       float64
+}
+
+// Search runs a KNN query. It orders by pgvector's cosine distance (<=>) rather than L2 (<->)
+// so scores and ranking match the JSON store for non-normalized embeddings. Query failures are
+// logged and yield no results.
+func (ps *PgVectorStore) Search(query []float32, topK int) []SearchResult {
+	return ps.SearchWithMin(query, topK, math.Inf(-1))
+}
+
+// SearchWithMin behaves like Search but only returns chunks scoring at least minScore, so it may
+// return fewer than topK results or none.
+func (ps *PgVectorStore) SearchWithMin(query []float32, topK int, minScore float64) []SearchResult {
+	if ps == nil || len(query) == 0 {
+		return nil
+	}
+	if topK <= 0 {
+		topK = 4
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), pgSearchTimeout)
+	defer cancel()
+
+	vector := formatVector(query)
+	floor, args := "", []any{vector, len(query)}
+	if !math.IsInf(minScore, -1) {
+		floor = "AND 1 - (embedding <=> ?::vector) >= ?"
+		args = append(args, vector, minScore)
+	}
+	args = append(args, vector, topK)
+	var rows []pgChunkRow
+	err := ps.db.WithContext(ctx).Raw(`SELECT id, document_id, source, uri, text, chunk_index, start_offset, end_offset, summary, start_line, end_line, fetched_at,
+			embedding::text AS embedding, 1 - (embedding <=> ?::vector) AS score
+		FROM chunks
+		WHERE vector_dims(embedding) = ? `+floor+`
+		ORDER BY embedding <=> ?::vector
+		LIMIT ?`, args...).Scan(&rows).Error
+	if err != nil {
+		log.Printf("pgvector search failed: %v", err)
+		return nil
+	}
+
+	results := make([]SearchResult, 0, len(rows))
+	for _, row := range rows {
+		results = append(results, SearchResult{Chunk: row.chunk(), Score: row.Score})
+	}
+	return results
+}
+
+// chunk converts a scanned row; Embedding stays nil when the query did not select it.
+func (row pgChunkRow) chunk() Chunk {
+	var fetchedAt time.Time
+	if row.FetchedAt != nil {
+		fetchedAt = row.FetchedAt.UTC()
+	}
+	return Chunk{
+		ID:          row.ID,
+		DocumentID:  row.DocumentID,
+		Source:      row.Source,
+		URI:         row.URI,
+		Text:        row.Text,
+		Index:       row.ChunkIndex,
+		StartOffset: row.StartOffset,
+		EndOffset:   row.EndOffset,
+		Summary:     row.Summary,
+		StartLine:   row.StartLine,
+		EndLine:     row.EndLine,
+		FetchedAt:   fetchedAt,
+		Embedding:   parseVector(row.Embedding),
+	}
+}
+
+// formatVector renders an embedding in pgvector's text format, e.g. [0.1,0.2].
+func formatVector(v []float32) string {
+	var b strings.Builder
+	b.WriteByte('[')
+	for i, f := range v {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(strconv.FormatFloat(float64(f), 'f', -1, 32))
+	}
+	b.WriteByte(']')
+	return b.String()
+}
+
+func parseVector(text string) []float32 {
+	text = strings.Trim(text, "[]")
+	if text == "" {
+		return nil
+	}
+	parts := strings.Split(text, ",")
+	out := make([]float32, 0, len(parts))
+	for _, part := range parts {
+		f, err := strconv.ParseFloat(strings.TrimSpace(part), 32)
+		if err != nil {
+			return nil
+		}
+		out = append(out, float32(f))
+	}
+	return out
+}
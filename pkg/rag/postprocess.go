@@ -0,0 +1,49 @@
+package rag
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// stripTagName limits StripTags entries to plain tag names, so they are safe to put in a regexp.
+var stripTagName = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_:-]*$`)
+
+// tagStripper removes <tag>...</tag> sections, such as reasoning models' <think> blocks, from completions.
+type tagStripper struct {
+	blocks   []*regexp.Regexp
+	closings []*regexp.Regexp
+	openings []*regexp.Regexp
+}
+
+// newTagStripper compiles the patterns for tags, returning nil when there are none.
+func newTagStripper(tags []string) (*tagStripper, error) {
+	if len(tags) == 0 {
+		return nil, nil
+	}
+	stripper := &tagStripper{}
+	for _, tag := range tags {
+		if !stripTagName.MatchString(tag) {
+			return nil, fmt.Errorf("invalid strip tag %q", tag)
+		}
+		quoted := regexp.QuoteMeta(tag)
+		stripper.blocks = append(stripper.blocks, regexp.MustCompile(`(?is)<`+quoted+`\b[^>]*>.*?</`+quoted+`\s*>`))
+		stripper.closings = append(stripper.closings, regexp.MustCompile(`(?is)^.*</`+quoted+`\s*>`))
+		stripper.openings = append(stripper.openings, regexp.MustCompile(`(?is)<`+quoted+`\b[^>]*>.*$`))
+	}
+	return stripper, nil
+}
+
+// strip removes every complete tag section. A closing tag without an opening one drops the text
+// before it, and an unclosed opening tag drops the text after it, since both mean the model's
+// template split the section.
+func (t *tagStripper) strip(text string) string {
+	if t == nil {
+		return text
+	}
+	for i := range t.blocks {
+		text = t.blocks[i].ReplaceAllString(text, "")
+		text = t.closings[i].ReplaceAllString(text, "")
+		text = t.openings[i].ReplaceAllString(text, "")
+	}
+	return text
+}
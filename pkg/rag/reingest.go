@@ -0,0 +1,66 @@
+package rag
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// IngestStats summarises an ingestion run.
+type IngestStats struct {
+	Documents int      `json:"documents"`
+	Chunks    int      `json:"chunks"`
+	Notes     []string `json:"notes,omitempty"`
+}
+
+// Reingest rebuilds the index from sources, persists it, and swaps it into the service.
+// Queries keep using the previous store until the new one is fully built. Remote fetches use the
+// service's retry policy unless sourceOpts sets one.
+func (s *Service) Reingest(ctx context.Context, sourceOpts SourceOptions, chunkOpts ChunkOptions) (IngestStats, error) {
+	if s == nil || s.embedder == nil {
+		return IngestStats{}, errors.New("rag service is not initialized")
+	}
+	if sourceOpts.Retry == (RetryPolicy{}) {
+		sourceOpts.Retry = s.config.Retry
+	}
+	documents, notes, err := CollectDocumentsWithNotes(ctx, sourceOpts)
+	if err != nil {
+		return IngestStats{}, fmt.Errorf("collect documents: %w", err)
+	}
+	if len(documents) == 0 {
+		return IngestStats{}, errors.New("no documents discovered for ingestion")
+	}
+	if sourceOpts.Summarize {
+		notes = append(notes, SummarizeDocuments(ctx, s.chatClient, documents)...)
+	}
+	chunks, chunkNotes := ChunkDocumentsWithNotes(documents, chunkOpts)
+	notes = append(notes, chunkNotes...)
+	meta := MetadataForRun(len(documents), len(chunks))
+	meta.Notes = notes
+	built, err := BuildVectorStore(ctx, chunks, s.embedder, BuildOptions{BatchSize: s.embedBatch, DocumentPrefix: s.docPrefix}, meta)
+	if err != nil {
+		return IngestStats{}, fmt.Errorf("build vector store: %w", err)
+	}
+	if sourceOpts.StoreFullDocuments {
+		built.Documents = documents
+	}
+
+	stats := IngestStats{Documents: len(documents), Chunks: len(chunks), Notes: notes}
+	// Hold the write lock across save and swap so an AddSource or Clear cannot save the
+	// replaced store over the new index.
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	if pg, ok := s.currentStore().(*PgVectorStore); ok {
+		if err := pg.Replace(built.Chunks); err != nil {
+			return IngestStats{}, fmt.Errorf("replace pgvector chunks: %w", err)
+		}
+		return stats, nil
+	}
+	if s.indexPath != "" {
+		if err := built.SaveWithBackups(s.indexPath, s.config.IndexBackups); err != nil {
+			return IngestStats{}, fmt.Errorf("save vector store: %w", err)
+		}
+	}
+	s.swapStore(built)
+	return stats, nil
+}
@@ -0,0 +1,27 @@
+package rag
+
+import (
+	"context"
+	"log"
+)
+
+type requestIDKey struct{}
+
+// WithRequestID returns a context carrying id, which the service includes in its log lines.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFrom returns the request ID stored by WithRequestID, or "".
+func RequestIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// logf logs like log.Printf, prefixed with the context's request ID when there is one.
+func logf(ctx context.Context, format string, args ...any) {
+	if id := RequestIDFrom(ctx); id != "" {
+		format = "[request " + id + "] " + format
+	}
+	log.Printf(format, args...)
+}
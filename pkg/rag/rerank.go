@@ -0,0 +1,100 @@
+package rag
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// DefaultRerankCandidates is how many vector matches are handed to the reranker.
+	DefaultRerankCandidates = 20
+	// rerankBatchSize is the number of passages scored per chat completion.
+	rerankBatchSize = 5
+)
+
+// Reranker reorders retrieval candidates by relevance to the query and keeps the best topK.
+type Reranker interface {
+	Rerank(ctx context.Context, query string, candidates []SearchResult, topK int) ([]SearchResult, error)
+}
+
+// OllamaReranker scores candidates with a local Ollama chat model acting as a cross-encoder.
+// Scores only decide the order; results keep their vector similarity as Score.
+type OllamaReranker struct {
+	client *OllamaChatClient
+}
+
+// NewOllamaReranker constructs a reranker backed by the given Ollama model.
+func NewOllamaReranker(baseURL, model string) *OllamaReranker {
+	return &OllamaReranker{client: NewOllamaChatClient(baseURL, model, GenerationLimits{})}
+}
+
+const rerankSystemPrompt = "You rate how relevant passages are to a search query. Reply only with the requested scores."
+
+// rerankScoreLine matches "3: 7" style replies, tolerating brackets and decimal scores.
+var rerankScoreLine = regexp.MustCompile(`(?m)^\s*\[?(\d+)\]?\s*[:.)=-]\s*(\d+(?:\.\d+)?)`)
+
+// Rerank scores candidates in batches of rerankBatchSize and returns the topK by model score,
+// ties and unscored passages keeping their retrieval order.
+func (r *OllamaReranker) Rerank(ctx context.Context, query string, candidates []SearchResult, topK int) ([]SearchResult, error) {
+	scores := make([]float64, len(candidates))
+	for start := 0; start < len(candidates); start += rerankBatchSize {
+		end := min(start+rerankBatchSize, len(candidates))
+		batch, err := r.scoreBatch(ctx, query, candidates[start:end])
+		if err != nil {
+			return nil, fmt.Errorf("rerank candidates %d-%d: %w", start+1, end, err)
+		}
+		copy(scores[start:end], batch)
+	}
+
+	order := make([]int, len(candidates))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return scores[order[a]] > scores[order[b]]
+	})
+	if topK > 0 && len(order) > topK {
+		order = order[:topK]
+	}
+	out := make([]SearchResult, len(order))
+	for i, idx := range order {
+		out[i] = candidates[idx]
+	}
+	return out, nil
+}
+
+// scoreBatch asks the model for a 0-10 relevance score per passage. Passages the reply omits score -1.
+func (r *OllamaReranker) scoreBatch(ctx context.Context, query string, batch []SearchResult) ([]float64, error) {
+	var b strings.Builder
+	b.WriteString("Rate how relevant each passage is to the query on a scale from 0 (unrelated) to 10 (directly answers it).\n")
+	b.WriteString("Reply with one line per passage in the form \"<passage number>: <score>\" and nothing else.\n\n")
+	fmt.Fprintf(&b, "Query: %s\n", query)
+	for i, candidate := range batch {
+		fmt.Fprintf(&b, "\nPassage %d:\n%s\n", i+1, strings.TrimSpace(candidate.Chunk.Text))
+	}
+
+	reply, err := r.client.Complete(ctx, rerankSystemPrompt, b.String(), DefaultTemperature)
+	if err != nil {
+		return nil, err
+	}
+	scores := make([]float64, len(batch))
+	for i := range scores {
+		scores[i] = -1
+	}
+	for _, m := range rerankScoreLine.FindAllStringSubmatch(reply, -1) {
+		n, err := strconv.Atoi(m[1])
+		if err != nil || n < 1 || n > len(batch) {
+			continue
+		}
+		score, err := strconv.ParseFloat(m[2], 64)
+		if err != nil {
+			continue
+		}
+		scores[n-1] = min(max(score, 0), 10)
+	}
+	return scores, nil
+}
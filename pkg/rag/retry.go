@@ -0,0 +1,211 @@
+package rag
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// Retry defaults, used for zero RetryPolicy fields and by LoadServiceConfigFromEnv.
+const (
+	DefaultRetryAttempts  = 3
+	DefaultRetryBaseDelay = 500 * time.Millisecond
+	DefaultRetryMaxDelay  = 10 * time.Second
+	DefaultRetryJitter    = 0.2
+)
+
+// RetryPolicy controls how transient failures of provider calls and remote fetches are retried.
+// Delays double from BaseDelay up to MaxDelay.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of tries including the first; zero uses
+	// DefaultRetryAttempts and 1 disables retries.
+	MaxAttempts int
+	// BaseDelay is the pause before the first retry; zero uses DefaultRetryBaseDelay.
+	BaseDelay time.Duration
+	// MaxDelay caps a single pause; zero uses DefaultRetryMaxDelay.
+	MaxDelay time.Duration
+	// Jitter randomizes each pause by up to this fraction either way, e.g. 0.2 for ±20%, so
+	// clients that failed together do not retry in lockstep.
+	Jitter float64
+}
+
+// DefaultRetryPolicy returns the policy used when nothing is configured.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: DefaultRetryAttempts,
+		BaseDelay:   DefaultRetryBaseDelay,
+		MaxDelay:    DefaultRetryMaxDelay,
+		Jitter:      DefaultRetryJitter,
+	}
+}
+
+// normalized fills zero fields with defaults and keeps Jitter within [0, 1].
+func (p RetryPolicy) normalized() RetryPolicy {
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = DefaultRetryAttempts
+	}
+	if p.BaseDelay <= 0 {
+		p.BaseDelay = DefaultRetryBaseDelay
+	}
+	if p.MaxDelay <= 0 {
+		p.MaxDelay = DefaultRetryMaxDelay
+	}
+	p.Jitter = min(max(p.Jitter, 0), 1)
+	return p
+}
+
+func (p RetryPolicy) validate() error {
+	if p.MaxAttempts < 0 {
+		return fmt.Errorf("retry attempts must not be negative, got %d", p.MaxAttempts)
+	}
+	if p.BaseDelay < 0 || p.MaxDelay < 0 {
+		return errors.New("retry delays must not be negative")
+	}
+	if p.Jitter < 0 || p.Jitter > 1 {
+		return fmt.Errorf("retry jitter must be between 0 and 1, got %g", p.Jitter)
+	}
+	return nil
+}
+
+// delay returns the pause before the given retry, counting from 1.
+func (p RetryPolicy) delay(retry int) time.Duration {
+	d := p.BaseDelay
+	for i := 1; i < retry && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+	d = min(d, p.MaxDelay)
+	if p.Jitter > 0 {
+		d = time.Duration(float64(d) * (1 + p.Jitter*(2*rand.Float64()-1)))
+	}
+	return d
+}
+
+// permanentError marks a failure that doWithRetry must not retry.
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// permanent wraps err so doWithRetry returns it without retrying.
+func permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+// doWithRetry calls fn until it succeeds, returns a permanent error, the policy runs out of
+// attempts, or ctx is done. It returns fn's last error, unwrapped from permanent.
+func doWithRetry(ctx context.Context, policy RetryPolicy, fn func(ctx context.Context) error) error {
+	policy = policy.normalized()
+	for attempt := 1; ; attempt++ {
+		err := fn(ctx)
+		if err == nil {
+			return nil
+		}
+		var perm *permanentError
+		if errors.As(err, &perm) {
+			return perm.err
+		}
+		if attempt >= policy.MaxAttempts || ctx.Err() != nil {
+			return err
+		}
+		timer := time.NewTimer(policy.delay(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// statusError reports an unsuccessful HTTP response from a provider.
+type statusError struct {
+	op     string
+	code   int
+	status string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("%s failed: %s", e.op, e.status)
+}
+
+// retryableStatus reports whether an HTTP status is worth retrying: rate limits, timeouts, and
+// server errors.
+func retryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code == http.StatusRequestTimeout || code >= http.StatusInternalServerError
+}
+
+// retryableProviderError reports whether a provider call may succeed on another attempt. HTTP
+// errors are classified by status; other failures, e.g. dropped connections, are retried.
+func retryableProviderError(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var status *statusError
+	if errors.As(err, &status) {
+		return retryableStatus(status.code)
+	}
+	var apiErr *openai.APIError
+	if errors.As(err, &apiErr) && apiErr.HTTPStatusCode != 0 {
+		return retryableStatus(apiErr.HTTPStatusCode)
+	}
+	var reqErr *openai.RequestError
+	if errors.As(err, &reqErr) && reqErr.HTTPStatusCode != 0 {
+		return retryableStatus(reqErr.HTTPStatusCode)
+	}
+	return true
+}
+
+// retryCall runs fn under policy, treating non-retryable provider errors as permanent.
+func retryCall(ctx context.Context, policy RetryPolicy, fn func(ctx context.Context) error) error {
+	return doWithRetry(ctx, policy, func(ctx context.Context) error {
+		err := fn(ctx)
+		var perm *permanentError
+		if err != nil && !errors.As(err, &perm) && !retryableProviderError(err) {
+			return permanent(err)
+		}
+		return err
+	})
+}
+
+// retryEmbedder retries failed calls of the wrapped embedder.
+type retryEmbedder struct {
+	Embedder
+	policy RetryPolicy
+}
+
+func (r retryEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	var embeddings [][]float32
+	err := retryCall(ctx, r.policy, func(ctx context.Context) error {
+		var err error
+		embeddings, err = r.Embedder.Embed(ctx, texts)
+		return err
+	})
+	return embeddings, err
+}
+
+func (r retryEmbedder) EmbedOne(ctx context.Context, text string) ([]float32, error) {
+	return embedOne(ctx, r.Embed, text)
+}
+
+// retryChatClient retries failed calls of the wrapped chat client.
+type retryChatClient struct {
+	ChatClient
+	policy RetryPolicy
+}
+
+func (r retryChatClient) Complete(ctx context.Context, systemPrompt, prompt string, temperature float32) (string, error) {
+	var answer string
+	err := retryCall(ctx, r.policy, func(ctx context.Context) error {
+		var err error
+		answer, err = r.ChatClient.Complete(ctx, systemPrompt, prompt, temperature)
+		return err
+	})
+	return answer, err
+}
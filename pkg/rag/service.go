@@ -4,21 +4,73 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"log"
+	"math"
 	"strings"
+	"sync"
+	"text/template"
 	"time"
 )
 
+var (
+	// ErrEmptyQuestion reports a blank question.
+	ErrEmptyQuestion = errors.New("question is required")
+	// ErrNoContext reports that the store holds nothing to search, e.g. before the first ingestion.
+	ErrNoContext = errors.New("no content indexed yet; add sources or run ingestion first")
+	// ErrInvalidEmbedding reports a caller-supplied query embedding that is empty or does not
+	// match the index dimension.
+	ErrInvalidEmbedding = errors.New("invalid query embedding")
+	// ErrUpstream wraps failures of the embedding or chat provider.
+	ErrUpstream = errors.New("upstream provider failed")
+)
+
 // Service wires the vector store, embedder, and LLM together.
 type Service struct {
-	store        *VectorStore
+	mu sync.RWMutex
+	// writeMu serializes changes to the index, from mutating the store through saving it, so an
+	// older snapshot never replaces a newer one on disk.
+	writeMu      sync.Mutex
+	store        Store
+	indexPath    string
 	embedder     Embedder
+	embedModel   string
+	embedBatch   int
+	queryCache   *embeddingCache
 	chatClient   ChatClient
 	systemPrompt string
+	promptTmpl   *template.Template
 	defaultTopK  int
+	chatTimeout  time.Duration
+	threshold    float64
+	refusals     []string
+	// docPrefix and queryPrefix are prepended to texts before embedding; see ServiceConfig.EmbedDocumentPrefix.
+	docPrefix   string
+	queryPrefix string
+	// stripper removes configured wrapper tags from completions; nil when none are set.
+	stripper *tagStripper
+	// reranker, when set, reorders rerankCandidates matches before generation.
+	reranker         Reranker
+	rerankCandidates int
+	// sanitize neutralizes prompt injections in context sections; see ServiceConfig.SanitizeContext.
+	sanitize bool
+	// prices estimate the cost of each answer's Usage.
+	prices map[string]ModelPrice
+	// observeStage, when set, receives the duration of each query stage.
+	observeStage func(stage string, elapsed time.Duration)
+	// config is the configuration the service was built from, reported by Config.
+	config ServiceConfig
 }
 
-// NewService creates a ready-to-use RAG service.
-func NewService(store *VectorStore, embedder Embedder, chatClient ChatClient, cfg ServiceConfig) *Service {
+// Query stages reported to the stage observer.
+const (
+	StageRetrieval  = "retrieval"
+	StageGeneration = "generation"
+)
+
+// NewService creates a ready-to-use RAG service. It fails when the prompt template does not parse, a
+// strip tag is not a plain tag name, or a model price is malformed.
+func NewService(store Store, embedder Embedder, chatClient ChatClient, cfg ServiceConfig) (*Service, error) {
 	topK := cfg.DefaultTopK
 	if topK <= 0 {
 		topK = DefaultTopK
@@ -27,104 +79,693 @@ func NewService(store *VectorStore, embedder Embedder, chatClient ChatClient, cf
 	if prompt == "" {
 		prompt = DefaultSystemPrompt
 	}
+	tmpl, err := parsePromptTemplate(firstNonEmpty(cfg.PromptTemplate, DefaultPromptTemplate))
+	if err != nil {
+		return nil, err
+	}
+	patterns := cfg.RefusalPatterns
+	if patterns == nil {
+		patterns = DefaultRefusalPatterns
+	}
+	refusals := make([]string, 0, len(patterns))
+	for _, p := range patterns {
+		if p = normalizeRefusalText(p); p != "" {
+			refusals = append(refusals, p)
+		}
+	}
+	stripper, err := newTagStripper(cfg.StripTags)
+	if err != nil {
+		return nil, err
+	}
+	prices, err := parseModelPrices(cfg.ModelPrices, DefaultModelPrices)
+	if err != nil {
+		return nil, err
+	}
+	var reranker Reranker
+	if cfg.RerankModel != "" {
+		reranker = NewOllamaReranker(cfg.OllamaBaseURL, cfg.RerankModel)
+	}
+	candidates := cfg.RerankCandidates
+	if candidates <= 0 {
+		candidates = DefaultRerankCandidates
+	}
 	return &Service{
 		store:        store,
+		indexPath:    cfg.IndexPath,
 		embedder:     embedder,
+		embedModel:   cfg.EmbeddingModel,
+		embedBatch:   cfg.EmbedBatchSize,
+		docPrefix:    cfg.EmbedDocumentPrefix,
+		queryPrefix:  cfg.EmbedQueryPrefix,
+		queryCache:   newEmbeddingCache(cfg.QueryCacheSize),
 		chatClient:   chatClient,
 		systemPrompt: prompt,
+		promptTmpl:   tmpl,
 		defaultTopK:  topK,
-	}
+		chatTimeout:  cfg.Timeout,
+		threshold:    cfg.ScoreThreshold,
+		refusals:     refusals,
+		stripper:     stripper,
+
+		reranker:         reranker,
+		rerankCandidates: candidates,
+		sanitize:         cfg.SanitizeContext,
+		prices:           prices,
+		config:           cfg,
+	}, nil
 }
 
 // NewServiceFromEnv loads configuration and supporting assets from disk.
 func NewServiceFromEnv(ctx context.Context) (*Service, error) {
 	cfg := LoadServiceConfigFromEnv()
-	store, err := LoadVectorStore(cfg.IndexPath)
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	store, err := OpenOrCreateStore(cfg)
 	if err != nil {
-		return nil, fmt.Errorf("load vector store: %w", err)
+		return nil, fmt.Errorf("open %s store: %w", firstNonEmpty(cfg.Store, StoreJSON), err)
 	}
 	embedder, err := NewEmbedder(cfg)
 	if err != nil {
 		return nil, err
 	}
+	if fallback, ok := embedder.(*FallbackEmbedder); ok {
+		fallback.Dimension = store.Meta().EmbeddingDim
+	}
 	chatClient, err := NewChatClient(cfg)
 	if err != nil {
 		return nil, err
 	}
-	return NewService(store, embedder, chatClient, cfg), nil
+	service, err := NewService(store, embedder, chatClient, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	verifyCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	if err := service.VerifyEmbedderCompatibility(verifyCtx); err != nil {
+		log.Printf("WARNING: RAG embedder check failed, answers may be wrong until you re-ingest: %v", err)
+	}
+	return service, nil
+}
+
+// currentStore returns the active store; Reingest may swap it concurrently.
+func (s *Service) currentStore() Store {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.store
+}
+
+func (s *Service) swapStore(store Store) {
+	s.mu.Lock()
+	s.store = store
+	s.mu.Unlock()
+}
+
+// SetStageObserver registers fn to receive how long retrieval (including reranking) and generation
+// took for every answered question. It must be called before the service handles queries.
+func (s *Service) SetStageObserver(fn func(stage string, elapsed time.Duration)) {
+	s.observeStage = fn
+}
+
+// observe reports the time since started for stage to the stage observer, if any.
+func (s *Service) observe(stage string, started time.Time) {
+	if s.observeStage != nil {
+		s.observeStage(stage, time.Since(started))
+	}
+}
+
+// ChunkCount reports the number of chunks in the active store.
+func (s *Service) ChunkCount() int {
+	return s.currentStore().Len()
+}
+
+// DefaultTopK reports how many chunks a query retrieves when no TopK is supplied.
+func (s *Service) DefaultTopK() int {
+	return s.defaultTopK
 }
 
-// Answer runs retrieval + generation.
+// MaxConcurrentQueries reports how many queries the API may answer at once; zero is unlimited.
+func (s *Service) MaxConcurrentQueries() int {
+	return s.config.MaxConcurrentQueries
+}
+
+// Config reports the non-secret configuration the service was built from.
+func (s *Service) Config() PublicConfig {
+	return s.config.Public()
+}
+
+// QueryTimeout is a suitable deadline for a full query: the configured chat timeout plus
+// headroom for embedding and search, never less than 45s.
+func (s *Service) QueryTimeout() time.Duration {
+	const minimum = 45 * time.Second
+	if timeout := s.chatTimeout + 15*time.Second; timeout > minimum {
+		return timeout
+	}
+	return minimum
+}
+
+// Answer runs retrieval + generation, reranking the matches in between when a reranker is configured.
 func (s *Service) Answer(ctx context.Context, question string, opts QueryOptions) (*Answer, error) {
-	if s == nil || s.store == nil {
-		return nil, errors.New("rag service is not initialized")
+	return s.answer(ctx, question, opts, nil)
+}
+
+// AnswerStream answers like Answer but passes the model's output to onDelta as it is generated,
+// when the chat client supports streaming, or in one piece otherwise. Deltas are the raw model
+// output; the returned Answer holds the cleaned-up text. onDelta is not called when no context
+// was found, and an error from it aborts the answer.
+func (s *Service) AnswerStream(ctx context.Context, question string, opts QueryOptions, onDelta func(string) error) (*Answer, error) {
+	return s.answer(ctx, question, opts, onDelta)
+}
+
+func (s *Service) answer(ctx context.Context, question string, opts QueryOptions, onDelta func(string) error) (*Answer, error) {
+	trimmed, opts, err := s.prepareQuery(question, opts)
+	if err != nil {
+		return nil, err
 	}
-	trimmed := strings.TrimSpace(question)
-	if trimmed == "" {
-		return nil, errors.New("question is required")
+	ctx, meter := withUsageMeter(ctx, s.prices)
+	started := time.Now()
+	matches, err := s.retrieve(ctx, trimmed, s.candidateOptions(opts))
+	if err != nil {
+		logf(ctx, "rag retrieval failed after %s: %v", time.Since(started).Round(time.Millisecond), err)
+		return nil, err
 	}
-	if opts.TopK <= 0 {
-		opts.TopK = s.defaultTopK
+	matches = s.rerank(ctx, trimmed, matches, opts.TopK)
+	retrieval := time.Since(started)
+	s.observe(StageRetrieval, started)
+
+	generationStarted := time.Now()
+	answer, err := s.timedGenerate(ctx, trimmed, matches, opts, onDelta)
+	generation := time.Since(generationStarted)
+	if err != nil {
+		logf(ctx, "rag generation failed after %s: %v", generation.Round(time.Millisecond), err)
+		return nil, err
+	}
+	answer.Usage = meter.snapshot()
+	logf(ctx, "rag answer: %d sources, retrieval %s, generation %s", len(matches), retrieval.Round(time.Millisecond), generation.Round(time.Millisecond))
+	return answer, nil
+}
+
+// AnswerWithEmbedding answers question like Answer but searches with a caller-supplied embedding
+// instead of embedding the question, so clients with their own embedding pipeline skip a provider
+// call. The embedding must have the index's dimension. Query variants are ignored since they would
+// need embedding; the question is still used for reranking, keyword fallback, and the prompt.
+func (s *Service) AnswerWithEmbedding(ctx context.Context, question string, embedding []float32, opts QueryOptions) (*Answer, error) {
+	trimmed, opts, err := s.prepareQuery(question, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkQueryDimension(embedding); err != nil {
+		return nil, err
+	}
+	answer, err := s.answerEmbedded(ctx, trimmed, embedding, opts)
+	if err != nil {
+		logf(ctx, "rag answer with precomputed embedding failed: %v", err)
+		return nil, err
+	}
+	return answer, nil
+}
+
+// checkQueryDimension rejects, with ErrInvalidEmbedding, a caller-supplied query embedding whose
+// length differs from the stored vectors.
+func (s *Service) checkQueryDimension(embedding []float32) error {
+	if len(embedding) == 0 {
+		return fmt.Errorf("%w: embedding is empty", ErrInvalidEmbedding)
+	}
+	store := s.currentStore()
+	want := store.Meta().EmbeddingDim
+	if vs, ok := store.(*VectorStore); ok && want == 0 {
+		want = vs.embeddingDim()
+	}
+	if want > 0 && len(embedding) != want {
+		return fmt.Errorf("%w: %w: embedding has %d dimensions, the index has %d", ErrInvalidEmbedding, ErrDimensionMismatch, len(embedding), want)
+	}
+	return nil
+}
+
+// answerEmbedded runs search, reranking, and generation for a question whose embedding is already known.
+func (s *Service) answerEmbedded(ctx context.Context, question string, embedding []float32, opts QueryOptions) (*Answer, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
 	}
-	if opts.Temperature == 0 {
-		opts.Temperature = 0.2
+	ctx, meter := withUsageMeter(ctx, s.prices)
+	started := time.Now()
+	matches, err := s.search(ctx, question, embedding, s.candidateOptions(opts))
+	if err != nil {
+		return nil, err
 	}
+	matches = s.rerank(ctx, question, matches, opts.TopK)
+	s.observe(StageRetrieval, started)
+	answer, err := s.timedGenerate(ctx, question, matches, opts, nil)
+	if err != nil {
+		return nil, err
+	}
+	answer.Usage = meter.snapshot()
+	return answer, nil
+}
 
-	embeddings, err := s.embedder.Embed(ctx, []string{trimmed})
+// timedGenerate runs generate, reports its duration when the chat client was called, and attaches
+// the score statistics of matches.
+func (s *Service) timedGenerate(ctx context.Context, question string, matches []SearchResult, opts QueryOptions, onDelta func(string) error) (*Answer, error) {
+	started := time.Now()
+	answer, err := s.generate(ctx, question, matches, opts, onDelta)
+	if len(matches) > 0 {
+		s.observe(StageGeneration, started)
+	}
 	if err != nil {
 		return nil, err
 	}
-	if len(embeddings) == 0 {
-		return nil, errors.New("empty query embedding")
+	answer.ScoreStats = scoreStats(matches)
+	return answer, nil
+}
+
+// candidateOptions widens TopK to the rerank candidate count when a reranker is configured.
+func (s *Service) candidateOptions(opts QueryOptions) QueryOptions {
+	if s.reranker != nil && s.rerankCandidates > opts.TopK {
+		opts.TopK = s.rerankCandidates
 	}
+	return opts
+}
 
-	matches := s.store.Search(embeddings[0], opts.TopK)
+// rerank reorders matches with the configured reranker and keeps topK. A failing reranker is
+// logged and the vector order is used instead, so reranking never fails a query.
+func (s *Service) rerank(ctx context.Context, question string, matches []SearchResult, topK int) []SearchResult {
+	if s.reranker != nil && len(matches) > 1 {
+		reranked, err := s.reranker.Rerank(ctx, question, matches, topK)
+		if err == nil {
+			return reranked
+		}
+		logf(ctx, "rerank failed, using vector order: %v", err)
+	}
+	if len(matches) > topK {
+		matches = matches[:topK]
+	}
+	return matches
+}
+
+// generate asks the chat client to answer question from matches and attributes the sources. With
+// onDelta set, the answer is streamed to it.
+func (s *Service) generate(ctx context.Context, question string, matches []SearchResult, opts QueryOptions, onDelta func(string) error) (*Answer, error) {
 	if len(matches) == 0 {
-		return nil, errors.New("no context available; run ingestion first")
+		return &Answer{Answer: NoAnswerMessage, Answered: false, Sources: []SourceAttribution{}}, nil
 	}
 
-	prompt := buildPrompt(trimmed, matches)
-	answer, err := s.chatClient.Complete(ctx, s.systemPrompt, prompt, opts.Temperature)
+	tmpl := s.promptTmpl
+	if opts.PromptTemplate != "" {
+		var err error
+		tmpl, err = parsePromptTemplate(opts.PromptTemplate)
+		if err != nil {
+			return nil, err
+		}
+	}
+	sections := matches
+	if opts.ContextWindow > 0 {
+		sections = s.expandContext(matches, opts.ContextWindow)
+	}
+	prompt, flagged, err := buildPrompt(tmpl, question, sections, opts.ContextOrder, s.sanitize)
 	if err != nil {
 		return nil, err
 	}
+	if flagged > 0 {
+		logf(ctx, "rag prompt: neutralized possible prompt injection in %d of %d context sections", flagged, len(matches))
+	}
+	if s.config.FocusTerms {
+		prompt = focusPrompt(question) + prompt
+	}
+	prompt = historyPrompt(opts.History) + prompt
+	systemPrompt := firstNonEmpty(opts.SystemPrompt, s.systemPrompt)
+	if s.sanitize {
+		systemPrompt += "\n\n" + UntrustedContextInstruction
+	}
+	if instruction := verbosityInstruction(opts.Verbosity); instruction != "" {
+		systemPrompt += "\n\n" + instruction
+	}
+	ctx = withVerbosity(ctx, opts.Verbosity)
+	var debugPrompt string
+	if opts.ReturnPrompt {
+		debugPrompt = prompt
+	}
+	var answer string
+	if onDelta != nil {
+		answer, err = completeStream(ctx, s.chatClient, systemPrompt, prompt, opts.temperature(), onDelta)
+	} else {
+		answer, err = s.chatClient.Complete(ctx, systemPrompt, prompt, opts.temperature())
+	}
+	if err != nil {
+		err = fmt.Errorf("%w: %w", ErrUpstream, err)
+		if !opts.FallbackToSources {
+			return nil, err
+		}
+		return &Answer{Answer: SourcesOnlyMessage, Answered: false, Sources: attributeSources(question, matches, opts), Error: err.Error(), DebugPrompt: debugPrompt, FlaggedSections: flagged}, nil
+	}
+
+	answer = strings.TrimSpace(s.stripper.strip(answer))
+	return &Answer{Answer: answer, Answered: !s.isRefusal(answer), Sources: attributeSources(question, matches, opts), DebugPrompt: debugPrompt, FlaggedSections: flagged}, nil
+}
+
+// historyPrompt quotes earlier conversation turns for the prompt, or returns "" when there are none.
+func historyPrompt(history []ChatTurn) string {
+	if len(history) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("Conversation so far:\n")
+	for _, turn := range history {
+		fmt.Fprintf(&b, "User: %s\nAssistant: %s\n", strings.TrimSpace(turn.Question), strings.TrimSpace(turn.Answer))
+	}
+	b.WriteString("\n")
+	return b.String()
+}
 
+// attributeSources converts matches into attributions, honouring IncludeFullText, DedupeSources,
+// and HighlightTerms.
+func attributeSources(question string, matches []SearchResult, opts QueryOptions) []SourceAttribution {
+	marker := firstNonEmpty(opts.HighlightMarker, DefaultHighlightMarker)
 	attributions := make([]SourceAttribution, len(matches))
 	for i, match := range matches {
 		snippet := strings.TrimSpace(match.Chunk.Text)
 		if len(snippet) > 400 {
 			snippet = snippet[:400] + "..."
 		}
+		if opts.HighlightTerms {
+			snippet = highlightTerms(snippet, question, marker)
+		}
 		attributions[i] = SourceAttribution{
-			Title:   match.Chunk.Source,
-			URI:     match.Chunk.URI,
-			Snippet: snippet,
-			Score:   match.Score,
+			DocumentID:   match.Chunk.DocumentID,
+			Title:        match.Chunk.Source,
+			URI:          match.Chunk.URI,
+			Snippet:      snippet,
+			Score:        match.Score,
+			StartOffset:  match.Chunk.StartOffset,
+			EndOffset:    match.Chunk.EndOffset,
+			KeywordMatch: match.KeywordMatch,
+			Location:     match.Chunk.Location(),
+		}
+		if opts.IncludeFullText {
+			attributions[i].FullText = match.Chunk.Text
 		}
 	}
 
-	return &Answer{Answer: strings.TrimSpace(answer), Sources: attributions}, nil
+	if opts.DedupeSources {
+		attributions = dedupeAttributions(attributions)
+	}
+	return attributions
 }
 
-func buildPrompt(question string, matches []SearchResult) string {
-	var b strings.Builder
-	b.WriteString("Context sections (most relevant to least):\n")
-	for i, match := range matches {
-		b.WriteString(fmt.Sprintf("[%d] Source: %s (%s)\n", i+1, match.Chunk.Source, match.Chunk.URI))
-		b.WriteString(match.Chunk.Text)
-		b.WriteString("\n\n")
+// Retrieve embeds the question and returns the ranked chunks without invoking the chat client.
+// Matches below the score threshold are dropped; when none remain a keyword search is tried,
+// so the result may be empty.
+func (s *Service) Retrieve(ctx context.Context, question string, opts QueryOptions) ([]SearchResult, error) {
+	trimmed, opts, err := s.prepareQuery(question, opts)
+	if err != nil {
+		return nil, err
+	}
+	return s.retrieve(ctx, trimmed, opts)
+}
+
+// prepareQuery validates the question and fills option defaults.
+func (s *Service) prepareQuery(question string, opts QueryOptions) (string, QueryOptions, error) {
+	if s == nil || s.currentStore() == nil {
+		return "", opts, errors.New("rag service is not initialized")
+	}
+	trimmed := strings.TrimSpace(question)
+	if trimmed == "" {
+		return "", opts, ErrEmptyQuestion
+	}
+	// An empty JSON index, e.g. one created because the file was missing, cannot match anything,
+	// so skip the embedding call.
+	if vs, ok := s.currentStore().(*VectorStore); ok && vs.Len() == 0 {
+		return "", opts, ErrNoContext
+	}
+	opts.SystemPrompt = strings.TrimSpace(opts.SystemPrompt)
+	if opts.TopK <= 0 {
+		opts.TopK = s.defaultTopK
+	}
+	if opts.ScoreThreshold <= 0 {
+		opts.ScoreThreshold = s.threshold
 	}
+	return trimmed, opts, nil
+}
 
-	b.WriteString("Instructions:\n")
-	b.WriteString("1. Use only the provided context sections.\n")
-	b.WriteString("2. If the answer is not present, say you do not have that information.\n")
-	b.WriteString("3. When relevant, cite the source title in parentheses.\n")
-	b.WriteString("4. Highlight Amazon-specific constraints (rate limits, launch phases, pilots) explicitly.\n")
+// retrieve embeds an already-trimmed question and searches the active store. With query variants
+// every phrasing is searched and the rankings are fused.
+func (s *Service) retrieve(ctx context.Context, question string, opts QueryOptions) ([]SearchResult, error) {
+	if len(opts.QueryVariants) > 0 || opts.AutoVariants > 0 {
+		return s.retrieveMultiQuery(ctx, question, opts)
+	}
+	embedding, err := s.embedQuery(ctx, question)
+	if err != nil {
+		return nil, err
+	}
+	return s.search(ctx, question, embedding, opts)
+}
 
-	b.WriteString("\nQuestion:\n")
-	b.WriteString(question)
+// search ranks stored chunks against an embedded question, applying the score threshold and the
+// keyword fallback.
+func (s *Service) search(ctx context.Context, question string, embedding []float32, opts QueryOptions) ([]SearchResult, error) {
+	store := s.currentStore()
+	matches := searchStore(store, embedding, opts)
+	if vs, ok := store.(*VectorStore); ok {
+		vs.warnDimensionMismatch(ctx, len(embedding))
+	}
+	if len(matches) > 0 {
+		return matches, nil
+	}
+	switch {
+	case store.Len() == 0:
+		return nil, ErrNoContext
+	case len(opts.Sources) > 0:
+		// The index has content, just none from the requested sources above the threshold.
+		return nil, nil
+	case opts.ScoreThreshold > 0:
+		return s.keywordFallback(question, opts.TopK), nil
+	default:
+		return nil, ErrNoContext
+	}
+}
 
-	return b.String()
+// keywordFallback runs a typo-tolerant keyword search when the store supports it and no vector
+// match cleared the score threshold.
+func (s *Service) keywordFallback(question string, topK int) []SearchResult {
+	searcher, ok := s.currentStore().(interface {
+		KeywordSearch(terms []string, topK int) []SearchResult
+	})
+	if !ok {
+		return nil
+	}
+	return searcher.KeywordSearch(strings.Fields(question), topK)
+}
+
+// embedQuery embeds an already-trimmed question, reusing cached embeddings for repeated questions.
+func (s *Service) embedQuery(ctx context.Context, question string) ([]float32, error) {
+	key := s.embedModel + "\x00" + question
+	if embedding, ok := s.queryCache.get(key); ok {
+		return embedding, nil
+	}
+	embedding, err := s.embedder.EmbedOne(ctx, s.queryPrefix+question)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUpstream, err)
+	}
+	s.queryCache.put(key, embedding)
+	return embedding, nil
+}
+
+// searchStore runs the store search, applying the source filter, priorities, and per-source cap
+// when requested. Filtering and capping rank every chunk when the store can score them all;
+// otherwise, like reweighting, they over-fetch so other sources can still surface. The store
+// skips chunks whose similarity is below opts.ScoreThreshold, before any priority weight applies.
+func searchStore(store Store, embedding []float32, opts QueryOptions) []SearchResult {
+	minScore := math.Inf(-1)
+	if opts.ScoreThreshold > 0 {
+		minScore = opts.ScoreThreshold
+	}
+	if len(opts.Sources) == 0 && opts.MaxPerSource <= 0 {
+		if len(opts.SourcePriority) == 0 {
+			return store.SearchWithMin(embedding, opts.TopK, minScore)
+		}
+		if weighted, ok := store.(interface {
+			SearchWeighted(query []float32, topK int, weights map[string]float64, minScore float64) []SearchResult
+		}); ok {
+			return weighted.SearchWeighted(embedding, opts.TopK, opts.SourcePriority, minScore)
+		}
+	}
+
+	var matches []SearchResult
+	if scorer, ok := store.(interface {
+		ScoreAll(query []float32, minScore float64) []SearchResult
+	}); ok && (len(opts.Sources) > 0 || opts.MaxPerSource > 0) {
+		matches = scorer.ScoreAll(embedding, minScore)
+	} else {
+		matches = store.SearchWithMin(embedding, opts.TopK*4, minScore)
+	}
+	matches = filterBySource(matches, opts.Sources)
+	matches = applySourcePriority(matches, opts.SourcePriority)
+	matches = limitPerSource(matches, opts.MaxPerSource)
+	if len(matches) > opts.TopK {
+		matches = matches[:opts.TopK]
+	}
+	return matches
+}
+
+// filterBySource keeps matches whose DocumentID or Source is in sources; empty keeps everything.
+func filterBySource(matches []SearchResult, sources []string) []SearchResult {
+	if len(sources) == 0 {
+		return matches
+	}
+	allowed := map[string]struct{}{}
+	for _, source := range sources {
+		allowed[source] = struct{}{}
+	}
+	kept := matches[:0]
+	for _, match := range matches {
+		_, byID := allowed[match.Chunk.DocumentID]
+		_, bySource := allowed[match.Chunk.Source]
+		if byID || bySource {
+			kept = append(kept, match)
+		}
+	}
+	return kept
+}
+
+// limitPerSource keeps, in order, at most maxPerSource matches from each document, identified by
+// DocumentID or, failing that, Source. Zero or less keeps everything.
+func limitPerSource(matches []SearchResult, maxPerSource int) []SearchResult {
+	if maxPerSource <= 0 {
+		return matches
+	}
+	counts := map[string]int{}
+	kept := matches[:0]
+	for _, match := range matches {
+		key := firstNonEmpty(match.Chunk.DocumentID, match.Chunk.Source)
+		if counts[key] >= maxPerSource {
+			continue
+		}
+		counts[key]++
+		kept = append(kept, match)
+	}
+	return kept
+}
+
+// dedupeAttributions keeps one attribution per document (by DocumentID, else URI), choosing the
+// highest score and its snippet. The order of first appearance is preserved.
+func dedupeAttributions(attributions []SourceAttribution) []SourceAttribution {
+	index := map[string]int{}
+	deduped := make([]SourceAttribution, 0, len(attributions))
+	for _, attr := range attributions {
+		key := firstNonEmpty(attr.DocumentID, attr.URI)
+		if i, ok := index[key]; ok {
+			if attr.Score > deduped[i].Score {
+				deduped[i] = attr
+			}
+			continue
+		}
+		index[key] = len(deduped)
+		deduped = append(deduped, attr)
+	}
+	return deduped
+}
+
+// isRefusal reports whether the completion matches one of the configured refusal phrases.
+func (s *Service) isRefusal(answer string) bool {
+	if answer == "" {
+		return true
+	}
+	normalized := normalizeRefusalText(answer)
+	for _, pattern := range s.refusals {
+		if strings.Contains(normalized, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeRefusalText(text string) string {
+	text = strings.ReplaceAll(text, "’", "'")
+	return strings.ToLower(strings.Join(strings.Fields(text), " "))
+}
+
+// promptData is the value passed to prompt templates.
+type promptData struct {
+	Question string
+	Sources  []promptSource
+}
+
+// promptSource describes one retrieved context section; Number is 1-based.
+type promptSource struct {
+	Number int
+	Source string
+	URI    string
+	Text   string
+	Score  float64
+	// Summary is the document summary generated at ingestion, empty unless summarization ran.
+	Summary string
+}
+
+func parsePromptTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("prompt").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("parse prompt template: %w", err)
+	}
+	// Execute against sample data so references to unknown fields fail up front.
+	sample := promptData{Question: "?", Sources: []promptSource{{Number: 1}}}
+	if err := tmpl.Execute(io.Discard, sample); err != nil {
+		return nil, fmt.Errorf("validate prompt template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// buildPrompt renders matches, ranked best first, in the given order. Sections keep their rank as
+// Number so citations line up with the answer's sources. With sanitize set, section text is cleaned
+// and delimited, and the number of sections that contained injection patterns is returned.
+func buildPrompt(tmpl *template.Template, question string, matches []SearchResult, order ContextOrder, sanitize bool) (string, int, error) {
+	sources := make([]promptSource, len(matches))
+	for i, match := range matches {
+		sources[i] = promptSource{
+			Number:  i + 1,
+			Source:  match.Chunk.Source,
+			URI:     match.Chunk.URI,
+			Text:    match.Chunk.Text,
+			Score:   match.Score,
+			Summary: match.Chunk.Summary,
+		}
+	}
+	flagged := 0
+	if sanitize {
+		flagged = sanitizeSections(sources)
+	}
+	data := promptData{Question: question, Sources: orderSections(sources, order)}
+
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", 0, fmt.Errorf("render prompt: %w", err)
+	}
+	return b.String(), flagged, nil
+}
+
+// orderSections rearranges sections ranked best first. LostInMiddle places ranks 1, 3, 5, ... from
+// the front and 2, 4, 6, ... from the back, so the two best sections sit at either end.
+func orderSections(sections []promptSource, order ContextOrder) []promptSource {
+	ordered := make([]promptSource, len(sections))
+	switch order {
+	case ContextOrderLeastFirst:
+		for i, section := range sections {
+			ordered[len(sections)-1-i] = section
+		}
+	case ContextOrderLostInMiddle:
+		front, back := 0, len(sections)-1
+		for i, section := range sections {
+			if i%2 == 0 {
+				ordered[front] = section
+				front++
+			} else {
+				ordered[back] = section
+				back--
+			}
+		}
+	default:
+		copy(ordered, sections)
+	}
+	return ordered
 }
 
 // MetadataForRun captures metadata for ingestion runs.
@@ -135,3 +776,18 @@ func MetadataForRun(sourceCount, chunkCount int) Metadata {
 		ChunkCount:  chunkCount,
 	}
 }
+
+// Warmup issues a tiny embedding and a trivial chat completion so providers that load models
+// lazily, like Ollama, do so before the first real query.
+func (s *Service) Warmup(ctx context.Context) error {
+	if s == nil || s.embedder == nil || s.chatClient == nil {
+		return errors.New("rag service is not initialized")
+	}
+	if _, err := s.embedder.EmbedOne(ctx, "warmup"); err != nil {
+		return fmt.Errorf("warm up embedder: %w", err)
+	}
+	if _, err := s.chatClient.Complete(ctx, "Reply with OK.", "ping", 0); err != nil {
+		return fmt.Errorf("warm up chat model: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package rag
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrChunkNotFound reports a chunk ID that is not in the store.
+var ErrChunkNotFound = errors.New("chunk not found")
+
+// SimilarTo returns the topK chunks most similar to the stored chunk chunkID, excluding the chunk
+// itself. It reuses the stored embedding, so no model is called.
+func (vs *VectorStore) SimilarTo(chunkID string, topK int) ([]SearchResult, error) {
+	if topK <= 0 {
+		topK = 4
+	}
+	var embedding []float32
+	vs.mu.RLock()
+	for i := range vs.Chunks {
+		if vs.Chunks[i].ID == chunkID {
+			embedding = vs.Chunks[i].Embedding
+			break
+		}
+	}
+	vs.mu.RUnlock()
+	if embedding == nil {
+		return nil, fmt.Errorf("%w: %s", ErrChunkNotFound, chunkID)
+	}
+	return excludeChunk(vs.Search(embedding, topK+1), chunkID, topK), nil
+}
+
+// SimilarTo returns the topK chunks most similar to the stored chunk chunkID, excluding the chunk
+// itself.
+func (ps *PgVectorStore) SimilarTo(chunkID string, topK int) ([]SearchResult, error) {
+	if topK <= 0 {
+		topK = 4
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), pgSearchTimeout)
+	defer cancel()
+	var rows []string
+	err := ps.db.WithContext(ctx).Raw(`SELECT embedding::text FROM chunks WHERE id = ? LIMIT 1`, chunkID).Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("look up chunk %s: %w", chunkID, err)
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrChunkNotFound, chunkID)
+	}
+	return excludeChunk(ps.Search(parseVector(rows[0]), topK+1), chunkID, topK), nil
+}
+
+// excludeChunk drops chunkID from results and keeps at most topK.
+func excludeChunk(results []SearchResult, chunkID string, topK int) []SearchResult {
+	kept := results[:0]
+	for _, result := range results {
+		if result.Chunk.ID != chunkID {
+			kept = append(kept, result)
+		}
+	}
+	if len(kept) > topK {
+		kept = kept[:topK]
+	}
+	return kept
+}
+
+// SimilarTo returns the topK chunks most similar to chunkID in the active store. It fails with
+// ErrChunkNotFound for unknown IDs.
+func (s *Service) SimilarTo(chunkID string, topK int) ([]SearchResult, error) {
+	if s == nil || s.currentStore() == nil {
+		return nil, errors.New("rag service is not initialized")
+	}
+	finder, ok := s.currentStore().(interface {
+		SimilarTo(chunkID string, topK int) ([]SearchResult, error)
+	})
+	if !ok {
+		return nil, errors.New("store does not support similar-chunk lookup")
+	}
+	if topK <= 0 {
+		topK = s.defaultTopK
+	}
+	return finder.SimilarTo(chunkID, topK)
+}
@@ -0,0 +1,154 @@
+package rag
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SitemapSource declares a sitemap.xml whose listed pages are ingested as HTML documents.
+type SitemapSource struct {
+	URL string
+	// FilterPrefix, when set, keeps only page URLs that start with it.
+	FilterPrefix string
+	// MaxURLs caps the number of pages fetched; zero uses DefaultSitemapMaxURLs.
+	MaxURLs int
+	// ModifiedSince skips pages whose lastmod is older. Pages without lastmod are always fetched.
+	ModifiedSince time.Time
+}
+
+const (
+	DefaultSitemapMaxURLs = 200
+	// maxSitemapDepth bounds how many levels of nested sitemap indexes are followed.
+	maxSitemapDepth = 3
+)
+
+// sitemapDocument covers both <urlset> and <sitemapindex> roots.
+type sitemapDocument struct {
+	XMLName  xml.Name       `xml:""`
+	URLs     []sitemapEntry `xml:"url"`
+	Sitemaps []sitemapEntry `xml:"sitemap"`
+}
+
+type sitemapEntry struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod"`
+}
+
+// collectSitemapDocuments fetches the sitemap (following nested indexes) and each listed page.
+func collectSitemapDocuments(ctx context.Context, f *fetcher, src SitemapSource) ([]Document, []string, error) {
+	maxURLs := src.MaxURLs
+	if maxURLs <= 0 {
+		maxURLs = DefaultSitemapMaxURLs
+	}
+
+	var notes []string
+	entries, err := expandSitemap(ctx, f, src.URL, 0, &notes)
+	if err != nil {
+		return nil, notes, err
+	}
+
+	var documents []Document
+	seen := map[string]struct{}{}
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return nil, nil, err
+		}
+		loc := strings.TrimSpace(entry.Loc)
+		if loc == "" || (src.FilterPrefix != "" && !strings.HasPrefix(loc, src.FilterPrefix)) {
+			continue
+		}
+		if _, dup := seen[loc]; dup {
+			continue
+		}
+		seen[loc] = struct{}{}
+		if modified, ok := parseLastMod(entry.LastMod); ok && !src.ModifiedSince.IsZero() && modified.Before(src.ModifiedSince) {
+			continue
+		}
+		if len(documents) >= maxURLs {
+			notes = append(notes, fmt.Sprintf("sitemap %s stopped at %d pages", src.URL, maxURLs))
+			break
+		}
+
+		body, err := f.get(ctx, loc)
+		if err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return nil, nil, ctxErr
+			}
+			notes = append(notes, fmt.Sprintf("sitemap skipped %s: %v", loc, err))
+			continue
+		}
+		title, _ := parseHTMLPage(string(body))
+		text, err := convertPayload(string(body), FormatHTML, f.htmlOpts)
+		if err != nil {
+			notes = append(notes, fmt.Sprintf("sitemap skipped %s: %v", loc, err))
+			continue
+		}
+		if title == "" {
+			title = loc
+		}
+		documents = append(documents, Document{
+			ID:      Slugify(loc),
+			Title:   title,
+			URI:     loc,
+			Source:  "sitemap: " + src.URL,
+			Content: text,
+
+			fromHTML: true,
+		})
+	}
+
+	if len(documents) == 0 {
+		return nil, notes, errors.New("no pages could be fetched")
+	}
+	return documents, notes, nil
+}
+
+// expandSitemap returns the page entries of the sitemap at rawURL, recursing into sitemap indexes.
+// Nested sitemaps that fail to load are noted and skipped; the root sitemap must load.
+func expandSitemap(ctx context.Context, f *fetcher, rawURL string, depth int, notes *[]string) ([]sitemapEntry, error) {
+	body, err := f.get(ctx, rawURL)
+	if err != nil {
+		return nil, err
+	}
+	var doc sitemapDocument
+	if err := xml.Unmarshal(body, &doc); err != nil {
+		return nil, fmt.Errorf("parse sitemap %s: %w", rawURL, err)
+	}
+
+	entries := doc.URLs
+	for _, nested := range doc.Sitemaps {
+		loc := strings.TrimSpace(nested.Loc)
+		if loc == "" {
+			continue
+		}
+		if depth+1 >= maxSitemapDepth {
+			*notes = append(*notes, fmt.Sprintf("sitemap skipped %s: nested too deeply", loc))
+			continue
+		}
+		children, err := expandSitemap(ctx, f, loc, depth+1, notes)
+		if err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return nil, ctxErr
+			}
+			*notes = append(*notes, fmt.Sprintf("sitemap skipped %s: %v", loc, err))
+			continue
+		}
+		entries = append(entries, children...)
+	}
+	return entries, nil
+}
+
+// parseLastMod accepts the W3C datetime forms used by sitemaps.
+func parseLastMod(raw string) (time.Time, bool) {
+	raw = strings.TrimSpace(raw)
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04Z07:00", "2006-01-02"} {
+		if t, err := time.Parse(layout, raw); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,85 @@
+package rag
+
+import (
+	"log"
+	"sort"
+	"time"
+)
+
+// SourceSummary describes one ingested document and when it was fetched.
+type SourceSummary struct {
+	DocumentID string `json:"documentId"`
+	Title      string `json:"title"`
+	URI        string `json:"uri"`
+	Chunks     int    `json:"chunks"`
+	// FetchedAt is the oldest fetch time among the document's chunks; zero when the chunks were
+	// ingested before fetch times were recorded.
+	FetchedAt time.Time `json:"fetchedAt"`
+}
+
+// sourceSummarizer is implemented by stores that can list their documents.
+type sourceSummarizer interface {
+	SourceSummaries() ([]SourceSummary, error)
+}
+
+// SourceSummaries groups the stored chunks by document.
+func (vs *VectorStore) SourceSummaries() ([]SourceSummary, error) {
+	vs.mu.RLock()
+	defer vs.mu.RUnlock()
+	index := map[string]int{}
+	var summaries []SourceSummary
+	for _, chunk := range vs.Chunks {
+		i, ok := index[chunk.DocumentID]
+		if !ok {
+			index[chunk.DocumentID] = len(summaries)
+			summaries = append(summaries, SourceSummary{
+				DocumentID: chunk.DocumentID,
+				Title:      chunk.Source,
+				URI:        chunk.URI,
+				Chunks:     1,
+				FetchedAt:  chunk.FetchedAt,
+			})
+			continue
+		}
+		summaries[i].Chunks++
+		if chunk.FetchedAt.Before(summaries[i].FetchedAt) {
+			summaries[i].FetchedAt = chunk.FetchedAt
+		}
+	}
+	return summaries, nil
+}
+
+// StaleSources lists the documents in store fetched more than maxAge ago, oldest first. Documents
+// without a fetch time count as stale. Stores that cannot list documents yield nil.
+func StaleSources(store Store, maxAge time.Duration) ([]SourceSummary, error) {
+	summarizer, ok := store.(sourceSummarizer)
+	if !ok {
+		return nil, nil
+	}
+	summaries, err := summarizer.SourceSummaries()
+	if err != nil {
+		return nil, err
+	}
+	cutoff := time.Now().Add(-maxAge)
+	var stale []SourceSummary
+	for _, summary := range summaries {
+		if summary.FetchedAt.Before(cutoff) {
+			stale = append(stale, summary)
+		}
+	}
+	sort.SliceStable(stale, func(i, j int) bool {
+		return stale[i].FetchedAt.Before(stale[j].FetchedAt)
+	})
+	return stale, nil
+}
+
+// StaleSources lists the indexed documents fetched more than maxAge ago, oldest first. Failures
+// are logged and yield nil.
+func (s *Service) StaleSources(maxAge time.Duration) []SourceSummary {
+	stale, err := StaleSources(s.currentStore(), maxAge)
+	if err != nil {
+		log.Printf("list stale sources: %v", err)
+		return nil
+	}
+	return stale
+}
@@ -0,0 +1,239 @@
+package rag
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+)
+
+const (
+	StoreJSON     = "json"
+	StorePgVector = "pgvector"
+)
+
+// Store is the retrieval backend used by Service. Implementations must be safe to use
+// from the query path; persistence semantics are backend-specific.
+type Store interface {
+	// Search returns the topK chunks that best match the supplied embedding.
+	Search(query []float32, topK int) []SearchResult
+	// SearchWithMin behaves like Search but skips chunks scoring below minScore, so it may
+	// return fewer than topK results. Ties rank the same as in Search.
+	SearchWithMin(query []float32, topK int, minScore float64) []SearchResult
+	// Add appends embedded chunks. It fails with ErrDimensionMismatch, adding nothing, when the
+	// chunks' embedding dimension differs from the stored vectors.
+	Add(chunks []Chunk) error
+	// Save persists the store; backends that write through on Add may ignore path.
+	Save(path string) error
+	// Load replaces the store contents from persistent storage.
+	Load(path string) error
+	// Meta reports ingestion metadata.
+	Meta() Metadata
+	// Len reports the number of stored chunks.
+	Len() int
+}
+
+var (
+	_ Store = (*VectorStore)(nil)
+	_ Store = (*PgVectorStore)(nil)
+)
+
+// Add appends embedded chunks and refreshes the metadata counts. Chunks must match the store's
+// embedding dimension (Metadata.EmbeddingDim, else the first stored chunk).
+func (vs *VectorStore) Add(chunks []Chunk) error {
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+	want := vs.Metadata.EmbeddingDim
+	if want == 0 && len(vs.Chunks) > 0 {
+		want = len(vs.Chunks[0].Embedding)
+	}
+	dim, err := checkChunkDimensions(chunks, want)
+	if err != nil {
+		return err
+	}
+	if vs.Metadata.EmbeddingDim == 0 {
+		vs.Metadata.EmbeddingDim = dim
+	}
+	vs.Chunks = append(vs.Chunks, chunks...)
+	vs.Metadata.ChunkCount = len(vs.Chunks)
+	vs.Metadata.SourceCount = countDocuments(vs.Chunks)
+	return nil
+}
+
+// AddUnique appends chunks like Add but skips any chunk whose cosine similarity to an existing
+// chunk, or to one accepted earlier in the same call, exceeds threshold. It returns the number of
+// skipped chunks. A threshold of zero or less disables the check.
+func (vs *VectorStore) AddUnique(chunks []Chunk, threshold float64) (int, error) {
+	kept, skipped, _ := vs.uniqueChunks(chunks, threshold, "")
+	if len(kept) == 0 {
+		return skipped, nil
+	}
+	return skipped, vs.Add(kept)
+}
+
+// duplicateFilter is implemented by stores that can drop new chunks duplicating stored ones.
+type duplicateFilter interface {
+	// uniqueChunks drops chunks whose cosine similarity to a stored chunk outside
+	// excludeDocument, or to a chunk kept earlier in the call, exceeds threshold. It returns the
+	// kept chunks and how many were dropped. A threshold of zero or less keeps every chunk.
+	uniqueChunks(chunks []Chunk, threshold float64, excludeDocument string) ([]Chunk, int, error)
+}
+
+var (
+	_ duplicateFilter = (*VectorStore)(nil)
+	_ duplicateFilter = (*PgVectorStore)(nil)
+)
+
+// dropNearDuplicates filters chunks about to replace documentID's through store's duplicate
+// filter. The document's own stored chunks are not compared, so re-adding it unchanged works.
+func dropNearDuplicates(store Store, chunks []Chunk, threshold float64, documentID string) ([]Chunk, int, error) {
+	filter, ok := store.(duplicateFilter)
+	if !ok || threshold <= 0 {
+		return chunks, 0, nil
+	}
+	return filter.uniqueChunks(chunks, threshold, documentID)
+}
+
+func (vs *VectorStore) uniqueChunks(chunks []Chunk, threshold float64, excludeDocument string) ([]Chunk, int, error) {
+	if threshold <= 0 {
+		return chunks, 0, nil
+	}
+	vs.mu.RLock()
+	defer vs.mu.RUnlock()
+	kept := make([]Chunk, 0, len(chunks))
+	skipped := 0
+	for _, chunk := range chunks {
+		if vs.hasNearDuplicate(chunk.Embedding, threshold, excludeDocument) || isNearDuplicate(chunk.Embedding, kept, threshold) {
+			skipped++
+			continue
+		}
+		kept = append(kept, chunk)
+	}
+	return kept, skipped, nil
+}
+
+// hasNearDuplicate reports whether a stored chunk outside excludeDocument is more similar to
+// embedding than threshold. The caller holds vs.mu.
+func (vs *VectorStore) hasNearDuplicate(embedding []float32, threshold float64, excludeDocument string) bool {
+	for _, other := range vs.Chunks {
+		if excludeDocument != "" && other.DocumentID == excludeDocument {
+			continue
+		}
+		if cosineSimilarity(embedding, other.Embedding) > threshold {
+			return true
+		}
+	}
+	return false
+}
+
+func isNearDuplicate(embedding []float32, chunks []Chunk, threshold float64) bool {
+	for _, other := range chunks {
+		if cosineSimilarity(embedding, other.Embedding) > threshold {
+			return true
+		}
+	}
+	return false
+}
+
+// Load replaces the store contents with the index at path.
+func (vs *VectorStore) Load(path string) error {
+	loaded, err := LoadVectorStore(path)
+	if err != nil {
+		return err
+	}
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+	vs.Metadata = loaded.Metadata
+	vs.Chunks = loaded.Chunks
+	vs.Documents = loaded.Documents
+	vs.norms = nil
+	return nil
+}
+
+// Meta reports the ingestion metadata.
+func (vs *VectorStore) Meta() Metadata {
+	vs.mu.RLock()
+	defer vs.mu.RUnlock()
+	return vs.Metadata
+}
+
+// Len reports the number of stored chunks.
+func (vs *VectorStore) Len() int {
+	vs.mu.RLock()
+	defer vs.mu.RUnlock()
+	return len(vs.Chunks)
+}
+
+// embeddingDim reports the dimension of the first stored chunk, for indexes that predate
+// Metadata.EmbeddingDim.
+func (vs *VectorStore) embeddingDim() int {
+	vs.mu.RLock()
+	defer vs.mu.RUnlock()
+	if len(vs.Chunks) == 0 {
+		return 0
+	}
+	return len(vs.Chunks[0].Embedding)
+}
+
+// checkChunkDimensions verifies every chunk has an embedding of the same length, equal to want
+// when want is positive. It returns the chunks' dimension.
+func checkChunkDimensions(chunks []Chunk, want int) (int, error) {
+	for _, chunk := range chunks {
+		got := len(chunk.Embedding)
+		if got == 0 {
+			return 0, fmt.Errorf("chunk %s has no embedding", chunk.ID)
+		}
+		if want == 0 {
+			want = got
+		}
+		if got != want {
+			return 0, fmt.Errorf("%w: chunk %s has %d dimensions, store has %d; re-ingest after changing the embedding model",
+				ErrDimensionMismatch, chunk.ID, got, want)
+		}
+	}
+	return want, nil
+}
+
+func countDocuments(chunks []Chunk) int {
+	seen := map[string]struct{}{}
+	for _, chunk := range chunks {
+		seen[chunk.DocumentID] = struct{}{}
+	}
+	return len(seen)
+}
+
+// OpenOrCreateStore is OpenStore, except that a missing JSON index opens as an empty store, so the
+// server can start, and sources can be appended, before anything has been ingested.
+func OpenOrCreateStore(cfg ServiceConfig) (Store, error) {
+	if cfg.Store != StoreJSON && cfg.Store != "" {
+		return OpenStore(cfg)
+	}
+	if _, err := os.Stat(cfg.IndexPath); errors.Is(err, os.ErrNotExist) {
+		log.Printf("RAG index %s does not exist yet; starting with an empty store", cfg.IndexPath)
+	}
+	store, err := LoadOrCreateVectorStore(cfg.IndexPath)
+	if err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// OpenStore opens the backend selected by cfg.Store. A missing JSON index is an error.
+func OpenStore(cfg ServiceConfig) (Store, error) {
+	switch cfg.Store {
+	case StoreJSON, "":
+		store, err := LoadVectorStore(cfg.IndexPath)
+		if err != nil {
+			return nil, err
+		}
+		return store, nil
+	case StorePgVector:
+		store, err := OpenPgVectorStore(cfg.DatabaseURL)
+		if err != nil {
+			return nil, err
+		}
+		return store, nil
+	default:
+		return nil, fmt.Errorf("unsupported store %s", cfg.Store)
+	}
+}
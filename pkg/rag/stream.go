@@ -0,0 +1,170 @@
+package rag
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// StreamingChatClient is a ChatClient that can deliver its answer while it is generated.
+type StreamingChatClient interface {
+	ChatClient
+	// CompleteStream calls onDelta with each piece of the answer as it arrives and returns the
+	// whole answer. An error from onDelta aborts the completion and is returned.
+	CompleteStream(ctx context.Context, systemPrompt, prompt string, temperature float32, onDelta func(string) error) (string, error)
+}
+
+// completeStream streams from client when it supports streaming; otherwise it completes normally
+// and delivers the answer as a single delta.
+func completeStream(ctx context.Context, client ChatClient, systemPrompt, prompt string, temperature float32, onDelta func(string) error) (string, error) {
+	if streaming, ok := client.(StreamingChatClient); ok {
+		return streaming.CompleteStream(ctx, systemPrompt, prompt, temperature, onDelta)
+	}
+	answer, err := client.Complete(ctx, systemPrompt, prompt, temperature)
+	if err != nil {
+		return "", err
+	}
+	if err := onDelta(answer); err != nil {
+		return "", err
+	}
+	return answer, nil
+}
+
+// CompleteStream streams the completion through the chat completions streaming API.
+func (c *OpenAIChatClient) CompleteStream(ctx context.Context, systemPrompt, prompt string, temperature float32, onDelta func(string) error) (string, error) {
+	req := openai.ChatCompletionRequest{
+		Model: c.model,
+		Messages: []openai.ChatCompletionMessage{
+			{Role: openai.ChatMessageRoleSystem, Content: systemPrompt},
+			{Role: openai.ChatMessageRoleUser, Content: prompt},
+		},
+		Temperature: openAITemperature(temperature),
+		MaxTokens:   maxTokensFor(ctx, c.maxTokens),
+		Stream:      true,
+	}
+	if c.streamUsage {
+		req.StreamOptions = &openai.StreamOptions{IncludeUsage: true}
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	stream, err := c.client.CreateChatCompletionStream(ctx, req)
+	if err != nil {
+		return "", err
+	}
+	defer stream.Close()
+
+	var answer strings.Builder
+	for {
+		resp, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return answer.String(), nil
+		}
+		if err != nil {
+			return "", err
+		}
+		if resp.Usage != nil {
+			recordChatUsage(ctx, c.model, resp.Usage.PromptTokens, resp.Usage.CompletionTokens)
+		}
+		if len(resp.Choices) == 0 || resp.Choices[0].Delta.Content == "" {
+			continue
+		}
+		delta := resp.Choices[0].Delta.Content
+		answer.WriteString(delta)
+		if err := onDelta(delta); err != nil {
+			return "", err
+		}
+	}
+}
+
+// CompleteStream streams the completion from Ollama's newline-delimited chat responses.
+func (c *OllamaChatClient) CompleteStream(ctx context.Context, systemPrompt, prompt string, temperature float32, onDelta func(string) error) (string, error) {
+	resp, err := c.post(ctx, systemPrompt, prompt, temperature, true)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var answer strings.Builder
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64<<10), 1<<20)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var part struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+			Done  bool   `json:"done"`
+			Error string `json:"error"`
+			ollamaUsage
+		}
+		if err := json.Unmarshal(line, &part); err != nil {
+			return "", fmt.Errorf("ollama chat stream: %w", err)
+		}
+		if part.Error != "" {
+			return "", fmt.Errorf("ollama chat stream: %s", part.Error)
+		}
+		if delta := part.Message.Content; delta != "" {
+			answer.WriteString(delta)
+			if err := onDelta(delta); err != nil {
+				return "", err
+			}
+		}
+		if part.Done {
+			recordChatUsage(ctx, c.model, part.PromptEvalCount, part.EvalCount)
+			return answer.String(), nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", errors.New("ollama chat stream ended before the answer was done")
+}
+
+// CompleteStream streams from the wrapped client, retrying only failures that happen before the
+// first delta so callers never receive part of an answer twice.
+func (r retryChatClient) CompleteStream(ctx context.Context, systemPrompt, prompt string, temperature float32, onDelta func(string) error) (string, error) {
+	var answer string
+	emitted := false
+	err := retryCall(ctx, r.policy, func(ctx context.Context) error {
+		var err error
+		answer, err = completeStream(ctx, r.ChatClient, systemPrompt, prompt, temperature, func(delta string) error {
+			emitted = true
+			return onDelta(delta)
+		})
+		if err != nil && emitted {
+			return permanent(err)
+		}
+		return err
+	})
+	return answer, err
+}
+
+// CompleteStream streams from the primary, falling back to the secondary when the primary fails
+// before producing any output.
+func (f *FallbackChatClient) CompleteStream(ctx context.Context, systemPrompt, prompt string, temperature float32, onDelta func(string) error) (string, error) {
+	emitted := false
+	answer, err := completeStream(ctx, f.Primary, systemPrompt, prompt, temperature, func(delta string) error {
+		emitted = true
+		return onDelta(delta)
+	})
+	if err == nil || emitted || ctx.Err() != nil {
+		return answer, err
+	}
+	log.Printf("rag: primary chat client failed, trying fallback: %v", err)
+	answer, fallbackErr := completeStream(ctx, f.Secondary, systemPrompt, prompt, temperature, onDelta)
+	if fallbackErr != nil {
+		return "", fmt.Errorf("primary chat client: %w; fallback chat client: %w", err, fallbackErr)
+	}
+	return answer, nil
+}
@@ -0,0 +1,36 @@
+package rag
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// maxSummaryInputRunes caps how much of a document is sent for summarization.
+const maxSummaryInputRunes = 12000
+
+const summarySystemPrompt = "You summarize technical documentation. Reply with a single plain-text paragraph of at most five sentences."
+
+// SummarizeDocuments asks chat for a one-paragraph summary of each document and stores it on
+// Document.Summary. Failures are returned as notes and leave that document unsummarized.
+func SummarizeDocuments(ctx context.Context, chat ChatClient, docs []Document) []string {
+	var notes []string
+	for i := range docs {
+		if err := ctx.Err(); err != nil {
+			notes = append(notes, fmt.Sprintf("summarization stopped: %v", err))
+			break
+		}
+		content := docs[i].Content
+		if runes := []rune(content); len(runes) > maxSummaryInputRunes {
+			content = string(runes[:maxSummaryInputRunes])
+		}
+		prompt := fmt.Sprintf("Summarize what this document covers so a reader can tell whether it answers their question.\n\nTitle: %s\n\n%s", docs[i].Title, content)
+		summary, err := chat.Complete(ctx, summarySystemPrompt, prompt, DefaultTemperature)
+		if err != nil {
+			notes = append(notes, fmt.Sprintf("summarize %s: %v", docs[i].Title, err))
+			continue
+		}
+		docs[i].Summary = strings.Join(strings.Fields(summary), " ")
+	}
+	return notes
+}
@@ -1,6 +1,10 @@
 package rag
 
-import "time"
+import (
+	"fmt"
+	"math"
+	"time"
+)
 
 // Document represents a normalized text artifact that will be chunked into embeddings.
 type Document struct {
@@ -9,6 +13,17 @@ type Document struct {
 	URI     string `json:"uri"`
 	Source  string `json:"source"`
 	Content string `json:"content"`
+	// Language is the detected ISO 639-1 code, empty when detection was skipped or unreliable.
+	Language string `json:"language,omitempty"`
+	// Summary is a one-paragraph overview generated at ingestion when SourceOptions.Summarize is set.
+	Summary string `json:"summary,omitempty"`
+	// Lines maps each line of Content to its 1-based line number in the original file.
+	// It is only set for local files.
+	Lines []int `json:"-"`
+	// fromHTML marks documents converted from HTML, the candidates for boilerplate removal.
+	fromHTML bool
+	// FetchedAt is when the document was read or downloaded during collection.
+	FetchedAt time.Time `json:"fetchedAt"`
 }
 
 // Chunk represents a slice of a document used for retrieval.
@@ -20,6 +35,33 @@ type Chunk struct {
 	Text       string    `json:"text"`
 	Index      int       `json:"index"`
 	Embedding  []float32 `json:"embedding"`
+	// StartOffset and EndOffset are rune offsets of Text within the document content, end exclusive.
+	StartOffset int `json:"startOffset"`
+	EndOffset   int `json:"endOffset"`
+	// Summary is the parent document's summary; it is prepended to Text when embedding.
+	Summary string `json:"summary,omitempty"`
+	// StartLine and EndLine are the 1-based, inclusive lines of the chunk in its local source file.
+	// They are zero for remote documents.
+	StartLine int `json:"startLine,omitempty"`
+	EndLine   int `json:"endLine,omitempty"`
+	// FetchedAt is when the parent document was fetched; zero for chunks ingested before it was recorded.
+	FetchedAt time.Time `json:"fetchedAt"`
+}
+
+// Location renders the chunk's file position as "path:start-end", or "" when lines are unknown.
+func (c Chunk) Location() string {
+	if c.StartLine == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%s:%d-%d", c.URI, c.StartLine, c.EndLine)
+}
+
+// embeddingText is the text embedded for the chunk: Text, preceded by the document summary when present.
+func (c Chunk) embeddingText() string {
+	if c.Summary == "" {
+		return c.Text
+	}
+	return c.Summary + "\n\n" + c.Text
 }
 
 // Metadata tracks ingestion run details.
@@ -28,24 +70,231 @@ type Metadata struct {
 	SourceCount int       `json:"sourceCount"`
 	ChunkCount  int       `json:"chunkCount"`
 	Notes       []string  `json:"notes"`
+	// EmbeddingDim is the vector length produced by the ingest-time embedder.
+	EmbeddingDim int `json:"embeddingDim,omitempty"`
+	// EmbeddingCanary is the ingest-time embedding of EmbeddingCanaryText, used to detect model changes.
+	EmbeddingCanary []float32 `json:"embeddingCanary,omitempty"`
+}
+
+// ContextOrder arranges the retrieved sections in the prompt. Models attend most to the start
+// and end of their context, so putting the best sections there can improve answers.
+type ContextOrder string
+
+const (
+	// ContextOrderMostFirst lists sections from most to least relevant; it is the default.
+	ContextOrderMostFirst ContextOrder = "most_first"
+	// ContextOrderLeastFirst lists sections from least to most relevant, ending on the best.
+	ContextOrderLeastFirst ContextOrder = "least_first"
+	// ContextOrderLostInMiddle alternates sections between both ends, leaving the weakest in the middle.
+	ContextOrderLostInMiddle ContextOrder = "lost_in_middle"
+)
+
+// ParseContextOrder validates an ordering name; an empty name is ContextOrderMostFirst.
+func ParseContextOrder(name string) (ContextOrder, error) {
+	switch order := ContextOrder(name); order {
+	case "":
+		return ContextOrderMostFirst, nil
+	case ContextOrderMostFirst, ContextOrderLeastFirst, ContextOrderLostInMiddle:
+		return order, nil
+	default:
+		return "", fmt.Errorf("unknown context order %q, expected most_first, least_first, or lost_in_middle", name)
+	}
+}
+
+// Verbosity sets how long and thorough an answer should be.
+type Verbosity string
+
+const (
+	// VerbosityBrief asks for a one or two sentence answer and caps completion tokens.
+	VerbosityBrief Verbosity = "brief"
+	// VerbosityNormal leaves the prompt and token limit unchanged; it is the default.
+	VerbosityNormal Verbosity = "normal"
+	// VerbosityDetailed asks for step-by-step SP-API implementation notes and doubles the token limit.
+	VerbosityDetailed Verbosity = "detailed"
+)
+
+// ParseVerbosity validates a verbosity name; an empty name is VerbosityNormal.
+func ParseVerbosity(name string) (Verbosity, error) {
+	switch verbosity := Verbosity(name); verbosity {
+	case "":
+		return VerbosityNormal, nil
+	case VerbosityBrief, VerbosityNormal, VerbosityDetailed:
+		return verbosity, nil
+	default:
+		return "", fmt.Errorf("unknown verbosity %q, expected brief, normal, or detailed", name)
+	}
 }
 
 // QueryOptions configure retrieval and generation.
 type QueryOptions struct {
-	TopK        int
-	Temperature float32
+	TopK int
+	// Temperature sets the sampling temperature, clamped to [0, MaxTemperature]; nil uses
+	// DefaultTemperature. An explicit 0 asks for the most deterministic answer.
+	Temperature *float32
+	// PromptTemplate overrides the service template for a single query when set.
+	PromptTemplate string
+	// SystemPrompt overrides the service system prompt for a single query when set.
+	SystemPrompt string
+	// ScoreThreshold overrides the service threshold when positive.
+	ScoreThreshold float64
+	// SourcePriority multiplies scores of chunks whose DocumentID or Source matches a key.
+	// Unlisted sources keep weight 1.0.
+	SourcePriority map[string]float64
+	// Sources, when set, restricts retrieval to chunks whose DocumentID or Source is listed.
+	Sources []string
+	// MaxPerSource, when positive, caps the chunks any one document contributes, filling TopK with
+	// the next-best chunks from other documents.
+	MaxPerSource int
+	// IncludeFullText fills SourceAttribution.FullText with the untruncated chunk text.
+	IncludeFullText bool
+	// HighlightTerms wraps the question's significant terms in each SourceAttribution.Snippet
+	// with HighlightMarker, matching whole words case-insensitively and skipping stop words.
+	HighlightTerms bool
+	// HighlightMarker surrounds highlighted terms; empty uses DefaultHighlightMarker.
+	HighlightMarker string
+	// DedupeSources collapses attributions from the same document, keeping the best-scoring chunk.
+	// Retrieval and the prompt still use every chunk.
+	DedupeSources bool
+	// QueryVariants are extra phrasings of the question; each is searched and the rankings are
+	// fused with reciprocal rank fusion before generation.
+	QueryVariants []string
+	// AutoVariants asks the chat client for this many additional phrasings, capped at MaxAutoVariants.
+	AutoVariants int
+	// FallbackToSources returns the retrieved sources with Answer.Error set, instead of failing,
+	// when the chat client errors.
+	FallbackToSources bool
+	// ContextOrder arranges the sections in the prompt; empty uses ContextOrderMostFirst.
+	// Section numbers and Answer.Sources keep relevance order either way.
+	ContextOrder ContextOrder
+	// ReturnPrompt copies the rendered user prompt into Answer.DebugPrompt. Generation is unchanged.
+	ReturnPrompt bool
+	// History holds the earlier turns of a conversation, oldest first. They are quoted ahead of
+	// the prompt so follow-up questions can refer back; retrieval uses only the current question.
+	History []ChatTurn
+	// Verbosity adjusts the answer-length instruction and completion token limit; empty is
+	// VerbosityNormal.
+	Verbosity Verbosity
+	// ContextWindow adds up to this many neighbouring chunks of the same document on each side
+	// of every match to its prompt section, capped at MaxContextWindow. Retrieval, scores, and
+	// Answer.Sources are unchanged.
+	ContextWindow int
+}
+
+// temperature resolves Temperature to the value sent to the chat client.
+func (o QueryOptions) temperature() float32 {
+	if o.Temperature == nil {
+		return DefaultTemperature
+	}
+	return min(max(*o.Temperature, 0), MaxTemperature)
+}
+
+// ChatTurn is one earlier question and its answer in a conversation.
+type ChatTurn struct {
+	Question string `json:"question"`
+	Answer   string `json:"answer"`
 }
 
 // Answer bundles the LLM output and retrieved snippets.
 type Answer struct {
-	Answer  string              `json:"answer"`
-	Sources []SourceAttribution `json:"sources"`
+	Answer string `json:"answer"`
+	// Answered is false when the model declined or no context cleared the score threshold.
+	Answered bool                `json:"answered"`
+	Sources  []SourceAttribution `json:"sources"`
+	// Error describes why this item failed in a batch, in which case the other fields are empty,
+	// or why generation failed when QueryOptions.FallbackToSources returned sources only.
+	Error string `json:"error,omitempty"`
+	// ScoreStats summarizes the scores of the matches sent to the model; nil when there were none.
+	ScoreStats *ScoreStats `json:"scoreStats,omitempty"`
+	// DebugPrompt is the prompt sent to the chat client, set only when QueryOptions.ReturnPrompt is.
+	DebugPrompt string `json:"debugPrompt,omitempty"`
+	// FlaggedSections counts the context sections in which SanitizeContext neutralized a possible
+	// prompt injection.
+	FlaggedSections int `json:"flaggedSections,omitempty"`
+	// Usage reports the tokens the providers counted for this answer and their estimated cost;
+	// nil when no provider reported usage.
+	Usage *Usage `json:"usage,omitempty"`
+}
+
+// ScoreStats describes the score distribution of a query's matches, to help calibrate
+// ScoreThreshold. A large Gap between the two best matches signals a confident retrieval.
+type ScoreStats struct {
+	Min  float64 `json:"min"`
+	Max  float64 `json:"max"`
+	Mean float64 `json:"mean"`
+	// Gap is the best score minus the second best; zero for a single match.
+	Gap float64 `json:"gap"`
+}
+
+// scoreStats summarizes match scores, returning nil for no matches.
+func scoreStats(matches []SearchResult) *ScoreStats {
+	if len(matches) == 0 {
+		return nil
+	}
+	stats := &ScoreStats{Min: matches[0].Score, Max: matches[0].Score}
+	second := math.Inf(-1)
+	sum := 0.0
+	for i, match := range matches {
+		sum += match.Score
+		if i == 0 {
+			continue
+		}
+		stats.Min = math.Min(stats.Min, match.Score)
+		if match.Score > stats.Max {
+			second, stats.Max = stats.Max, match.Score
+		} else if match.Score > second {
+			second = match.Score
+		}
+	}
+	stats.Mean = sum / float64(len(matches))
+	if len(matches) > 1 {
+		stats.Gap = stats.Max - second
+	}
+	return stats
 }
 
 // SourceAttribution highlights which slices backed the answer.
 type SourceAttribution struct {
-	Title   string  `json:"title"`
-	URI     string  `json:"uri"`
-	Snippet string  `json:"snippet"`
-	Score   float64 `json:"score"`
+	DocumentID string  `json:"documentId"`
+	Title      string  `json:"title"`
+	URI        string  `json:"uri"`
+	Snippet    string  `json:"snippet"`
+	Score      float64 `json:"score"`
+	// FullText is the complete chunk text, set only when QueryOptions.IncludeFullText is true.
+	FullText string `json:"fullText,omitempty"`
+	// StartOffset and EndOffset locate the chunk within the document content in runes.
+	StartOffset int `json:"startOffset"`
+	EndOffset   int `json:"endOffset"`
+	// KeywordMatch notes that the source was found by the keyword fallback.
+	KeywordMatch bool `json:"keywordMatch,omitempty"`
+	// Location is "file.md:120-145" for chunks of local files, pointing at the lines they came from.
+	Location string `json:"location,omitempty"`
+}
+
+// RetrievedChunk is a ranked chunk returned by retrieval-only endpoints.
+type RetrievedChunk struct {
+	ID         string  `json:"id"`
+	DocumentID string  `json:"documentId"`
+	Title      string  `json:"title"`
+	URI        string  `json:"uri"`
+	Text       string  `json:"text"`
+	Score      float64 `json:"score"`
+	// KeywordMatch notes that the chunk was found by the keyword fallback.
+	KeywordMatch bool `json:"keywordMatch,omitempty"`
+}
+
+// RetrievedChunks converts search results into their JSON representation.
+func RetrievedChunks(matches []SearchResult) []RetrievedChunk {
+	out := make([]RetrievedChunk, len(matches))
+	for i, match := range matches {
+		out[i] = RetrievedChunk{
+			ID:           match.Chunk.ID,
+			DocumentID:   match.Chunk.DocumentID,
+			Title:        match.Chunk.Source,
+			URI:          match.Chunk.URI,
+			Text:         match.Chunk.Text,
+			Score:        match.Score,
+			KeywordMatch: match.KeywordMatch,
+		}
+	}
+	return out
 }
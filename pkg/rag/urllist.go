@@ -0,0 +1,76 @@
+package rag
+
+import (
+	"bufio"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// LoadURLList reads remote sources from a text file with one URL per line, optionally prefixed
+// by a format as "format|url". Blank lines and lines starting with # are skipped. Without a
+// format, it is inferred from the URL's extension, defaulting to HTML. Each source is named after
+// its URL, so its document ID stays stable across runs.
+func LoadURLList(listPath string) ([]RemoteSource, error) {
+	file, err := os.Open(listPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var sources []RemoteSource
+	seen := map[string]struct{}{}
+	description := "URL list: " + filepath.Base(listPath)
+	scanner := bufio.NewScanner(file)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var format RemoteFormat
+		rawURL := line
+		if prefix, rest, ok := strings.Cut(line, "|"); ok {
+			format, rawURL = RemoteFormat(strings.ToLower(strings.TrimSpace(prefix))), strings.TrimSpace(rest)
+		}
+		parsed, err := url.Parse(rawURL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return nil, fmt.Errorf("%s:%d: %q is not an http(s) URL", listPath, lineNo, rawURL)
+		}
+		if format == "" {
+			format = formatForPath(parsed.Path)
+		}
+		switch format {
+		case FormatMarkdown, FormatHTML, FormatText, FormatTSV, FormatDOCX:
+		default:
+			return nil, fmt.Errorf("%s:%d: unknown format %q, expected markdown, html, text, tsv, or docx", listPath, lineNo, format)
+		}
+		if _, dup := seen[rawURL]; dup {
+			continue
+		}
+		seen[rawURL] = struct{}{}
+		sources = append(sources, RemoteSource{Name: rawURL, URL: rawURL, Format: format, Description: description})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read %s: %w", listPath, err)
+	}
+	return sources, nil
+}
+
+// formatForPath infers a remote format from a URL path's extension.
+func formatForPath(urlPath string) RemoteFormat {
+	switch strings.ToLower(path.Ext(urlPath)) {
+	case ".md", ".markdown":
+		return FormatMarkdown
+	case ".txt":
+		return FormatText
+	case ".tsv":
+		return FormatTSV
+	case ".docx":
+		return FormatDOCX
+	default:
+		return FormatHTML
+	}
+}
@@ -0,0 +1,134 @@
+package rag
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Usage counts the provider tokens spent answering one question, including query embedding,
+// reranking, and generated query variants, and estimates what they cost.
+type Usage struct {
+	PromptTokens     int `json:"promptTokens"`
+	CompletionTokens int `json:"completionTokens"`
+	EmbeddingTokens  int `json:"embeddingTokens"`
+	TotalTokens      int `json:"totalTokens"`
+	// CostUSD is estimated from ServiceConfig.ModelPrices. Models without a price, such as local
+	// Ollama models, add nothing.
+	CostUSD float64 `json:"costUsd"`
+}
+
+// ModelPrice is what a model costs in USD per million tokens. Embedding models only use Input.
+type ModelPrice struct {
+	Input  float64 `json:"input"`
+	Output float64 `json:"output"`
+}
+
+// DefaultModelPrices lists OpenAI list prices for the default models and their common
+// alternatives. Override or extend them with RAG_MODEL_PRICES.
+var DefaultModelPrices = map[string]ModelPrice{
+	"gpt-4o-mini":            {Input: 0.15, Output: 0.60},
+	"gpt-4o":                 {Input: 2.50, Output: 10.00},
+	"gpt-4.1-mini":           {Input: 0.40, Output: 1.60},
+	"gpt-4.1":                {Input: 2.00, Output: 8.00},
+	"text-embedding-3-small": {Input: 0.02},
+	"text-embedding-3-large": {Input: 0.13},
+	"text-embedding-ada-002": {Input: 0.10},
+}
+
+// ollamaUsage holds the token counts Ollama adds to a finished chat response.
+type ollamaUsage struct {
+	PromptEvalCount int `json:"prompt_eval_count"`
+	EvalCount       int `json:"eval_count"`
+}
+
+type usageKey struct{}
+
+// usageMeter accumulates the usage reported by provider clients for one question.
+type usageMeter struct {
+	mu     sync.Mutex
+	usage  Usage
+	used   bool
+	prices map[string]ModelPrice
+}
+
+// withUsageMeter returns a context whose provider calls are counted by the returned meter.
+func withUsageMeter(ctx context.Context, prices map[string]ModelPrice) (context.Context, *usageMeter) {
+	meter := &usageMeter{prices: prices}
+	return context.WithValue(ctx, usageKey{}, meter), meter
+}
+
+// recordChatUsage adds a chat completion's token counts to the context's meter, if any.
+func recordChatUsage(ctx context.Context, model string, promptTokens, completionTokens int) {
+	if meter, ok := ctx.Value(usageKey{}).(*usageMeter); ok {
+		meter.add(model, promptTokens, completionTokens, 0)
+	}
+}
+
+// recordEmbeddingUsage adds an embedding call's token count to the context's meter, if any.
+func recordEmbeddingUsage(ctx context.Context, model string, tokens int) {
+	if meter, ok := ctx.Value(usageKey{}).(*usageMeter); ok {
+		meter.add(model, 0, 0, tokens)
+	}
+}
+
+func (m *usageMeter) add(model string, promptTokens, completionTokens, embeddingTokens int) {
+	if promptTokens+completionTokens+embeddingTokens == 0 {
+		return
+	}
+	price := m.prices[model]
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.used = true
+	m.usage.PromptTokens += promptTokens
+	m.usage.CompletionTokens += completionTokens
+	m.usage.EmbeddingTokens += embeddingTokens
+	m.usage.TotalTokens += promptTokens + completionTokens + embeddingTokens
+	m.usage.CostUSD += (float64(promptTokens+embeddingTokens)*price.Input + float64(completionTokens)*price.Output) / 1e6
+}
+
+// snapshot returns the usage so far, or nil when no provider reported any.
+func (m *usageMeter) snapshot() *Usage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.used {
+		return nil
+	}
+	usage := m.usage
+	return &usage
+}
+
+// parseModelPrices reads "model=input/output" entries in USD per million tokens, e.g.
+// "gpt-4o-mini=0.15/0.60" or "text-embedding-3-small=0.02", since embedding models need no output
+// price. Entries are added to a copy of base, replacing its prices.
+func parseModelPrices(entries []string, base map[string]ModelPrice) (map[string]ModelPrice, error) {
+	prices := make(map[string]ModelPrice, len(base))
+	for model, price := range base {
+		prices[model] = price
+	}
+	for _, entry := range entries {
+		model, rates, ok := strings.Cut(entry, "=")
+		model = strings.TrimSpace(model)
+		if !ok || model == "" {
+			return nil, fmt.Errorf("model price %q: want model=input/output", entry)
+		}
+		input, output, _ := strings.Cut(rates, "/")
+		var price ModelPrice
+		var err error
+		if price.Input, err = strconv.ParseFloat(strings.TrimSpace(input), 64); err != nil {
+			return nil, fmt.Errorf("model price %q: %w", entry, err)
+		}
+		if output = strings.TrimSpace(output); output != "" {
+			if price.Output, err = strconv.ParseFloat(output, 64); err != nil {
+				return nil, fmt.Errorf("model price %q: %w", entry, err)
+			}
+		}
+		if price.Input < 0 || price.Output < 0 {
+			return nil, fmt.Errorf("model price %q: prices must not be negative", entry)
+		}
+		prices[model] = price
+	}
+	return prices, nil
+}
@@ -1,34 +1,91 @@
 package rag
 
 import (
+	"container/heap"
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"math"
 	"os"
 	"path/filepath"
 	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
-// VectorStore persists embedded chunks on disk for later querying.
+// VectorStore persists embedded chunks on disk for later querying. Its methods are safe for
+// concurrent use; code touching Chunks or Metadata directly must not race with them. Search caches
+// each chunk's embedding magnitude and recomputes the cache when the number of chunks changes, so
+// code that rewrites embeddings in place must go through the store's methods.
 type VectorStore struct {
+	mu       sync.RWMutex
 	Metadata Metadata `json:"metadata"`
 	Chunks   []Chunk  `json:"chunks"`
+
+	// Documents holds the original documents when ingestion ran with
+	// SourceOptions.StoreFullDocuments, so they can be re-chunked or shown without re-fetching.
+	// It is empty otherwise; pgvector stores never keep documents.
+	Documents []Document `json:"documents,omitempty"`
+
+	// dimWarnedAt is when warnDimensionMismatch last logged, in Unix nanoseconds.
+	dimWarnedAt atomic.Int64
+	// norms holds the embedding magnitude of Chunks[i] at index i. It may be shorter than Chunks
+	// after an append, or nil after chunks are replaced; see rlockNorms.
+	norms []float64
+}
+
+// dimensionWarnInterval rate-limits the dimension mismatch warning logged after searches.
+const dimensionWarnInterval = 10 * time.Minute
+
+// BuildOptions tune how BuildVectorStore embeds chunks.
+type BuildOptions struct {
+	// BatchSize is the number of chunks per embedding request; zero uses DefaultEmbedBatchSize.
+	BatchSize int
+	// DocumentPrefix is prepended to each chunk's text before embedding; see
+	// ServiceConfig.EmbedDocumentPrefix.
+	DocumentPrefix string
+	// Progress, when set, is called after each batch with the chunks embedded so far.
+	Progress func(done, total int)
 }
 
 // BuildVectorStore embeds all chunks and returns a ready-to-save store.
-func BuildVectorStore(ctx context.Context, chunks []Chunk, embedder Embedder, batchSize int, meta Metadata) (*VectorStore, error) {
+func BuildVectorStore(ctx context.Context, chunks []Chunk, embedder Embedder, opts BuildOptions, meta Metadata) (*VectorStore, error) {
 	if embedder == nil {
 		return nil, errors.New("embedder is required")
 	}
 	if len(chunks) == 0 {
 		return nil, errors.New("no chunks supplied")
 	}
+	if err := embedChunks(ctx, chunks, embedder, opts); err != nil {
+		return nil, err
+	}
+
+	meta.EmbeddingDim = len(chunks[0].Embedding)
+	canary, err := embedder.EmbedOne(ctx, EmbeddingCanaryText)
+	if err != nil {
+		return nil, fmt.Errorf("embed canary: %w", err)
+	}
+	meta.EmbeddingCanary = canary
+
+	store := &VectorStore{Metadata: meta, Chunks: chunks}
+	return store, nil
+}
+
+// embedChunks sets the Embedding of every chunk, in batches of opts.BatchSize.
+func embedChunks(ctx context.Context, chunks []Chunk, embedder Embedder, opts BuildOptions) error {
+	batchSize := opts.BatchSize
 	if batchSize <= 0 {
-		batchSize = 16
+		batchSize = DefaultEmbedBatchSize
 	}
 
 	for start := 0; start < len(chunks); start += batchSize {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
 		end := start + batchSize
 		if end > len(chunks) {
 			end = len(chunks)
@@ -36,31 +93,51 @@ func BuildVectorStore(ctx context.Context, chunks []Chunk, embedder Embedder, ba
 		batch := chunks[start:end]
 		texts := make([]string, len(batch))
 		for i, chunk := range batch {
-			texts[i] = chunk.Text
+			texts[i] = opts.DocumentPrefix + chunk.embeddingText()
 		}
 		embeddings, err := embedder.Embed(ctx, texts)
 		if err != nil {
-			return nil, err
+			return err
+		}
+		if len(embeddings) != len(batch) {
+			// Some providers silently drop inputs (e.g. empty strings); the remaining vectors
+			// can no longer be matched to their chunks by position.
+			return fmt.Errorf("embed chunks %d-%d: got %d embeddings for %d texts", start+1, end, len(embeddings), len(batch))
 		}
 		for i := range batch {
 			chunks[start+i].Embedding = embeddings[i]
 		}
+		if opts.Progress != nil {
+			opts.Progress(end, len(chunks))
+		}
 	}
-
-	store := &VectorStore{Metadata: meta, Chunks: chunks}
-	return store, nil
+	return nil
 }
 
-// Save writes the vector store to disk.
+// Save writes the vector store to disk atomically, replacing any existing index.
 func (vs *VectorStore) Save(path string) error {
+	return vs.SaveWithBackups(path, 0)
+}
+
+// SaveWithBackups writes the vector store to disk atomically. With keep positive, an existing
+// index is first kept as <name>.<timestamp>.bak beside it, and only the keep most recent
+// backups are retained.
+func (vs *VectorStore) SaveWithBackups(path string, keep int) error {
 	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
 		return err
 	}
+	vs.mu.RLock()
 	data, err := json.MarshalIndent(vs, "", "  ")
+	vs.mu.RUnlock()
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(path, data, 0o644)
+	if keep > 0 {
+		if err := backupIndex(path, keep); err != nil {
+			return err
+		}
+	}
+	return writeFileAtomic(path, data)
 }
 
 // LoadVectorStore reads a store from disk.
@@ -76,52 +153,259 @@ func LoadVectorStore(path string) (*VectorStore, error) {
 	return &store, nil
 }
 
+// LoadOrCreateVectorStore reads a store from disk, returning an empty store with zeroed metadata
+// when path does not exist yet. Nothing is written until the store is saved.
+func LoadOrCreateVectorStore(path string) (*VectorStore, error) {
+	store, err := LoadVectorStore(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &VectorStore{}, nil
+	}
+	return store, err
+}
+
 // SearchResult describes the best-matching chunks.
 type SearchResult struct {
 	Chunk Chunk
 	Score float64
+	// KeywordMatch is set when the result came from the keyword fallback rather than vector similarity.
+	KeywordMatch bool
 }
 
+// ErrDimensionMismatch reports vectors of differing lengths.
+var ErrDimensionMismatch = errors.New("embedding dimension mismatch")
+
 // Search returns the topK chunks that best match the supplied embedding.
 func (vs *VectorStore) Search(query []float32, topK int) []SearchResult {
+	return vs.SearchWithMin(query, topK, math.Inf(-1))
+}
+
+// SearchWithMin returns up to topK chunks scoring at least minScore, best first. Chunks below
+// minScore are skipped while selecting, so fewer than topK results, or an empty slice, mean no
+// other chunk cleared the floor. Equal scores rank in store order whatever the floor.
+func (vs *VectorStore) SearchWithMin(query []float32, topK int, minScore float64) []SearchResult {
 	if vs == nil || len(query) == 0 {
 		return nil
 	}
 	if topK <= 0 {
 		topK = 4
 	}
-	results := make([]SearchResult, 0, topK)
-	for _, chunk := range vs.Chunks {
-		score := cosineSimilarity(query, chunk.Embedding)
-		results = append(results, SearchResult{Chunk: chunk, Score: score})
+	queryNorm := math.Sqrt(squaredNorm(query))
+	vs.rlockNorms()
+	best := make(resultHeap, 0, min(topK, len(vs.Chunks)))
+	for i := range vs.Chunks {
+		chunk := &vs.Chunks[i]
+		score := normalizedScore(query, queryNorm, chunk.Embedding, vs.norms[i])
+		switch {
+		case score < minScore:
+		case len(best) < topK:
+			heap.Push(&best, rankedResult{SearchResult{Chunk: *chunk, Score: score}, i})
+		case score > best[0].Score:
+			// An equal score loses to the earlier chunk already held.
+			best[0] = rankedResult{SearchResult{Chunk: *chunk, Score: score}, i}
+			heap.Fix(&best, 0)
+		}
 	}
-	sortByScore(results)
+	vs.mu.RUnlock()
+
+	results := make([]SearchResult, len(best))
+	for i := len(results) - 1; i >= 0; i-- {
+		results[i] = heap.Pop(&best).(rankedResult).SearchResult
+	}
+	return results
+}
+
+// rlockNorms read-locks the store with a magnitude cached for every chunk, computing the missing
+// ones under the write lock first. The caller must RUnlock.
+func (vs *VectorStore) rlockNorms() {
+	vs.mu.RLock()
+	for len(vs.norms) != len(vs.Chunks) {
+		vs.mu.RUnlock()
+		vs.mu.Lock()
+		if len(vs.norms) > len(vs.Chunks) {
+			vs.norms = nil
+		}
+		for i := len(vs.norms); i < len(vs.Chunks); i++ {
+			vs.norms = append(vs.norms, math.Sqrt(squaredNorm(vs.Chunks[i].Embedding)))
+		}
+		vs.mu.Unlock()
+		vs.mu.RLock()
+	}
+}
+
+// normalizedScore is the cosine similarity of query and embedding given both magnitudes. Like
+// cosineSimilarity it returns 0 for mismatched dimensions or zero vectors.
+func normalizedScore(query []float32, queryNorm float64, embedding []float32, norm float64) float64 {
+	if len(embedding) != len(query) || queryNorm == 0 || norm == 0 {
+		return 0
+	}
+	return dot(query, embedding) / (queryNorm * norm)
+}
+
+// Document returns the stored original document with the given ID. It only finds documents in
+// stores built with SourceOptions.StoreFullDocuments.
+func (vs *VectorStore) Document(id string) (Document, bool) {
+	vs.mu.RLock()
+	defer vs.mu.RUnlock()
+	for _, doc := range vs.Documents {
+		if doc.ID == id {
+			return doc, true
+		}
+	}
+	return Document{}, false
+}
+
+// putDocument replaces or appends doc in a store that keeps original documents. Stores built
+// without them are left alone so adding one source does not start a partial collection.
+func (vs *VectorStore) putDocument(doc Document) {
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+	if len(vs.Documents) == 0 {
+		return
+	}
+	for i := range vs.Documents {
+		if vs.Documents[i].ID == doc.ID {
+			vs.Documents[i] = doc
+			return
+		}
+	}
+	vs.Documents = append(vs.Documents, doc)
+}
+
+// warnDimensionMismatch logs through ctx's request logger when chunks could not be scored
+// against a query of queryDim dimensions, at most once per dimensionWarnInterval. Such chunks
+// score zero in Search, SearchWeighted, and ScoreAll, which usually means the index mixes
+// embedding models or the query model changed since ingestion.
+func (vs *VectorStore) warnDimensionMismatch(ctx context.Context, queryDim int) {
+	now := time.Now().UnixNano()
+	last := vs.dimWarnedAt.Load()
+	if last != 0 && now-last < int64(dimensionWarnInterval) {
+		return
+	}
+	count, chunkDim := 0, 0
+	vs.mu.RLock()
+	for i := range vs.Chunks {
+		if dim := len(vs.Chunks[i].Embedding); dim != queryDim && dim > 0 {
+			count++
+			chunkDim = dim
+		}
+	}
+	vs.mu.RUnlock()
+	if count == 0 || !vs.dimWarnedAt.CompareAndSwap(last, now) {
+		return
+	}
+	logf(ctx, "WARNING: RAG search scored %d chunk(s) with %d-dimensional embeddings as 0 against a %d-dimensional query; re-ingest with a single embedding model",
+		count, chunkDim, queryDim)
+}
+
+// rankedResult is a SearchResult with its chunk's position in the store, which breaks score ties.
+type rankedResult struct {
+	SearchResult
+	position int
+}
+
+// resultHeap is a min-heap on Score, holding the best results seen so far with the weakest on top.
+// Of two equal scores the later chunk is the weaker, so ties pop in store order.
+type resultHeap []rankedResult
+
+func (h resultHeap) Len() int { return len(h) }
+func (h resultHeap) Less(i, j int) bool {
+	if h[i].Score != h[j].Score {
+		return h[i].Score < h[j].Score
+	}
+	return h[i].position > h[j].position
+}
+func (h resultHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *resultHeap) Push(x any) { *h = append(*h, x.(rankedResult)) }
+
+func (h *resultHeap) Pop() any {
+	old := *h
+	last := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return last
+}
+
+// SearchWeighted behaves like SearchWithMin but multiplies each score that clears minScore by the
+// weight for the chunk's DocumentID or Source before selecting the topK.
+func (vs *VectorStore) SearchWeighted(query []float32, topK int, weights map[string]float64, minScore float64) []SearchResult {
+	if topK <= 0 {
+		topK = 4
+	}
+	results := applySourcePriority(vs.ScoreAll(query, minScore), weights)
 	if len(results) > topK {
 		results = results[:topK]
 	}
 	return results
 }
 
+// applySourcePriority reweights results in place and re-sorts them.
+func applySourcePriority(results []SearchResult, weights map[string]float64) []SearchResult {
+	if len(weights) == 0 {
+		return results
+	}
+	for i := range results {
+		results[i].Score *= sourceWeight(results[i].Chunk, weights)
+	}
+	sortByScore(results)
+	return results
+}
+
+func sourceWeight(chunk Chunk, weights map[string]float64) float64 {
+	if w, ok := weights[chunk.DocumentID]; ok {
+		return w
+	}
+	if w, ok := weights[chunk.Source]; ok {
+		return w
+	}
+	return 1.0
+}
+
+// ScoreAll scores every chunk against the query and returns those scoring at least minScore,
+// sorted by descending score with ties in store order. Chunks whose embedding dimension differs
+// from the query score 0.
+func (vs *VectorStore) ScoreAll(query []float32, minScore float64) []SearchResult {
+	if vs == nil || len(query) == 0 {
+		return nil
+	}
+	queryNorm := math.Sqrt(squaredNorm(query))
+	vs.rlockNorms()
+	defer vs.mu.RUnlock()
+	results := make([]SearchResult, 0, len(vs.Chunks))
+	for i, chunk := range vs.Chunks {
+		score := normalizedScore(query, queryNorm, chunk.Embedding, vs.norms[i])
+		if score >= minScore {
+			results = append(results, SearchResult{Chunk: chunk, Score: score})
+		}
+	}
+	sortByScore(results)
+	return results
+}
+
+// CosineSimilarity returns the cosine similarity of two vectors, failing on empty or mismatched input.
+func CosineSimilarity(a, b []float32) (float64, error) {
+	if len(a) == 0 || len(b) == 0 {
+		return 0, errors.New("cosine similarity of empty vector")
+	}
+	if len(a) != len(b) {
+		return 0, fmt.Errorf("%w: %d vs %d", ErrDimensionMismatch, len(a), len(b))
+	}
+	return cosineSimilarity(a, b), nil
+}
+
 func cosineSimilarity(a, b []float32) float64 {
 	if len(a) == 0 || len(b) == 0 || len(a) != len(b) {
 		return 0
 	}
-	var dot float64
-	var magA float64
-	var magB float64
-	for i := range a {
-		dot += float64(a[i] * b[i])
-		magA += float64(a[i] * a[i])
-		magB += float64(b[i] * b[i])
-	}
+	dot, magA, magB := dotAndSquaredNorms(a, b)
 	if magA == 0 || magB == 0 {
 		return 0
 	}
 	return dot / (math.Sqrt(magA) * math.Sqrt(magB))
 }
 
+// sortByScore orders results by descending score, keeping the current order of ties.
 func sortByScore(results []SearchResult) {
-	sort.Slice(results, func(i, j int) bool {
+	sort.SliceStable(results, func(i, j int) bool {
 		return results[i].Score > results[j].Score
 	})
 }
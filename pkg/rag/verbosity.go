@@ -0,0 +1,47 @@
+package rag
+
+import "context"
+
+const (
+	// briefMaxTokens caps completion tokens for VerbosityBrief.
+	briefMaxTokens = 200
+
+	briefInstruction    = "Answer in one or two sentences. Skip background, caveats, and examples unless the question asks for them."
+	detailedInstruction = "Give a thorough answer. Include step-by-step implementation notes relevant to the Amazon Selling Partner API (SP-API): the operations and endpoints involved, required roles or grantless scopes, key request parameters, and pitfalls such as rate limits, pagination, and marketplace differences."
+)
+
+type verbosityKey struct{}
+
+// withVerbosity returns a context telling chat clients to size completions for verbosity.
+func withVerbosity(ctx context.Context, verbosity Verbosity) context.Context {
+	if verbosity == "" || verbosity == VerbosityNormal {
+		return ctx
+	}
+	return context.WithValue(ctx, verbosityKey{}, verbosity)
+}
+
+// maxTokensFor adjusts a client's completion token limit for the context's verbosity. Zero means
+// the provider default; VerbosityDetailed leaves it so.
+func maxTokensFor(ctx context.Context, maxTokens int) int {
+	switch verbosity, _ := ctx.Value(verbosityKey{}).(Verbosity); verbosity {
+	case VerbosityBrief:
+		if maxTokens <= 0 || maxTokens > briefMaxTokens {
+			return briefMaxTokens
+		}
+	case VerbosityDetailed:
+		return maxTokens * 2
+	}
+	return maxTokens
+}
+
+// verbosityInstruction is the system prompt addition for verbosity, or "" for VerbosityNormal.
+func verbosityInstruction(verbosity Verbosity) string {
+	switch verbosity {
+	case VerbosityBrief:
+		return briefInstruction
+	case VerbosityDetailed:
+		return detailedInstruction
+	default:
+		return ""
+	}
+}
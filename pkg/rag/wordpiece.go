@@ -0,0 +1,213 @@
+package rag
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// maxLocalTokens caps a tokenized text, special tokens included, like BERT-style encoders do.
+const maxLocalTokens = 512
+
+// wordPieceTokenizer implements the BERT tokenization that sentence-transformer exports describe
+// in tokenizer.json: BertNormalizer, BertPreTokenizer, and a WordPiece model with [CLS] and
+// [SEP] around every text.
+type wordPieceTokenizer struct {
+	vocab        map[string]int64
+	unknown      int64
+	cls, sep     int64
+	prefix       string
+	maxWordChars int
+	maxTokens    int
+	lowercase    bool
+	stripAccents bool
+	chineseChars bool
+	cleanText    bool
+}
+
+// loadWordPieceTokenizer reads a Hugging Face tokenizer.json. Only WordPiece models are supported.
+func loadWordPieceTokenizer(path string) (*wordPieceTokenizer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file struct {
+		Truncation *struct {
+			MaxLength int `json:"max_length"`
+		} `json:"truncation"`
+		Normalizer *struct {
+			Type               string `json:"type"`
+			CleanText          *bool  `json:"clean_text"`
+			HandleChineseChars *bool  `json:"handle_chinese_chars"`
+			StripAccents       *bool  `json:"strip_accents"`
+			Lowercase          *bool  `json:"lowercase"`
+		} `json:"normalizer"`
+		Model struct {
+			Type                    string           `json:"type"`
+			UnkToken                string           `json:"unk_token"`
+			ContinuingSubwordPrefix *string          `json:"continuing_subword_prefix"`
+			MaxInputCharsPerWord    int              `json:"max_input_chars_per_word"`
+			Vocab                   map[string]int64 `json:"vocab"`
+		} `json:"model"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if file.Model.Type != "WordPiece" {
+		return nil, fmt.Errorf("%s: unsupported tokenizer model %q; only WordPiece is supported", path, file.Model.Type)
+	}
+
+	t := &wordPieceTokenizer{
+		vocab:        file.Model.Vocab,
+		prefix:       "##",
+		maxWordChars: file.Model.MaxInputCharsPerWord,
+		maxTokens:    maxLocalTokens,
+		lowercase:    true,
+		chineseChars: true,
+		cleanText:    true,
+	}
+	if file.Model.ContinuingSubwordPrefix != nil {
+		t.prefix = *file.Model.ContinuingSubwordPrefix
+	}
+	if t.maxWordChars <= 0 {
+		t.maxWordChars = 100
+	}
+	if file.Truncation != nil && file.Truncation.MaxLength > 0 && file.Truncation.MaxLength < t.maxTokens {
+		t.maxTokens = file.Truncation.MaxLength
+	}
+	if n := file.Normalizer; n != nil && n.Type == "BertNormalizer" {
+		t.lowercase = n.Lowercase == nil || *n.Lowercase
+		t.chineseChars = n.HandleChineseChars == nil || *n.HandleChineseChars
+		t.cleanText = n.CleanText == nil || *n.CleanText
+	} else if n != nil {
+		t.lowercase = false
+	}
+	// BertNormalizer strips accents whenever it lowercases unless told otherwise.
+	t.stripAccents = t.lowercase
+	if n := file.Normalizer; n != nil && n.StripAccents != nil {
+		t.stripAccents = *n.StripAccents
+	}
+
+	var ok bool
+	for token, id := range map[string]*int64{firstNonEmpty(file.Model.UnkToken, "[UNK]"): &t.unknown, "[CLS]": &t.cls, "[SEP]": &t.sep} {
+		if *id, ok = t.vocab[token]; !ok {
+			return nil, fmt.Errorf("%s: vocabulary has no %s token", path, token)
+		}
+	}
+	return t, nil
+}
+
+// encode returns the token IDs of text wrapped in [CLS] and [SEP], truncated to t.maxTokens.
+func (t *wordPieceTokenizer) encode(text string) []int64 {
+	ids := []int64{t.cls}
+	limit := t.maxTokens - 1
+	for _, word := range t.words(t.normalize(text)) {
+		ids = t.appendWordPieces(ids, word)
+		if len(ids) >= limit {
+			ids = ids[:limit]
+			break
+		}
+	}
+	return append(ids, t.sep)
+}
+
+// normalize cleans, lowercases, and strips accents from text as BertNormalizer does.
+func (t *wordPieceTokenizer) normalize(text string) string {
+	if t.stripAccents {
+		text = norm.NFD.String(text)
+	}
+	var b strings.Builder
+	for _, r := range text {
+		switch {
+		case t.cleanText && (r == 0 || r == unicode.ReplacementChar || (unicode.IsControl(r) && !unicode.IsSpace(r))):
+			continue
+		case t.stripAccents && unicode.Is(unicode.Mn, r):
+			continue
+		case t.cleanText && unicode.IsSpace(r):
+			b.WriteByte(' ')
+		case t.chineseChars && isCJK(r):
+			b.WriteByte(' ')
+			b.WriteRune(r)
+			b.WriteByte(' ')
+		case t.lowercase:
+			b.WriteRune(unicode.ToLower(r))
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// words splits normalized text on whitespace and around every punctuation character, like
+// BertPreTokenizer.
+func (t *wordPieceTokenizer) words(text string) []string {
+	var words []string
+	for _, field := range strings.Fields(text) {
+		start := 0
+		for i, r := range field {
+			if !isBertPunctuation(r) {
+				continue
+			}
+			if start < i {
+				words = append(words, field[start:i])
+			}
+			end := i + len(string(r))
+			words = append(words, field[i:end])
+			start = end
+		}
+		if start < len(field) {
+			words = append(words, field[start:])
+		}
+	}
+	return words
+}
+
+// appendWordPieces appends the greedy longest-match pieces of word to ids, or the unknown token
+// when some part of word has no piece.
+func (t *wordPieceTokenizer) appendWordPieces(ids []int64, word string) []int64 {
+	runes := []rune(word)
+	if len(runes) > t.maxWordChars {
+		return append(ids, t.unknown)
+	}
+	var pieces []int64
+	for start := 0; start < len(runes); {
+		end := len(runes)
+		found := false
+		for ; end > start; end-- {
+			piece := string(runes[start:end])
+			if start > 0 {
+				piece = t.prefix + piece
+			}
+			if id, ok := t.vocab[piece]; ok {
+				pieces = append(pieces, id)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return append(ids, t.unknown)
+		}
+		start = end
+	}
+	return append(ids, pieces...)
+}
+
+// isBertPunctuation treats every ASCII non-alphanumeric symbol as punctuation, as BERT does,
+// along with Unicode punctuation.
+func isBertPunctuation(r rune) bool {
+	if (r >= 33 && r <= 47) || (r >= 58 && r <= 64) || (r >= 91 && r <= 96) || (r >= 123 && r <= 126) {
+		return true
+	}
+	return unicode.IsPunct(r)
+}
+
+// isCJK reports whether r is in a CJK ideograph block, which BERT tokenizes one character at a time.
+func isCJK(r rune) bool {
+	return (r >= 0x4E00 && r <= 0x9FFF) || (r >= 0x3400 && r <= 0x4DBF) || (r >= 0x20000 && r <= 0x2A6DF) ||
+		(r >= 0x2A700 && r <= 0x2B73F) || (r >= 0x2B740 && r <= 0x2B81F) || (r >= 0x2B820 && r <= 0x2CEAF) ||
+		(r >= 0xF900 && r <= 0xFAFF) || (r >= 0x2F800 && r <= 0x2FA1F)
+}
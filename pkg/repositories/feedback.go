@@ -0,0 +1,26 @@
+package repositories
+
+import (
+	"gorm.io/gorm"
+)
+
+const (
+	RatingUp   = "up"
+	RatingDown = "down"
+)
+
+type Feedback struct {
+	gorm.Model
+	QueryLogID uint     `gorm:"not null;index" json:"query_log_id"`
+	QueryLog   QueryLog `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+	Rating     string   `gorm:"not null;size:8" json:"rating"`
+	Comment    string   `gorm:"type:text" json:"comment,omitempty"`
+}
+
+// CreateFeedback - stores a rating for a logged query; returns gorm.ErrRecordNotFound when the query log does not exist
+func CreateFeedback(db *gorm.DB, entry *Feedback) error {
+	if err := db.Select("id").First(&QueryLog{}, entry.QueryLogID).Error; err != nil {
+		return err
+	}
+	return db.Omit("QueryLog").Create(entry).Error
+}
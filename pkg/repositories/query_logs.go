@@ -0,0 +1,20 @@
+package repositories
+
+import (
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+)
+
+type QueryLog struct {
+	gorm.Model
+	Question          string         `gorm:"type:text;not null" json:"question"`
+	TopK              int            `gorm:"not null" json:"top_k"`
+	AnswerLength      int            `gorm:"not null" json:"answer_length"`
+	SourceDocumentIDs pq.StringArray `gorm:"type:text[]" json:"source_document_ids"`
+	LatencyMs         int64          `gorm:"not null" json:"latency_ms"`
+}
+
+// LogQuery - stores an answered RAG question; entry.ID is populated on success
+func LogQuery(db *gorm.DB, entry *QueryLog) error {
+	return db.Create(entry).Error
+}
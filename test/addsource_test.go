@@ -0,0 +1,53 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+
+	"cmd/main.go/pkg/rag"
+)
+
+func TestAddSourceConcurrentWithClearKeepsIndexInSync(t *testing.T) {
+	indexPath := filepath.Join(t.TempDir(), "index.json")
+	store := &rag.VectorStore{}
+	if err := store.Add([]rag.Chunk{{ID: "seed", DocumentID: "seed", Text: "Seed chunk.", Embedding: []float32{1, 0}}}); err != nil {
+		t.Fatal(err)
+	}
+	service, err := rag.NewService(store, constantEmbedder{}, &recordingChat{}, rag.ServiceConfig{IndexPath: indexPath})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			src := rag.NewSource{Title: fmt.Sprintf("Source %d", i), Content: "Orders ship within two days."}
+			if _, err := service.AddSource(context.Background(), src, rag.ChunkOptions{}, true); err != nil {
+				t.Error(err)
+			}
+			if i%5 == 0 {
+				if _, err := service.Clear(); err != nil {
+					t.Error(err)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	saved, err := rag.LoadVectorStore(indexPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, want := chunkIDs(saved.Chunks), chunkIDs(store.Chunks)
+	slices.Sort(got)
+	slices.Sort(want)
+	if !slices.Equal(got, want) {
+		t.Fatalf("saved index has chunks %v, service has %v", got, want)
+	}
+}
@@ -0,0 +1,35 @@
+package test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"cmd/main.go/pkg/rag"
+)
+
+// shortEmbedder drops the last text of every batch, like providers that skip some inputs.
+type shortEmbedder struct{}
+
+func (shortEmbedder) Embed(_ context.Context, texts []string) ([][]float32, error) {
+	embeddings := make([][]float32, 0, len(texts))
+	for range texts[:len(texts)-1] {
+		embeddings = append(embeddings, []float32{1, 0})
+	}
+	return embeddings, nil
+}
+
+func (shortEmbedder) EmbedOne(context.Context, string) ([]float32, error) {
+	return []float32{1, 0}, nil
+}
+
+func TestBuildVectorStoreShortBatch(t *testing.T) {
+	chunks := []rag.Chunk{{ID: "a", Text: "one"}, {ID: "b", Text: "two"}, {ID: "c", Text: "three"}}
+	_, err := rag.BuildVectorStore(context.Background(), chunks, shortEmbedder{}, rag.BuildOptions{BatchSize: 2}, rag.Metadata{})
+	if err == nil {
+		t.Fatal("expected an error for a short embedding batch")
+	}
+	if !strings.Contains(err.Error(), "embed chunks 1-2: got 1 embeddings for 2 texts") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
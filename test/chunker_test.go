@@ -0,0 +1,55 @@
+package test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"cmd/main.go/pkg/rag"
+)
+
+func chunkIDs(chunks []rag.Chunk) []string {
+	ids := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		ids[i] = chunk.ID
+	}
+	return ids
+}
+
+func TestChunkIDsAreDeterministic(t *testing.T) {
+	docs := []rag.Document{
+		{ID: rag.Slugify("Local: guides/Returns API.md"), Content: strings.Repeat("Returns are processed within 30 days. ", 40)},
+		{ID: rag.Slugify("Local: notes.txt"), Content: "short note"},
+	}
+	opts := rag.ChunkOptions{Size: 200, Overlap: 40}
+
+	first := rag.ChunkDocuments(docs, opts)
+	second := rag.ChunkDocuments(docs, opts)
+	if len(first) < 3 {
+		t.Fatalf("expected several chunks, got %d", len(first))
+	}
+	if !reflect.DeepEqual(chunkIDs(first), chunkIDs(second)) {
+		t.Fatalf("chunk IDs differ between runs:\n%v\n%v", chunkIDs(first), chunkIDs(second))
+	}
+	if want := docs[0].ID + "-chunk-0"; first[0].ID != want {
+		t.Fatalf("first chunk ID = %q, want %q", first[0].ID, want)
+	}
+}
+
+func TestChunkMinSizeMergesTinyTrailingChunk(t *testing.T) {
+	doc := rag.Document{ID: "doc", Content: "abcdefghijklmnopqrstuvwxy"} // 25 runes: windows of 10, 10, and 5
+
+	chunks := rag.ChunkDocuments([]rag.Document{doc}, rag.ChunkOptions{Size: 10, MinSize: 6})
+	if len(chunks) != 2 {
+		t.Fatalf("got %d chunks, want 2: %v", len(chunks), chunkIDs(chunks))
+	}
+	last := chunks[1]
+	if last.Text != "klmnopqrstuvwxy" || last.StartOffset != 10 || last.EndOffset != 25 {
+		t.Fatalf("last chunk = %q [%d, %d), want the tail merged into it", last.Text, last.StartOffset, last.EndOffset)
+	}
+
+	kept := rag.ChunkDocuments([]rag.Document{doc}, rag.ChunkOptions{Size: 10, MinSize: 5})
+	if len(kept) != 3 {
+		t.Fatalf("a trailing chunk of exactly MinSize must be kept; got %d chunks", len(kept))
+	}
+}
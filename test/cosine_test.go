@@ -0,0 +1,41 @@
+package test
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"cmd/main.go/pkg/rag"
+)
+
+// referenceCosine is the plain float64 loop every rag.CosineBackend must agree with.
+func referenceCosine(a, b []float32) float64 {
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// TestCosineBackendMatchesScalar checks the compiled kernel against the scalar loop; run it with
+// and without -tags gonum.
+func TestCosineBackendMatchesScalar(t *testing.T) {
+	rng := rand.New(rand.NewSource(7))
+	for _, dim := range []int{3, 384, 768, 3072} {
+		for trial := 0; trial < 20; trial++ {
+			a, b := make([]float32, dim), make([]float32, dim)
+			for i := range a {
+				a[i], b[i] = rng.Float32()*2-1, rng.Float32()*2-1
+			}
+			got, err := rag.CosineSimilarity(a, b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := referenceCosine(a, b); math.Abs(got-want) > 1e-6 {
+				t.Fatalf("%s backend, dim %d: got %.9f, want %.9f", rag.CosineBackend, dim, got, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"cmd/main.go/pkg/rag"
+)
+
+func TestFocusTerms(t *testing.T) {
+	store := &rag.VectorStore{}
+	if err := store.Add([]rag.Chunk{{ID: "quota", DocumentID: "quota", Source: "Quotas", Text: "getOrders allows 20 requests per hour.", Embedding: []float32{1, 0}}}); err != nil {
+		t.Fatal(err)
+	}
+	service, err := rag.NewService(store, constantEmbedder{}, &recordingChat{}, rag.ServiceConfig{FocusTerms: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		question string
+		want     string
+	}{
+		{"What is the hourly quota for getOrders?", "hourly, quota, getOrders"},
+		{"How do I authenticate with the SP-API?", "authenticate, SP-API"},
+		{"Which daily, weekly, monthly, quarterly, and yearly reports exist?", "daily, weekly, monthly, quarterly, yearly, reports, exist"},
+		{"Does a refund apply when I reply to the supply notice?", "refund, apply, reply, supply, notice"},
+		{"Do returns really only take exactly 30 days?", "returns, take, days"},
+		{"Is my family plan available in Italy in July?", "family, plan, available, Italy, July"},
+	}
+	for _, tt := range tests {
+		answer, err := service.Answer(context.Background(), tt.question, rag.QueryOptions{ReturnPrompt: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		line, _, _ := strings.Cut(answer.DebugPrompt, "\n")
+		if got := strings.TrimPrefix(line, "Focus terms: "); got != tt.want {
+			t.Errorf("%q: focus terms = %q, want %q", tt.question, got, tt.want)
+		}
+	}
+}
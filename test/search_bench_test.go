@@ -0,0 +1,74 @@
+package test
+
+import (
+	"math/rand"
+	"testing"
+
+	"cmd/main.go/pkg/rag"
+)
+
+const (
+	benchChunks = 20000
+	benchDim    = 768
+)
+
+func randomEmbedding(rng *rand.Rand) []float32 {
+	embedding := make([]float32, benchDim)
+	for i := range embedding {
+		embedding[i] = rng.Float32()*2 - 1
+	}
+	return embedding
+}
+
+func benchStore(b *testing.B) (*rag.VectorStore, []float32) {
+	b.Helper()
+	rng := rand.New(rand.NewSource(1))
+	chunks := make([]rag.Chunk, benchChunks)
+	for i := range chunks {
+		chunks[i] = rag.Chunk{ID: "chunk", Embedding: randomEmbedding(rng)}
+	}
+	store := &rag.VectorStore{}
+	if err := store.Add(chunks); err != nil {
+		b.Fatal(err)
+	}
+	return store, randomEmbedding(rng)
+}
+
+// BenchmarkSearch measures an in-memory search over 20k chunks using the cached magnitudes.
+func BenchmarkSearch(b *testing.B) {
+	store, query := benchStore(b)
+	store.Search(query, 5) // fill the magnitude cache outside the timed loop
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		store.Search(query, 5)
+	}
+}
+
+// BenchmarkCosineScan is the baseline: scoring every chunk with both magnitudes recomputed.
+func BenchmarkCosineScan(b *testing.B) {
+	store, query := benchStore(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, chunk := range store.Chunks {
+			if _, err := rag.CosineSimilarity(query, chunk.Embedding); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+// BenchmarkCosineSimilarity times one comparison of 3072-dimension vectors, the size of OpenAI's
+// text-embedding-3-large. Compare backends with and without -tags gonum.
+func BenchmarkCosineSimilarity(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	x, y := make([]float32, 3072), make([]float32, 3072)
+	for i := range x {
+		x[i], y[i] = rng.Float32()*2-1, rng.Float32()*2-1
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := rag.CosineSimilarity(x, y); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package test
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"cmd/main.go/pkg/rag"
+)
+
+// TestVectorStoreConcurrentAddSearch is meant for go test -race: readers must never see a
+// half-appended chunk slice or metadata.
+func TestVectorStoreConcurrentAddSearch(t *testing.T) {
+	store := &rag.VectorStore{}
+	if err := store.Add([]rag.Chunk{{ID: "seed", DocumentID: "seed", Embedding: []float32{1, 0, 0}}}); err != nil {
+		t.Fatal(err)
+	}
+
+	const writers, readers, rounds = 4, 4, 200
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < rounds; i++ {
+				chunk := rag.Chunk{ID: fmt.Sprintf("w%d-%d", w, i), DocumentID: fmt.Sprintf("doc-%d", w), Embedding: []float32{float32(w), float32(i), 1}}
+				if err := store.Add([]rag.Chunk{chunk}); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(w)
+	}
+	for r := 0; r < readers; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < rounds; i++ {
+				if results := store.Search([]float32{1, 0, 0}, 3); len(results) == 0 {
+					t.Error("search returned no results")
+					return
+				}
+				_ = store.Meta()
+				_ = store.Len()
+			}
+		}()
+	}
+	wg.Wait()
+
+	want := 1 + writers*rounds
+	if got := store.Len(); got != want {
+		t.Fatalf("Len() = %d, want %d", got, want)
+	}
+	if got := store.Meta().ChunkCount; got != want {
+		t.Fatalf("Meta().ChunkCount = %d, want %d", got, want)
+	}
+}
+
+// TestReplaceDocumentConcurrentInsert checks that concurrent inserts of one document with
+// replacement off let exactly one writer through.
+func TestReplaceDocumentConcurrentInsert(t *testing.T) {
+	store := &rag.VectorStore{}
+	const writers = 8
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			chunk := rag.Chunk{ID: fmt.Sprintf("doc-%d", w), DocumentID: "doc", Embedding: []float32{1, 0}}
+			_, err := store.ReplaceDocument("doc", []rag.Chunk{chunk}, false)
+			errs <- err
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+
+	inserted := 0
+	for err := range errs {
+		switch {
+		case err == nil:
+			inserted++
+		case !errors.Is(err, rag.ErrSourceExists):
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if inserted != 1 || store.Len() != 1 {
+		t.Fatalf("got %d successful inserts and %d chunks, want 1 and 1", inserted, store.Len())
+	}
+}
+
+// TestSearchWithMinKeepsTieOrder checks that a score floor changes which chunks are returned but
+// not how equal scores are ordered.
+func TestSearchWithMinKeepsTieOrder(t *testing.T) {
+	store := &rag.VectorStore{}
+	var chunks []rag.Chunk
+	for i := 0; i < 8; i++ {
+		embedding := []float32{1, 0}
+		if i%3 == 0 {
+			embedding = []float32{0, 1}
+		}
+		chunks = append(chunks, rag.Chunk{ID: fmt.Sprintf("c%d", i), DocumentID: fmt.Sprintf("d%d", i), Embedding: embedding})
+	}
+	if err := store.Add(chunks); err != nil {
+		t.Fatal(err)
+	}
+
+	query := []float32{1, 0}
+	ids := func(results []rag.SearchResult) []string {
+		out := make([]string, len(results))
+		for i, result := range results {
+			out[i] = result.Chunk.ID
+		}
+		return out
+	}
+	plain := ids(store.Search(query, 5))
+	floored := ids(store.SearchWithMin(query, 5, 0.5))
+	want := []string{"c1", "c2", "c4", "c5", "c7"}
+	if fmt.Sprint(plain) != fmt.Sprint(want) || fmt.Sprint(floored) != fmt.Sprint(want) {
+		t.Fatalf("Search = %v, SearchWithMin = %v, want both %v", plain, floored, want)
+	}
+}